@@ -11,7 +11,10 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/nextiva/nextkala/api/middleware"
@@ -29,8 +32,16 @@ const (
 	JobPath    = "job/"
 	ApiJobPath = ApiUrlPrefix + JobPath
 
+	SchedulePreviewPath = "schedule/preview/"
+
+	// Number of runs returned by the next runs and schedule preview routes
+	// when no count is given, and the most they return.
+	DefaultNextRunsCount = 10
+	MaxNextRunsCount     = 1000
+
 	contentType     = "Content-Type"
 	jsonContentType = "application/json;charset=UTF-8"
+	textContentType = "text/plain;charset=UTF-8"
 
 	httpDelete = "DELETE"
 	httpGet    = "GET"
@@ -106,9 +117,9 @@ func unmarshalNewJob(r *http.Request) (*job.Job, error) {
 	return newJob, nil
 }
 
-func unmarshalJobStatus(r *http.Request) (*job.JobStatus, error) {
+func unmarshalCallbackResult(r *http.Request) (*job.CallbackResult, error) {
 
-	var jobStatus job.JobStatus
+	var result job.CallbackResult
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
 	if err != nil {
 		log.Errorf("Error occurred when reading r.Body: %s", err)
@@ -116,12 +127,12 @@ func unmarshalJobStatus(r *http.Request) (*job.JobStatus, error) {
 	}
 	defer r.Body.Close()
 
-	if err = json.Unmarshal(body, &jobStatus); err != nil {
+	if err = json.Unmarshal(body, &result); err != nil {
 		log.Errorf("Error occurred when unmarshaling data: %s", err)
 		return nil, err
 	}
 
-	return &jobStatus, nil
+	return &result, nil
 }
 
 // HandleAddJob takes a job object and unmarshals it to a Job type,
@@ -135,7 +146,7 @@ func HandleAddJob(cache job.JobCache, defaultOwner string, disableLocalJobs bool
 			return
 		}
 
-		if disableLocalJobs && (newJob.JobType == job.LocalJob) {
+		if disableLocalJobs && newJob.IsLocal() {
 			errorEncodeJSON(errors.New("local jobs are disabled"), http.StatusForbidden, w)
 			return
 		}
@@ -214,7 +225,7 @@ func HandleJobRequest(cache job.JobCache, disableLocalJobs bool) func(w http.Res
 				return
 			}
 
-			if disableLocalJobs && (updatedJob.JobType == job.LocalJob) {
+			if disableLocalJobs && updatedJob.IsLocal() {
 				errorEncodeJSON(errors.New("local jobs are disabled"), http.StatusForbidden, w)
 				return
 			}
@@ -418,7 +429,7 @@ func HandleListJobRunsRequest(cache job.JobCache) func(w http.ResponseWriter, r
 		}
 
 		resp := &ListJobStatsResponse{
-			JobStats: allJobs,
+			JobStats: withRunningStats(allJobs, job.RunningStats(id)),
 		}
 
 		w.Header().Set(contentType, jsonContentType)
@@ -430,6 +441,20 @@ func HandleListJobRunsRequest(cache job.JobCache) func(w http.ResponseWriter, r
 	}
 }
 
+// withRunningStats adds the stats of runs in progress to those saved, other than those saved already.
+func withRunningStats(saved, running []*job.JobStat) []*job.JobStat {
+	ids := make(map[string]bool, len(saved))
+	for _, stat := range saved {
+		ids[stat.Id] = true
+	}
+	for _, stat := range running {
+		if !ids[stat.Id] {
+			saved = append(saved, stat)
+		}
+	}
+	return saved
+}
+
 // JobRunResponse is for returning a single job execution
 type JobRunResponse struct {
 	JobRun *job.JobStat `json:"job_run"`
@@ -439,12 +464,16 @@ type JobRunResponse struct {
 // /api/v1/job/{job_id}/executions/{run_id}/
 func HandleJobRunRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := mux.Vars(r)["job_id"]
 		runID := mux.Vars(r)["id"]
 
 		switch r.Method {
 		case httpGet:
 			run, err := cache.GetRun(runID)
-			if err != nil {
+			if runLog, ok := job.GetRunLog(runID); err != nil && ok {
+				run, err = runLog.Stat(), nil
+			}
+			if err != nil || run == nil || run.JobId != jobID {
 				log.Errorf("Error occurred when trying to get job execution #{runID}.")
 				w.WriteHeader(http.StatusNotFound)
 				return
@@ -462,7 +491,7 @@ func HandleJobRunRequest(cache job.JobCache) func(w http.ResponseWriter, r *http
 			}
 		case httpPut:
 			run, err := cache.GetRun(runID)
-			if err != nil {
+			if err != nil || run == nil || run.JobId != jobID {
 				log.Errorf("Error occurred when trying to get job execution #{runID}.")
 				w.WriteHeader(http.StatusNotFound)
 				return
@@ -472,14 +501,25 @@ func HandleJobRunRequest(cache job.JobCache) func(w http.ResponseWriter, r *http
 				log.Errorf("Unable to retrieve job %s due to %v: ", run.JobId, err)
 			}
 
-			jobStatus, err := unmarshalJobStatus(r)
+			result, err := unmarshalCallbackResult(r)
 
 			if err != nil {
 				log.Errorf("Error occurred when trying to update job status for job execution #{runID}.")
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
-			run.Status = *jobStatus
+
+			// The runs of async jobs are completed by the run itself, once it has its callback.
+			if j != nil && j.IsAsync() {
+				if err := job.CompleteRun(runID, r.Header.Get(job.CallbackTokenHeader), result); err != nil {
+					errorEncodeJSON(err, callbackErrorStatus(err), w)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			run.Status = result.Status
 			run.ExecutionDuration = j.Now().Sub(run.RanAt)
 
 			err = cache.UpdateRun(run)
@@ -494,6 +534,214 @@ func HandleJobRunRequest(cache job.JobCache) func(w http.ResponseWriter, r *http
 	}
 }
 
+// callbackErrorStatus is the status code to respond to an async job's callback with, for an error completing the run.
+func callbackErrorStatus(err error) int {
+	switch err {
+	case job.ErrInvalidCallbackToken:
+		return http.StatusForbidden
+	case job.ErrRunNotAwaitingCallback:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// HandleCancelJobRunRequest cancels a job's run in progress.
+// POST /api/v1/job/{job_id}/executions/{id}/cancel/
+func HandleCancelJobRunRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := mux.Vars(r)["job_id"]
+		runID := mux.Vars(r)["id"]
+
+		j, err := cache.Get(jobID)
+		if err != nil || j == nil {
+			log.Errorf("Error occurred when trying to get the job you requested.")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := j.CancelRun(runID); err != nil {
+			errorEncodeJSON(err, http.StatusNotFound, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleJobRunLogRequest responds with the log of a local job's run, as far as it's got.
+// With follow=true, the response goes on with the log as the run writes it, until the run is done.
+// GET /api/v1/job/{job_id}/executions/{id}/log/
+func HandleJobRunLogRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := mux.Vars(r)["job_id"]
+		runID := mux.Vars(r)["id"]
+
+		runLog, ok := job.GetRunLog(runID)
+		if !ok || runLog.Stat().JobId != jobID {
+			// The run is done, so its log is with its stats.
+			run, err := cache.GetRun(runID)
+			if err != nil || run.JobId != jobID {
+				log.Errorf("Error occurred when trying to get job execution %s: %v", runID, err)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set(contentType, textContentType)
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, run.CombinedLog()) //nolint:errcheck // Nothing more to be done
+			return
+		}
+
+		follow := r.URL.Query().Get("follow") == "true"
+		flusher, _ := w.(http.Flusher)
+		w.Header().Set(contentType, textContentType)
+		if follow {
+			// Keeps the gzip middleware from holding the log back.
+			w.Header().Set("Content-Encoding", "identity")
+		}
+		w.WriteHeader(http.StatusOK)
+
+		var offset int64
+		for {
+			data, next, done, changed := runLog.Since(offset)
+			offset = next
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			if !follow || done {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// NextRunsResponse is for returning upcoming run times
+type NextRunsResponse struct {
+	NextRuns []time.Time `json:"next_runs"`
+}
+
+// nextRunsCount reads the count query parameter of the next runs and schedule preview routes.
+func nextRunsCount(r *http.Request) (int, error) {
+	param := r.URL.Query().Get("count")
+	if param == "" {
+		return DefaultNextRunsCount, nil
+	}
+	count, err := strconv.Atoi(param)
+	if err != nil || count < 1 || count > MaxNextRunsCount {
+		return 0, fmt.Errorf("count must be a number from 1 to %d", MaxNextRunsCount)
+	}
+	return count, nil
+}
+
+func handleNextRuns(w http.ResponseWriter, r *http.Request, j *job.Job) {
+	count, err := nextRunsCount(r)
+	if err != nil {
+		errorEncodeJSON(err, http.StatusBadRequest, w)
+		return
+	}
+
+	runs, err := j.NextRuns(count)
+	if err != nil {
+		errorEncodeJSON(err, http.StatusBadRequest, w)
+		return
+	}
+
+	resp := &NextRunsResponse{
+		NextRuns: runs,
+	}
+
+	w.Header().Set(contentType, jsonContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Error occurred when marshaling response: %s", err)
+		return
+	}
+}
+
+// HandleNextRunsRequest is the handler for getting a job's upcoming run times
+// /api/v1/job/{id}/next-runs/?count=N
+func HandleNextRunsRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		j, err := cache.Get(id)
+		if err != nil {
+			log.Errorf("Error occurred when trying to get the job you requested.")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if j == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		handleNextRuns(w, r, j)
+	}
+}
+
+// JobTypeResponse describes a job type, with a JSON schema of the job fields it uses.
+type JobTypeResponse struct {
+	Type   int             `json:"type"`
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type ListJobTypesResponse struct {
+	Types []JobTypeResponse `json:"types"`
+}
+
+// HandleListJobTypesRequest responds with the job types jobs can have, including any registered
+// by programs embedding NextKala.
+// GET /api/v1/job/types/
+func HandleListJobTypesRequest() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := &ListJobTypesResponse{}
+		for t, executor := range job.Executors() {
+			resp.Types = append(resp.Types, JobTypeResponse{
+				Type:   int(t),
+				Name:   executor.Name(),
+				Schema: executor.Schema(),
+			})
+		}
+		sort.Slice(resp.Types, func(i, k int) bool { return resp.Types[i].Type < resp.Types[k].Type })
+
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Errorf("Error occurred when marshaling response: %s", err)
+			return
+		}
+	}
+}
+
+// HandleSchedulePreviewRequest takes a job object and responds with the times it would run at,
+// without saving it.
+// /api/v1/schedule/preview/?count=N
+func HandleSchedulePreviewRequest() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		previewJob, err := unmarshalNewJob(r)
+		if err != nil {
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		// Check the schedule as creating the job would.
+		if err := previewJob.InitDelayDuration(true); err != nil {
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		handleNextRuns(w, r, previewJob)
+	}
+}
+
 // validateJob sends an http request to the remote job, and returns the result of that check.
 func validateJob(r *http.Request, j *job.Job) (bool, error) {
 	ctx := r.Context()
@@ -545,8 +793,13 @@ func validateJob(r *http.Request, j *job.Job) (bool, error) {
 		}
 	}
 
+	client, err := j.HTTPClient()
+	if err != nil {
+		return false, err
+	}
+
 	// Do the request
-	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	res, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return false, err
 	}
@@ -586,6 +839,8 @@ func SetupApiRoutes(r *mux.Router, cache job.JobCache, defaultOwner string, disa
 	r.HandleFunc(ApiJobPath, HandleAddJob(cache, defaultOwner, disableLocalJobs)).Methods(httpPost)
 	// Route for deleting all jobs
 	r.HandleFunc(ApiJobPath+"all/", HandleDeleteAllJobs(cache, disableDeleteAll)).Methods(httpDelete)
+	// Route for listing the job types
+	r.HandleFunc(ApiJobPath+"types/", HandleListJobTypesRequest()).Methods(httpGet)
 	// Route for deleting, editing and getting a job
 	r.HandleFunc(ApiJobPath+"{id}/", HandleJobRequest(cache, disableLocalJobs)).Methods(httpDelete, httpGet, httpPut)
 	// Route for updating a remote job's parameters.
@@ -602,8 +857,42 @@ func SetupApiRoutes(r *mux.Router, cache job.JobCache, defaultOwner string, disa
 	r.HandleFunc(ApiUrlPrefix+"stats/", HandleKalaStatsRequest(cache)).Methods(httpGet)
 	// Route for a single job execution actions
 	r.HandleFunc(ApiJobPath+"{job_id}/executions/{id}/", HandleJobRunRequest(cache)).Methods(httpGet, httpPut)
+	// Route for cancelling a job execution in progress
+	r.HandleFunc(ApiJobPath+"{job_id}/executions/{id}/cancel/", HandleCancelJobRunRequest(cache)).Methods(httpPost)
+	// Route for a job execution's log, followed as it's written with follow=true
+	r.HandleFunc(ApiJobPath+"{job_id}/executions/{id}/log/", HandleJobRunLogRequest(cache)).Methods(httpGet)
 	// Route for a single job execution actions
 	r.HandleFunc(ApiJobPath+"{id}/executions/", HandleListJobRunsRequest(cache)).Methods(httpGet)
+	// Route for getting a job's upcoming run times
+	r.HandleFunc(ApiJobPath+"{id}/next-runs/", HandleNextRunsRequest(cache)).Methods(httpGet)
+	// Route for previewing the run times of a job before creating it
+	r.HandleFunc(ApiUrlPrefix+SchedulePreviewPath, HandleSchedulePreviewRequest()).Methods(httpPost)
+	// Route for creating a calendar
+	r.HandleFunc(ApiCalendarPath, HandleAddCalendar(cache)).Methods(httpPost)
+	// Route for listing all calendars
+	r.HandleFunc(ApiCalendarPath, HandleListCalendarsRequest(cache)).Methods(httpGet)
+	// Route for deleting, editing and getting a calendar
+	r.HandleFunc(ApiCalendarPath+"{name}/", HandleCalendarRequest(cache)).Methods(httpDelete, httpGet, httpPut)
+	// Route for loading a calendar's holidays from an iCalendar file
+	r.HandleFunc(ApiCalendarPath+"{name}/holidays/", HandleCalendarHolidaysRequest(cache)).Methods(httpPut)
+	// Route for creating a resource pool
+	r.HandleFunc(ApiResourcePoolPath, HandleAddResourcePool(cache)).Methods(httpPost)
+	// Route for listing all resource pools
+	r.HandleFunc(ApiResourcePoolPath, HandleListResourcePoolsRequest(cache)).Methods(httpGet)
+	// Route for deleting, editing and getting a resource pool
+	r.HandleFunc(ApiResourcePoolPath+"{name}/", HandleResourcePoolRequest(cache)).Methods(httpDelete, httpGet, httpPut)
+	// Route for creating a workflow
+	r.HandleFunc(ApiWorkflowPath, HandleAddWorkflow(cache)).Methods(httpPost)
+	// Route for listing all workflows
+	r.HandleFunc(ApiWorkflowPath, HandleListWorkflowsRequest(cache)).Methods(httpGet)
+	// Route for deleting, editing and getting a workflow
+	r.HandleFunc(ApiWorkflowPath+"{name}/", HandleWorkflowRequest(cache)).Methods(httpDelete, httpGet, httpPut)
+	// Route for starting a workflow run, and listing a workflow's runs
+	r.HandleFunc(ApiWorkflowPath+"{name}/runs/", HandleWorkflowRunsRequest(cache)).Methods(httpGet, httpPost)
+	// Route for getting a workflow run
+	r.HandleFunc(ApiWorkflowPath+"{name}/runs/{id}/", HandleWorkflowRunRequest(cache)).Methods(httpGet)
+	// Route for re-running a workflow run
+	r.HandleFunc(ApiWorkflowPath+"{name}/runs/{id}/rerun/", HandleRerunWorkflowRequest(cache)).Methods(httpPost)
 	r.Use(job.AuthHandler)
 }
 
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -125,6 +126,22 @@ func (a *ApiTestSuite) TestHandleAddDisabledLocalJob() {
 	a.True(strings.Contains(respErr.Error, "local jobs are disabled"))
 }
 
+func (a *ApiTestSuite) TestHandleAddDisabledScriptJob() {
+	cache := job.NewMockCache()
+	handler := HandleAddJob(cache, "", true)
+
+	jsonJobMap, err := json.Marshal(map[string]interface{}{
+		"name":   "mock_script_job",
+		"type":   job.ScriptJob,
+		"script": "date",
+	})
+	a.NoError(err)
+	w, req := setupTestReq(a.T(), "POST", ApiJobPath, jsonJobMap)
+	handler(w, req)
+
+	a.Equal(http.StatusForbidden, w.Code)
+}
+
 func (a *ApiTestSuite) TestHandleAddRemoteJob() {
 	t := a.T()
 	cache := job.NewMockCache()
@@ -193,6 +210,26 @@ func (a *ApiTestSuite) TestHandleAddJobFailureBadSchedule() {
 	a.True(strings.Contains(respErr.Error, "when initializing"))
 }
 
+func (a *ApiTestSuite) TestHandleAddJobFailureBadCronSchedule() {
+	t := a.T()
+	cache := job.NewMockCache()
+	jobMap := generateNewJobMap()
+	handler := HandleAddJob(cache, "", false)
+
+	delete(jobMap, "schedule")
+	jobMap["cron_schedule"] = "0 25 * * *"
+
+	jsonJobMap, err := json.Marshal(jobMap)
+	a.NoError(err)
+	w, req := setupTestReq(t, "POST", ApiJobPath, jsonJobMap)
+	handler(w, req)
+	a.Equal(w.Code, http.StatusBadRequest)
+	var respErr apiError
+	err = json.Unmarshal(w.Body.Bytes(), &respErr)
+	a.NoError(err)
+	a.True(strings.Contains(respErr.Error, "when initializing"))
+}
+
 func (a *ApiTestSuite) TestDeleteJobSuccess() {
 	t := a.T()
 	cache, j := generateJobAndCache()
@@ -588,6 +625,219 @@ func (a *ApiTestSuite) TestHandleKalaStatsRequest() {
 	a.WithinDuration(statsResp.Stats.CreatedAt, now, 2*time.Second)
 }
 
+func (a *ApiTestSuite) TestHandleNextRunsRequest() {
+	cache, j := generateJobAndCache()
+	r := mux.NewRouter()
+	r.HandleFunc(ApiJobPath+"{id}/next-runs/", HandleNextRunsRequest(cache)).Methods("GET")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	_, req := setupTestReq(a.T(), "GET", ts.URL+ApiJobPath+j.Id+"/next-runs/?count=2", nil)
+	resp, err := http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+
+	var nextRunsResp NextRunsResponse
+	unmarshallRequestBody(a.T(), resp, &nextRunsResp)
+	a.Len(nextRunsResp.NextRuns, 2)
+	a.WithinDuration(j.NextRunAt, nextRunsResp.NextRuns[0], time.Second)
+	a.WithinDuration(nextRunsResp.NextRuns[0].Add(24*time.Hour+10*time.Minute+10*time.Second),
+		nextRunsResp.NextRuns[1], time.Second)
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiJobPath+j.Id+"/next-runs/?count=lots", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiJobPath+"not-a-real-id/next-runs/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (a *ApiTestSuite) TestHandleListJobTypesRequest() {
+	handler := HandleListJobTypesRequest()
+	w, req := setupTestReq(a.T(), "GET", ApiJobPath+"types/", nil)
+	handler(w, req)
+	a.Equal(http.StatusOK, w.Code)
+
+	var resp ListJobTypesResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	if a.Len(resp.Types, 3) {
+		a.Equal(int(job.LocalJob), resp.Types[0].Type)
+		a.Equal("local", resp.Types[0].Name)
+		a.Equal("remote", resp.Types[1].Name)
+		a.Equal("script", resp.Types[2].Name)
+		a.Contains(string(resp.Types[2].Schema), `"interpreter"`)
+	}
+}
+
+func (a *ApiTestSuite) TestHandleSchedulePreviewRequest() {
+	handler := HandleSchedulePreviewRequest()
+
+	jsonJobMap, err := json.Marshal(map[string]string{
+		"name":          "mock_job",
+		"cron_schedule": "0 0 1 * *",
+		"timezone":      "Europe/Paris",
+	})
+	a.NoError(err)
+	w, req := setupTestReq(a.T(), "POST", ApiUrlPrefix+SchedulePreviewPath+"?count=3", jsonJobMap)
+	handler(w, req)
+	a.Equal(http.StatusOK, w.Code)
+
+	var nextRunsResp NextRunsResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &nextRunsResp))
+	a.Len(nextRunsResp.NextRuns, 3)
+	for _, run := range nextRunsResp.NextRuns {
+		a.Equal(1, run.Day())
+		a.Equal(0, run.Hour())
+	}
+
+	// Without a count.
+	w, req = setupTestReq(a.T(), "POST", ApiUrlPrefix+SchedulePreviewPath, jsonJobMap)
+	handler(w, req)
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &nextRunsResp))
+	a.Len(nextRunsResp.NextRuns, DefaultNextRunsCount)
+
+	jsonJobMap, err = json.Marshal(map[string]string{
+		"name":          "mock_job",
+		"cron_schedule": "0 0 31 2 *",
+	})
+	a.NoError(err)
+	w, req = setupTestReq(a.T(), "POST", ApiUrlPrefix+SchedulePreviewPath, jsonJobMap)
+	handler(w, req)
+	a.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (a *ApiTestSuite) TestHandleJobRunLogRequest() {
+	cache, j := generateJobAndCache()
+	j.Command = "bash -c 'echo one; sleep 1; echo two'"
+	done := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(done)
+	}()
+
+	var running []*job.JobStat
+	for i := 0; i < 100 && len(running) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		running = job.RunningStats(j.Id)
+	}
+	if !a.Len(running, 1) {
+		return
+	}
+	runID := running[0].Id
+
+	r := mux.NewRouter()
+	r.HandleFunc(ApiJobPath+"{job_id}/executions/{id}/log/", HandleJobRunLogRequest(cache)).Methods("GET")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	logURL := ts.URL + ApiJobPath + j.Id + "/executions/" + runID + "/log/"
+
+	resp, err := http.Get(logURL + "?follow=true")
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	a.NoError(err)
+	a.Equal("one\n", line)
+	select {
+	case <-done:
+		a.Fail("The log should be followed while the run is in progress")
+	default:
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal("two\n", string(body))
+
+	<-done
+	resp, err = http.Get(logURL)
+	a.NoError(err)
+	body, err = ioutil.ReadAll(resp.Body)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal("one\ntwo\n", string(body))
+
+	resp, err = http.Get(ts.URL + ApiJobPath + "not-the-job/executions/" + runID + "/log/")
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (a *ApiTestSuite) TestHandleAsyncJobCallback() {
+	tokens := make(chan string, 1)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.Header.Get(job.CallbackTokenHeader)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer remote.Close()
+
+	cache := job.NewLockFreeJobCache(job.NewMemoryDB())
+	j := job.GetMockRemoteJob(job.RemoteProperties{Url: remote.URL, Async: true})
+	j.Id = "async"
+	a.NoError(cache.Set(j))
+	done := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(done)
+	}()
+	token := <-tokens
+
+	var running []*job.JobStat
+	for i := 0; i < 100 && (len(running) == 0 || running[0].Status != job.Status.Running); i++ {
+		time.Sleep(10 * time.Millisecond)
+		running = job.RunningStats(j.Id)
+	}
+	if !a.Len(running, 1) {
+		return
+	}
+	runID := running[0].Id
+
+	r := mux.NewRouter()
+	r.HandleFunc(ApiJobPath+"{job_id}/executions/{id}/", HandleJobRunRequest(cache)).Methods("GET", "PUT")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	runURL := ts.URL + ApiJobPath + j.Id + "/executions/" + runID + "/"
+
+	callback := func(token string, body string) int {
+		_, req := setupTestReq(a.T(), "PUT", runURL, []byte(body))
+		req.Header.Set(job.CallbackTokenHeader, token)
+		resp, err := http.DefaultClient.Do(req)
+		a.NoError(err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	a.Equal(http.StatusForbidden, callback("", `"Success"`))
+	a.Equal(http.StatusForbidden, callback(token+"x", `"Success"`))
+	a.Equal(http.StatusBadRequest, callback(token, `"Skipped"`))
+	a.Equal(http.StatusNoContent, callback(token, `{"status": "Success", "output": "done", "result": {"rows": 3}}`))
+	<-done
+	a.Equal(http.StatusConflict, callback(token, `"Failed"`))
+
+	resp, err := http.Get(runURL)
+	a.NoError(err)
+	var runResp JobRunResponse
+	unmarshallRequestBody(a.T(), resp, &runResp)
+	a.Equal(job.Status.Success, runResp.JobRun.Status)
+	a.Equal("done", runResp.JobRun.Output)
+	a.JSONEq(`{"rows": 3}`, string(runResp.JobRun.Result))
+
+	// Only through its own job's URL.
+	otherURL := ts.URL + ApiJobPath + "not-the-job/executions/" + runID + "/"
+	resp, err = http.Get(otherURL)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+	_, req := setupTestReq(a.T(), "PUT", otherURL, []byte(`"Failed"`))
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
 func (a *ApiTestSuite) TestSetupApiRoutes() {
 	cache := job.NewMockCache()
 	r := mux.NewRouter()
@@ -599,6 +849,259 @@ func (a *ApiTestSuite) TestSetupApiRoutes() {
 }
 
 // setupTestReq constructs the writer recorder and request obj for use in tests
+func (a *ApiTestSuite) TestCalendarRequests() {
+	cache := job.NewMockCache()
+	r := mux.NewRouter()
+	r.HandleFunc(ApiCalendarPath, HandleAddCalendar(cache)).Methods("POST")
+	r.HandleFunc(ApiCalendarPath, HandleListCalendarsRequest(cache)).Methods("GET")
+	r.HandleFunc(ApiCalendarPath+"{name}/", HandleCalendarRequest(cache)).Methods("DELETE", "GET", "PUT")
+	r.HandleFunc(ApiCalendarPath+"{name}/holidays/", HandleCalendarHolidaysRequest(cache)).Methods("PUT")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	calendarJSON := []byte(`{"name": "maintenance", "weekly": [{"days": ["sun"], "start": "02:00", "end": "04:00"}]}`)
+	_, req := setupTestReq(a.T(), "POST", ts.URL+ApiCalendarPath, calendarJSON)
+	resp, err := http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusCreated, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiCalendarPath, calendarJSON)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusConflict, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiCalendarPath, []byte(`{"name": "bad", "weekly": [{"days": ["sun"]}]}`))
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20201225\r\nSUMMARY:Christmas\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	_, req = setupTestReq(a.T(), "PUT", ts.URL+ApiCalendarPath+"maintenance/holidays/", []byte(ics))
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+
+	var calendarResp CalendarResponse
+	unmarshallRequestBody(a.T(), resp, &calendarResp)
+	a.Len(calendarResp.Calendar.Weekly, 1)
+	if a.Len(calendarResp.Calendar.Holidays, 1) {
+		a.Equal("2020-12-25", calendarResp.Calendar.Holidays[0].Date)
+	}
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiCalendarPath, nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+
+	var listResp ListCalendarsResponse
+	unmarshallRequestBody(a.T(), resp, &listResp)
+	a.Len(listResp.Calendars, 1)
+
+	_, req = setupTestReq(a.T(), "DELETE", ts.URL+ApiCalendarPath+"maintenance/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNoContent, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiCalendarPath+"maintenance/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (a *ApiTestSuite) TestResourcePoolRequests() {
+	cache := job.NewMockCache()
+	r := mux.NewRouter()
+	r.HandleFunc(ApiResourcePoolPath, HandleAddResourcePool(cache)).Methods("POST")
+	r.HandleFunc(ApiResourcePoolPath, HandleListResourcePoolsRequest(cache)).Methods("GET")
+	r.HandleFunc(ApiResourcePoolPath+"{name}/", HandleResourcePoolRequest(cache)).Methods("DELETE", "GET", "PUT")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	poolJSON := []byte(`{"name": "reporting-db", "slots": 2}`)
+	_, req := setupTestReq(a.T(), "POST", ts.URL+ApiResourcePoolPath, poolJSON)
+	resp, err := http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusCreated, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiResourcePoolPath, poolJSON)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusConflict, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiResourcePoolPath, []byte(`{"name": "bad", "slots": 0}`))
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "PUT", ts.URL+ApiResourcePoolPath+"reporting-db/", []byte(`{"slots": 3}`))
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+
+	var poolResp ResourcePoolResponse
+	unmarshallRequestBody(a.T(), resp, &poolResp)
+	a.Equal(&job.ResourcePool{Name: "reporting-db", Slots: 3}, poolResp.Pool)
+	a.Equal(0, poolResp.InUse)
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiResourcePoolPath, nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+
+	var listResp ListResourcePoolsResponse
+	unmarshallRequestBody(a.T(), resp, &listResp)
+	a.Len(listResp.Pools, 1)
+
+	_, req = setupTestReq(a.T(), "DELETE", ts.URL+ApiResourcePoolPath+"reporting-db/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNoContent, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiResourcePoolPath+"reporting-db/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (a *ApiTestSuite) TestDeleteResourcePoolInUse() {
+	cache := job.NewLockFreeJobCache(job.NewMemoryDB())
+	r := mux.NewRouter()
+	r.HandleFunc(ApiResourcePoolPath+"{name}/", HandleResourcePoolRequest(cache)).Methods("DELETE")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	a.NoError(cache.SaveResourcePool(&job.ResourcePool{Name: "reporting-db", Slots: 1}))
+	j := job.GetMockJob()
+	j.Pools = []job.PoolClaim{{Name: "reporting-db"}}
+	a.NoError(cache.Set(j))
+
+	_, req := setupTestReq(a.T(), "DELETE", ts.URL+ApiResourcePoolPath+"reporting-db/", nil)
+	resp, err := http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusConflict, resp.StatusCode)
+
+	_, err = cache.GetResourcePool("reporting-db")
+	a.NoError(err)
+}
+
+func (a *ApiTestSuite) TestWorkflowRequests() {
+	cache := job.NewLockFreeJobCache(job.NewMemoryDB())
+	r := mux.NewRouter()
+	r.HandleFunc(ApiWorkflowPath, HandleAddWorkflow(cache)).Methods("POST")
+	r.HandleFunc(ApiWorkflowPath, HandleListWorkflowsRequest(cache)).Methods("GET")
+	r.HandleFunc(ApiWorkflowPath+"{name}/", HandleWorkflowRequest(cache)).Methods("DELETE", "GET", "PUT")
+	r.HandleFunc(ApiWorkflowPath+"{name}/runs/", HandleWorkflowRunsRequest(cache)).Methods("GET", "POST")
+	r.HandleFunc(ApiWorkflowPath+"{name}/runs/{id}/", HandleWorkflowRunRequest(cache)).Methods("GET")
+	r.HandleFunc(ApiWorkflowPath+"{name}/runs/{id}/rerun/", HandleRerunWorkflowRequest(cache)).Methods("POST")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	j := job.GetMockJob()
+	j.Schedule = fmt.Sprintf("R/%s/PT1H", time.Now().Add(time.Hour).Format(time.RFC3339))
+	a.NoError(j.Init(cache))
+
+	_, req := setupTestReq(a.T(), "POST", ts.URL+ApiWorkflowPath,
+		[]byte(`{"name": "nightly", "nodes": [{"name": "a", "job": "missing"}]}`))
+	resp, err := http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	workflowJSON := fmt.Sprintf(`{"name": "nightly", "nodes": [{"name": "a", "job": "%[1]s"}, {"name": "b", "job": "%[1]s"}],
+		"edges": [{"from": "a", "to": "b"}]}`, j.Id)
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiWorkflowPath, []byte(workflowJSON))
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusCreated, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiWorkflowPath, []byte(workflowJSON))
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusConflict, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiWorkflowPath, nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	var listResp ListWorkflowsResponse
+	unmarshallRequestBody(a.T(), resp, &listResp)
+	a.Len(listResp.Workflows, 1)
+
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiWorkflowPath+"nightly/runs/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusCreated, resp.StatusCode)
+	var runResp WorkflowRunResponse
+	unmarshallRequestBody(a.T(), resp, &runResp)
+	a.Equal(job.Status.Running, runResp.Run.Status)
+	runPath := ts.URL + ApiWorkflowPath + "nightly/runs/" + runResp.Run.Id + "/"
+
+	for i := 0; i < 100 && runResp.Run.Status == job.Status.Running; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, req = setupTestReq(a.T(), "GET", runPath, nil)
+		resp, err = http.DefaultClient.Do(req)
+		a.NoError(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		unmarshallRequestBody(a.T(), resp, &runResp)
+	}
+	a.Equal(job.Status.Success, runResp.Run.Status)
+	if a.Contains(runResp.Stats, "b") {
+		a.Equal(runResp.Run.Id, runResp.Stats["b"].WorkflowRunId)
+		a.Equal(job.Status.Success, runResp.Stats["b"].Status)
+	}
+
+	_, req = setupTestReq(a.T(), "POST", runPath+"rerun/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusCreated, resp.StatusCode)
+	var rerunResp WorkflowRunResponse
+	unmarshallRequestBody(a.T(), resp, &rerunResp)
+	a.Equal(runResp.Run.Id, rerunResp.Run.RerunOf)
+
+	_, req = setupTestReq(a.T(), "POST", ts.URL+ApiWorkflowPath+"nightly/runs/missing/rerun/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+
+	_, req = setupTestReq(a.T(), "GET", ts.URL+ApiWorkflowPath+"nightly/runs/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	var runsResp ListWorkflowRunsResponse
+	unmarshallRequestBody(a.T(), resp, &runsResp)
+	a.Len(runsResp.Runs, 2)
+
+	// A workflow can only be deleted once its runs are done, as they're deleted with it.
+	rerunPath := ts.URL + ApiWorkflowPath + "nightly/runs/" + rerunResp.Run.Id + "/"
+	for i := 0; i < 100 && rerunResp.Run.Status == job.Status.Running; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, req = setupTestReq(a.T(), "GET", rerunPath, nil)
+		resp, err = http.DefaultClient.Do(req)
+		a.NoError(err)
+		unmarshallRequestBody(a.T(), resp, &rerunResp)
+	}
+	_, req = setupTestReq(a.T(), "DELETE", ts.URL+ApiWorkflowPath+"nightly/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusNoContent, resp.StatusCode)
+
+	_, err = cache.GetWorkflowRun(runResp.Run.Id)
+	a.Equal(job.ErrWorkflowRunNotFound(runResp.Run.Id), err)
+}
+
 func setupTestReq(t assert.TestingT, method, path string, data []byte) (*httptest.ResponseRecorder, *http.Request) {
 	w := httptest.NewRecorder()
 	req, err := http.NewRequest(method, path, bytes.NewReader(data))
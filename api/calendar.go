@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nextiva/nextkala/job"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	CalendarPath    = "calendar/"
+	ApiCalendarPath = ApiUrlPrefix + CalendarPath
+)
+
+var errCalendarExists = errors.New("A calendar with that name already exists")
+
+type CalendarResponse struct {
+	Calendar *job.Calendar `json:"calendar"`
+}
+
+type ListCalendarsResponse struct {
+	Calendars []*job.Calendar `json:"calendars"`
+}
+
+func unmarshalCalendar(r *http.Request) (*job.Calendar, error) {
+	c := &job.Calendar{}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		log.Errorf("Error occurred when reading r.Body: %s", err)
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, c); err != nil {
+		log.Errorf("Error occurred when unmarshaling data: %s", err)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func handleGetCalendar(w http.ResponseWriter, status int, c *job.Calendar) {
+	resp := &CalendarResponse{
+		Calendar: c,
+	}
+
+	w.Header().Set(contentType, jsonContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Error occurred when marshaling response: %s", err)
+		return
+	}
+}
+
+// calendarErrorStatus is the status code to respond with for an error from the cache.
+func calendarErrorStatus(err error) int {
+	if _, ok := err.(job.ErrCalendarNotFound); ok {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleAddCalendar takes a calendar object and saves it, provided no calendar has its name yet.
+// POST /api/v1/calendar/
+func HandleAddCalendar(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := unmarshalCalendar(r)
+		if err != nil {
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		if existing, err := cache.GetCalendar(c.Name); err == nil && existing != nil {
+			errorEncodeJSON(errCalendarExists, http.StatusConflict, w)
+			return
+		}
+
+		if err := cache.SaveCalendar(c); err != nil {
+			log.Errorf("Error occurred when saving calendar %s: %s", c.Name, err)
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		handleGetCalendar(w, http.StatusCreated, c)
+	}
+}
+
+// HandleListCalendarsRequest responds with all of the calendars.
+// GET /api/v1/calendar/
+func HandleListCalendarsRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		calendars, err := cache.GetAllCalendars()
+		if err != nil {
+			errorEncodeJSON(err, http.StatusInternalServerError, w)
+			return
+		}
+
+		resp := &ListCalendarsResponse{
+			Calendars: calendars,
+		}
+
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Errorf("Error occurred when marshaling response: %s", err)
+			return
+		}
+	}
+}
+
+// HandleCalendarRequest routes requests to /api/v1/calendar/{name}/ to get the calendar on a GET,
+// replace it on a PUT or delete it on a DELETE.
+func HandleCalendarRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		c, err := cache.GetCalendar(name)
+		if err != nil {
+			log.Errorf("Error occurred when trying to get calendar %s: %v", name, err)
+			errorEncodeJSON(err, calendarErrorStatus(err), w)
+			return
+		}
+
+		switch r.Method {
+		case httpDelete:
+			if err := cache.DeleteCalendar(name); err != nil {
+				errorEncodeJSON(err, calendarErrorStatus(err), w)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case httpGet:
+			handleGetCalendar(w, http.StatusOK, c)
+		case httpPut:
+			updated, err := unmarshalCalendar(r)
+			if err != nil {
+				errorEncodeJSON(err, http.StatusBadRequest, w)
+				return
+			}
+
+			updated.Name = name
+			if err := cache.SaveCalendar(updated); err != nil {
+				log.Errorf("Error occurred when saving calendar %s: %s", name, err)
+				errorEncodeJSON(err, http.StatusBadRequest, w)
+				return
+			}
+
+			handleGetCalendar(w, http.StatusOK, updated)
+		}
+	}
+}
+
+// HandleCalendarHolidaysRequest replaces a calendar's holidays with the events of the
+// iCalendar file in the request body.
+// PUT /api/v1/calendar/{name}/holidays/
+func HandleCalendarHolidaysRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		c, err := cache.GetCalendar(name)
+		if err != nil {
+			log.Errorf("Error occurred when trying to get calendar %s: %v", name, err)
+			errorEncodeJSON(err, calendarErrorStatus(err), w)
+			return
+		}
+
+		defer r.Body.Close()
+		holidays, err := job.ParseICalendar(io.LimitReader(r.Body, 1048576), c.Timezone)
+		if err != nil {
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		c.Holidays = holidays
+		if err := cache.SaveCalendar(c); err != nil {
+			log.Errorf("Error occurred when saving calendar %s: %s", name, err)
+			errorEncodeJSON(err, http.StatusInternalServerError, w)
+			return
+		}
+
+		handleGetCalendar(w, http.StatusOK, c)
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nextiva/nextkala/job"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ResourcePoolPath    = "pool/"
+	ApiResourcePoolPath = ApiUrlPrefix + ResourcePoolPath
+)
+
+var errResourcePoolExists = errors.New("A resource pool with that name already exists")
+
+type ResourcePoolResponse struct {
+	Pool *job.ResourcePool `json:"pool"`
+	// Slots of the pool taken by runs in progress.
+	InUse int `json:"in_use"`
+}
+
+type ListResourcePoolsResponse struct {
+	Pools []*job.ResourcePool `json:"pools"`
+	// Slots of each pool taken by runs in progress, by name.
+	InUse map[string]int `json:"in_use"`
+}
+
+func unmarshalResourcePool(r *http.Request) (*job.ResourcePool, error) {
+	p := &job.ResourcePool{}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		log.Errorf("Error occurred when reading r.Body: %s", err)
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, p); err != nil {
+		log.Errorf("Error occurred when unmarshaling data: %s", err)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func handleGetResourcePool(w http.ResponseWriter, status int, p *job.ResourcePool) {
+	resp := &ResourcePoolResponse{
+		Pool:  p,
+		InUse: job.ResourcePoolUsage()[p.Name],
+	}
+
+	w.Header().Set(contentType, jsonContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Error occurred when marshaling response: %s", err)
+		return
+	}
+}
+
+// resourcePoolErrorStatus is the status code to respond with for an error from the cache.
+func resourcePoolErrorStatus(err error) int {
+	if _, ok := err.(job.ErrResourcePoolNotFound); ok {
+		return http.StatusNotFound
+	}
+	if err == job.ErrResourcePoolInUse {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleAddResourcePool takes a resource pool object and saves it, provided no pool has its name yet.
+// POST /api/v1/pool/
+func HandleAddResourcePool(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := unmarshalResourcePool(r)
+		if err != nil {
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		if existing, err := cache.GetResourcePool(p.Name); err == nil && existing != nil {
+			errorEncodeJSON(errResourcePoolExists, http.StatusConflict, w)
+			return
+		}
+
+		if err := cache.SaveResourcePool(p); err != nil {
+			log.Errorf("Error occurred when saving resource pool %s: %s", p.Name, err)
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		handleGetResourcePool(w, http.StatusCreated, p)
+	}
+}
+
+// HandleListResourcePoolsRequest responds with all of the resource pools.
+// GET /api/v1/pool/
+func HandleListResourcePoolsRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pools, err := cache.GetAllResourcePools()
+		if err != nil {
+			errorEncodeJSON(err, http.StatusInternalServerError, w)
+			return
+		}
+
+		resp := &ListResourcePoolsResponse{
+			Pools: pools,
+			InUse: job.ResourcePoolUsage(),
+		}
+
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Errorf("Error occurred when marshaling response: %s", err)
+			return
+		}
+	}
+}
+
+// HandleResourcePoolRequest routes requests to /api/v1/pool/{name}/ to get the resource pool on a GET,
+// replace it on a PUT or delete it on a DELETE.
+func HandleResourcePoolRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		p, err := cache.GetResourcePool(name)
+		if err != nil {
+			log.Errorf("Error occurred when trying to get resource pool %s: %v", name, err)
+			errorEncodeJSON(err, resourcePoolErrorStatus(err), w)
+			return
+		}
+
+		switch r.Method {
+		case httpDelete:
+			if err := cache.DeleteResourcePool(name); err != nil {
+				errorEncodeJSON(err, resourcePoolErrorStatus(err), w)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case httpGet:
+			handleGetResourcePool(w, http.StatusOK, p)
+		case httpPut:
+			updated, err := unmarshalResourcePool(r)
+			if err != nil {
+				errorEncodeJSON(err, http.StatusBadRequest, w)
+				return
+			}
+
+			updated.Name = name
+			if err := cache.SaveResourcePool(updated); err != nil {
+				log.Errorf("Error occurred when saving resource pool %s: %s", name, err)
+				errorEncodeJSON(err, http.StatusBadRequest, w)
+				return
+			}
+
+			handleGetResourcePool(w, http.StatusOK, updated)
+		}
+	}
+}
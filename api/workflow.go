@@ -0,0 +1,278 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nextiva/nextkala/job"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	WorkflowPath    = "workflow/"
+	ApiWorkflowPath = ApiUrlPrefix + WorkflowPath
+)
+
+var errWorkflowExists = errors.New("A workflow with that name already exists")
+
+type WorkflowResponse struct {
+	Workflow *job.Workflow `json:"workflow"`
+}
+
+type ListWorkflowsResponse struct {
+	Workflows []*job.Workflow `json:"workflows"`
+}
+
+type WorkflowRunResponse struct {
+	Run *job.WorkflowRun `json:"run"`
+	// Stats of the runs of the nodes' jobs, by node name, when getting a single workflow run.
+	Stats map[string]*job.JobStat `json:"stats,omitempty"`
+}
+
+type ListWorkflowRunsResponse struct {
+	Runs []*job.WorkflowRun `json:"runs"`
+}
+
+func unmarshalWorkflow(r *http.Request) (*job.Workflow, error) {
+	wf := &job.Workflow{}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		log.Errorf("Error occurred when reading r.Body: %s", err)
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, wf); err != nil {
+		log.Errorf("Error occurred when unmarshaling data: %s", err)
+		return nil, err
+	}
+
+	return wf, nil
+}
+
+func handleGetWorkflow(w http.ResponseWriter, status int, wf *job.Workflow) {
+	resp := &WorkflowResponse{
+		Workflow: wf,
+	}
+
+	w.Header().Set(contentType, jsonContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Error occurred when marshaling response: %s", err)
+		return
+	}
+}
+
+func handleGetWorkflowRun(w http.ResponseWriter, status int, resp *WorkflowRunResponse) {
+	w.Header().Set(contentType, jsonContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Error occurred when marshaling response: %s", err)
+		return
+	}
+}
+
+// workflowErrorStatus is the status code to respond with for an error from the cache,
+// or from starting a workflow run.
+func workflowErrorStatus(err error) int {
+	switch err.(type) {
+	case job.ErrWorkflowNotFound, job.ErrWorkflowRunNotFound:
+		return http.StatusNotFound
+	}
+	switch err {
+	case job.ErrWorkflowRunNotWorkflow:
+		return http.StatusNotFound
+	case job.ErrWorkflowRunInProgress:
+		return http.StatusConflict
+	case job.ErrJobDoesntExist:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleAddWorkflow takes a workflow object and saves it, provided no workflow has its name yet.
+// POST /api/v1/workflow/
+func HandleAddWorkflow(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wf, err := unmarshalWorkflow(r)
+		if err != nil {
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		if existing, err := cache.GetWorkflow(wf.Name); err == nil && existing != nil {
+			errorEncodeJSON(errWorkflowExists, http.StatusConflict, w)
+			return
+		}
+
+		if err := cache.SaveWorkflow(wf); err != nil {
+			log.Errorf("Error occurred when saving workflow %s: %s", wf.Name, err)
+			errorEncodeJSON(err, http.StatusBadRequest, w)
+			return
+		}
+
+		handleGetWorkflow(w, http.StatusCreated, wf)
+	}
+}
+
+// HandleListWorkflowsRequest responds with all of the workflows.
+// GET /api/v1/workflow/
+func HandleListWorkflowsRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		workflows, err := cache.GetAllWorkflows()
+		if err != nil {
+			errorEncodeJSON(err, http.StatusInternalServerError, w)
+			return
+		}
+
+		resp := &ListWorkflowsResponse{
+			Workflows: workflows,
+		}
+
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Errorf("Error occurred when marshaling response: %s", err)
+			return
+		}
+	}
+}
+
+// HandleWorkflowRequest routes requests to /api/v1/workflow/{name}/ to get the workflow on a GET,
+// replace it on a PUT or delete it on a DELETE.
+func HandleWorkflowRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		wf, err := cache.GetWorkflow(name)
+		if err != nil {
+			log.Errorf("Error occurred when trying to get workflow %s: %v", name, err)
+			errorEncodeJSON(err, workflowErrorStatus(err), w)
+			return
+		}
+
+		switch r.Method {
+		case httpDelete:
+			if err := cache.DeleteWorkflow(name); err != nil {
+				errorEncodeJSON(err, workflowErrorStatus(err), w)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case httpGet:
+			handleGetWorkflow(w, http.StatusOK, wf)
+		case httpPut:
+			updated, err := unmarshalWorkflow(r)
+			if err != nil {
+				errorEncodeJSON(err, http.StatusBadRequest, w)
+				return
+			}
+
+			updated.Name = name
+			if err := cache.SaveWorkflow(updated); err != nil {
+				log.Errorf("Error occurred when saving workflow %s: %s", name, err)
+				errorEncodeJSON(err, http.StatusBadRequest, w)
+				return
+			}
+
+			handleGetWorkflow(w, http.StatusOK, updated)
+		}
+	}
+}
+
+// HandleWorkflowRunsRequest starts a run of the workflow on a POST, and responds with its runs on a GET.
+// /api/v1/workflow/{name}/runs/
+func HandleWorkflowRunsRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		if r.Method == httpPost {
+			run, err := job.StartWorkflow(cache, name)
+			if err != nil {
+				log.Errorf("Error occurred when starting workflow %s: %v", name, err)
+				errorEncodeJSON(err, workflowErrorStatus(err), w)
+				return
+			}
+			handleGetWorkflowRun(w, http.StatusCreated, &WorkflowRunResponse{Run: run})
+			return
+		}
+
+		if _, err := cache.GetWorkflow(name); err != nil {
+			errorEncodeJSON(err, workflowErrorStatus(err), w)
+			return
+		}
+		runs, err := cache.GetWorkflowRuns(name)
+		if err != nil {
+			errorEncodeJSON(err, http.StatusInternalServerError, w)
+			return
+		}
+
+		resp := &ListWorkflowRunsResponse{
+			Runs: runs,
+		}
+
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Errorf("Error occurred when marshaling response: %s", err)
+			return
+		}
+	}
+}
+
+// HandleWorkflowRunRequest responds with a run of the workflow, and the stats of its nodes' jobs' runs.
+// GET /api/v1/workflow/{name}/runs/{id}/
+func HandleWorkflowRunRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, id := mux.Vars(r)["name"], mux.Vars(r)["id"]
+
+		run, err := cache.GetWorkflowRun(id)
+		if err == nil && run.Workflow != name {
+			err = job.ErrWorkflowRunNotWorkflow
+		}
+		if err != nil {
+			errorEncodeJSON(err, workflowErrorStatus(err), w)
+			return
+		}
+
+		resp := &WorkflowRunResponse{
+			Run:   run,
+			Stats: make(map[string]*job.JobStat, len(run.Nodes)),
+		}
+		for node, nodeRun := range run.Nodes {
+			if nodeRun.RunId == "" {
+				continue
+			}
+			stat, err := cache.GetRun(nodeRun.RunId)
+			if err != nil {
+				log.Errorf("Error occurred when trying to get run %s of workflow %s node %s: %v",
+					nodeRun.RunId, name, node, err)
+				continue
+			}
+			resp.Stats[node] = stat
+		}
+
+		handleGetWorkflowRun(w, http.StatusOK, resp)
+	}
+}
+
+// HandleRerunWorkflowRequest starts a new run of the workflow, as a re-run of one of its finished runs.
+// POST /api/v1/workflow/{name}/runs/{id}/rerun/
+func HandleRerunWorkflowRequest(cache job.JobCache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, id := mux.Vars(r)["name"], mux.Vars(r)["id"]
+
+		run, err := job.RerunWorkflow(cache, name, id)
+		if err != nil {
+			log.Errorf("Error occurred when re-running workflow %s run %s: %v", name, id, err)
+			errorEncodeJSON(err, workflowErrorStatus(err), w)
+			return
+		}
+
+		handleGetWorkflowRun(w, http.StatusCreated, &WorkflowRunResponse{Run: run})
+	}
+}
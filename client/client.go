@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nextiva/nextkala/api"
 	"github.com/nextiva/nextkala/job"
@@ -23,9 +25,23 @@ var (
 	ErrJobNotFound      = errors.New("Job not found")
 	ErrJobCreationError = errors.New("Error creating job")
 
+	ErrCalendarNotFound      = errors.New("Calendar not found")
+	ErrCalendarCreationError = errors.New("Error creating calendar")
+
+	ErrResourcePoolNotFound      = errors.New("Resource pool not found")
+	ErrResourcePoolCreationError = errors.New("Error creating resource pool")
+
+	ErrWorkflowNotFound      = errors.New("Workflow not found")
+	ErrWorkflowCreationError = errors.New("Error creating workflow")
+	ErrWorkflowRunNotFound   = errors.New("Workflow run not found")
+
 	ErrGenericError = errors.New("An error occurred performing your request")
 
-	jobPath = api.JobPath[:len(api.JobPath)-1]
+	jobPath             = api.JobPath[:len(api.JobPath)-1]
+	schedulePreviewPath = api.SchedulePreviewPath[:len(api.SchedulePreviewPath)-1]
+	calendarPath        = api.CalendarPath[:len(api.CalendarPath)-1]
+	resourcePoolPath    = api.ResourcePoolPath[:len(api.ResourcePoolPath)-1]
+	workflowPath        = api.WorkflowPath[:len(api.WorkflowPath)-1]
 )
 
 // KalaClient is the base struct for this package.
@@ -102,6 +118,13 @@ func (kc *KalaClient) do(method, url string, expectedStatus int, payload, target
 //			Command:  "bash -c 'date'",
 //		}
 //		id, err := c.CreateJob(body)
+//
+// A cron expression may be given in CronSchedule instead of Schedule:
+// 		body := &job.Job{
+//			CronSchedule: "30 9 * * MON-FRI",
+//			Name:         "test_job",
+//			Command:      "bash -c 'date'",
+//		}
 func (kc *KalaClient) CreateJob(body *job.Job) (string, error) {
 	id := &api.AddJobResponse{}
 	_, err := kc.do(methodPost, kc.url(jobPath), http.StatusCreated, body, id)
@@ -193,6 +216,20 @@ func (kc *KalaClient) StartJob(id string) (bool, error) {
 	return true, nil
 }
 
+// CancelRun is used to cancel a Job's run in progress, by the IDs of the Job and the run.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		id := "93b65499-b211-49ce-57e0-19e735cc5abd"
+//		runID := "0bd8d9c5-4b14-4b3a-6e1c-8cf6b5d3f0a4"
+//		err := c.CancelRun(id, runID)
+func (kc *KalaClient) CancelRun(id, runID string) error {
+	status, err := kc.do(methodPost, kc.url(jobPath, id, "executions", runID, "cancel"), http.StatusNoContent, nil, nil)
+	if err == ErrGenericError {
+		return fmt.Errorf("Cancel failed with a status code of %d", status)
+	}
+	return err
+}
+
 // GetKalaStats retrieves system-level metrics about Kala
 // Example:
 // 		c := New("http://127.0.0.1:8000")
@@ -234,3 +271,281 @@ func (kc *KalaClient) EnableJob(id string) (bool, error) {
 	}
 	return true, nil
 }
+
+// GetNextRuns returns the times of a Job's next count runs, or fewer if it doesn't have that many left.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		id := "93b65499-b211-49ce-57e0-19e735cc5abd"
+//		runs, err := c.GetNextRuns(id, 5)
+func (kc *KalaClient) GetNextRuns(id string, count int) ([]time.Time, error) {
+	runs := &api.NextRunsResponse{}
+	url := kc.url(jobPath, id, "next-runs") + "?count=" + strconv.Itoa(count)
+	_, err := kc.do(methodGet, url, http.StatusOK, nil, runs)
+	if err != nil {
+		if err == ErrGenericError {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return runs.NextRuns, nil
+}
+
+// PreviewSchedule returns the times of the first count runs of a Job, without creating it.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+// 		body := &job.Job{
+//			Schedule: "R/2015-06-04T19:25:16.828696-07:00/P1M",
+//		}
+//		runs, err := c.PreviewSchedule(body, 12)
+func (kc *KalaClient) PreviewSchedule(body *job.Job, count int) ([]time.Time, error) {
+	runs := &api.NextRunsResponse{}
+	url := kc.url(schedulePreviewPath) + "?count=" + strconv.Itoa(count)
+	status, err := kc.do(methodPost, url, http.StatusOK, body, runs)
+	if err != nil {
+		if err == ErrGenericError {
+			return nil, fmt.Errorf("Preview failed with a status code of %d", status)
+		}
+		return nil, err
+	}
+	return runs.NextRuns, nil
+}
+
+// CreateCalendar is used for creating a new blackout calendar, which jobs can then
+// name in their Calendars.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+// 		body := &job.Calendar{
+//			Name: "maintenance",
+//			Weekly: []job.WeeklyWindow{
+//				{Days: []string{"sun"}, Start: "02:00", End: "04:00"},
+//			},
+//		}
+//		err := c.CreateCalendar(body)
+func (kc *KalaClient) CreateCalendar(body *job.Calendar) error {
+	_, err := kc.do(methodPost, kc.url(calendarPath), http.StatusCreated, body, nil)
+	if err == ErrGenericError {
+		return ErrCalendarCreationError
+	}
+	return err
+}
+
+// GetCalendar is used to retrieve a blackout calendar by its name.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		calendar, err := c.GetCalendar("maintenance")
+func (kc *KalaClient) GetCalendar(name string) (*job.Calendar, error) {
+	resp := &api.CalendarResponse{}
+	_, err := kc.do(methodGet, kc.url(calendarPath, name), http.StatusOK, nil, resp)
+	if err != nil {
+		if err == ErrGenericError {
+			return nil, ErrCalendarNotFound
+		}
+		return nil, err
+	}
+	return resp.Calendar, nil
+}
+
+// GetAllCalendars returns all of the blackout calendars.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		calendars, err := c.GetAllCalendars()
+func (kc *KalaClient) GetAllCalendars() ([]*job.Calendar, error) {
+	resp := &api.ListCalendarsResponse{}
+	_, err := kc.do(methodGet, kc.url(calendarPath), http.StatusOK, nil, resp)
+	return resp.Calendars, err
+}
+
+// DeleteCalendar is used to delete a blackout calendar by its name.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		ok, err := c.DeleteCalendar("maintenance")
+func (kc *KalaClient) DeleteCalendar(name string) (bool, error) {
+	status, err := kc.do(methodDelete, kc.url(calendarPath, name), http.StatusNoContent, nil, nil)
+	if err != nil {
+		if err == ErrGenericError {
+			return false, fmt.Errorf("Delete failed with a status code of %d", status)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateResourcePool is used for creating a new resource pool, which jobs can then
+// claim slots of in their Pools.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+// 		body := &job.ResourcePool{
+//			Name:  "reporting-db",
+//			Slots: 2,
+//		}
+//		err := c.CreateResourcePool(body)
+func (kc *KalaClient) CreateResourcePool(body *job.ResourcePool) error {
+	_, err := kc.do(methodPost, kc.url(resourcePoolPath), http.StatusCreated, body, nil)
+	if err == ErrGenericError {
+		return ErrResourcePoolCreationError
+	}
+	return err
+}
+
+// GetResourcePool is used to retrieve a resource pool by its name.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		pool, err := c.GetResourcePool("reporting-db")
+func (kc *KalaClient) GetResourcePool(name string) (*job.ResourcePool, error) {
+	resp := &api.ResourcePoolResponse{}
+	_, err := kc.do(methodGet, kc.url(resourcePoolPath, name), http.StatusOK, nil, resp)
+	if err != nil {
+		if err == ErrGenericError {
+			return nil, ErrResourcePoolNotFound
+		}
+		return nil, err
+	}
+	return resp.Pool, nil
+}
+
+// GetAllResourcePools returns all of the resource pools.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		pools, err := c.GetAllResourcePools()
+func (kc *KalaClient) GetAllResourcePools() ([]*job.ResourcePool, error) {
+	resp := &api.ListResourcePoolsResponse{}
+	_, err := kc.do(methodGet, kc.url(resourcePoolPath), http.StatusOK, nil, resp)
+	return resp.Pools, err
+}
+
+// DeleteResourcePool is used to delete a resource pool by its name.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		ok, err := c.DeleteResourcePool("reporting-db")
+func (kc *KalaClient) DeleteResourcePool(name string) (bool, error) {
+	status, err := kc.do(methodDelete, kc.url(resourcePoolPath, name), http.StatusNoContent, nil, nil)
+	if err != nil {
+		if err == ErrGenericError {
+			return false, fmt.Errorf("Delete failed with a status code of %d", status)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateWorkflow is used for creating a new workflow of existing jobs.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+// 		body := &job.Workflow{
+//			Name:  "nightly",
+//			Nodes: []job.WorkflowNode{{Name: "export", Job: exportID}, {Name: "report", Job: reportID}},
+//			Edges: []job.WorkflowEdge{{From: "export", To: "report"}},
+//		}
+//		err := c.CreateWorkflow(body)
+func (kc *KalaClient) CreateWorkflow(body *job.Workflow) error {
+	_, err := kc.do(methodPost, kc.url(workflowPath), http.StatusCreated, body, nil)
+	if err == ErrGenericError {
+		return ErrWorkflowCreationError
+	}
+	return err
+}
+
+// GetWorkflow is used to retrieve a workflow by its name.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		workflow, err := c.GetWorkflow("nightly")
+func (kc *KalaClient) GetWorkflow(name string) (*job.Workflow, error) {
+	resp := &api.WorkflowResponse{}
+	_, err := kc.do(methodGet, kc.url(workflowPath, name), http.StatusOK, nil, resp)
+	if err != nil {
+		if err == ErrGenericError {
+			return nil, ErrWorkflowNotFound
+		}
+		return nil, err
+	}
+	return resp.Workflow, nil
+}
+
+// GetAllWorkflows returns all of the workflows.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		workflows, err := c.GetAllWorkflows()
+func (kc *KalaClient) GetAllWorkflows() ([]*job.Workflow, error) {
+	resp := &api.ListWorkflowsResponse{}
+	_, err := kc.do(methodGet, kc.url(workflowPath), http.StatusOK, nil, resp)
+	return resp.Workflows, err
+}
+
+// DeleteWorkflow is used to delete a workflow by its name.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		ok, err := c.DeleteWorkflow("nightly")
+func (kc *KalaClient) DeleteWorkflow(name string) (bool, error) {
+	status, err := kc.do(methodDelete, kc.url(workflowPath, name), http.StatusNoContent, nil, nil)
+	if err != nil {
+		if err == ErrGenericError {
+			return false, fmt.Errorf("Delete failed with a status code of %d", status)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// StartWorkflow is used to start a run of a workflow. It returns the run as it starts.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		run, err := c.StartWorkflow("nightly")
+func (kc *KalaClient) StartWorkflow(name string) (*job.WorkflowRun, error) {
+	resp := &api.WorkflowRunResponse{}
+	_, err := kc.do(methodPost, kc.url(workflowPath, name, "runs"), http.StatusCreated, nil, resp)
+	if err != nil {
+		if err == ErrGenericError {
+			return nil, ErrWorkflowNotFound
+		}
+		return nil, err
+	}
+	return resp.Run, nil
+}
+
+// GetWorkflowRuns returns the runs of a workflow.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		runs, err := c.GetWorkflowRuns("nightly")
+func (kc *KalaClient) GetWorkflowRuns(name string) ([]*job.WorkflowRun, error) {
+	resp := &api.ListWorkflowRunsResponse{}
+	_, err := kc.do(methodGet, kc.url(workflowPath, name, "runs"), http.StatusOK, nil, resp)
+	if err == ErrGenericError {
+		return nil, ErrWorkflowNotFound
+	}
+	return resp.Runs, err
+}
+
+// GetWorkflowRun is used to retrieve a run of a workflow, and the stats of its nodes' jobs' runs by node name.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		run, stats, err := c.GetWorkflowRun("nightly", runID)
+func (kc *KalaClient) GetWorkflowRun(name, id string) (*job.WorkflowRun, map[string]*job.JobStat, error) {
+	resp := &api.WorkflowRunResponse{}
+	_, err := kc.do(methodGet, kc.url(workflowPath, name, "runs", id), http.StatusOK, nil, resp)
+	if err != nil {
+		if err == ErrGenericError {
+			return nil, nil, ErrWorkflowRunNotFound
+		}
+		return nil, nil, err
+	}
+	return resp.Run, resp.Stats, nil
+}
+
+// RerunWorkflow is used to start a new run of a workflow, as a re-run of one of its finished runs.
+// Example:
+// 		c := New("http://127.0.0.1:8000")
+//		rerun, err := c.RerunWorkflow("nightly", runID)
+func (kc *KalaClient) RerunWorkflow(name, id string) (*job.WorkflowRun, error) {
+	resp := &api.WorkflowRunResponse{}
+	status, err := kc.do(methodPost, kc.url(workflowPath, name, "runs", id, "rerun"), http.StatusCreated, nil, resp)
+	if err != nil {
+		if err == ErrGenericError {
+			if status == http.StatusNotFound {
+				return nil, ErrWorkflowRunNotFound
+			}
+			return nil, fmt.Errorf("Re-run failed with a status code of %d", status)
+		}
+		return nil, err
+	}
+	return resp.Run, nil
+}
@@ -79,6 +79,31 @@ func TestCreateGetDeleteJob(t *testing.T) {
 	cleanUp()
 }
 
+func TestCreateGetDeleteCronJob(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Close()
+	kc := New(ts.URL)
+	j := NewJobMap()
+	j.Schedule = ""
+	j.CronSchedule = "30 9 * * MON-FRI"
+
+	id, err := kc.CreateJob(j)
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, "")
+
+	respJob, err := kc.GetJob(id)
+	assert.NoError(t, err)
+	assert.Equal(t, j.CronSchedule, respJob.CronSchedule)
+	assert.Equal(t, "", respJob.Schedule)
+	assert.True(t, respJob.NextRunAt.After(time.Now()))
+
+	ok, err := kc.DeleteJob(id)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	cleanUp()
+}
+
 func TestCreateJobError(t *testing.T) {
 	ts := NewTestServer()
 	defer ts.Close()
@@ -268,6 +293,48 @@ func TestStartJobError(t *testing.T) {
 	assert.False(t, ok)
 }
 
+func TestCancelRun(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Close()
+	kc := New(ts.URL)
+	j := NewJobMap()
+	j.Command = "bash -c 'sleep 30'"
+
+	id, err := kc.CreateJob(j)
+	assert.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		kc.StartJob(id)
+		close(done)
+	}()
+
+	var stats []*job.JobStat
+	for i := 0; i < 100 && len(stats) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		stats, err = kc.GetJobStats(id)
+		assert.NoError(t, err)
+	}
+	if !assert.Len(t, stats, 1) {
+		return
+	}
+	assert.Equal(t, job.Status.Started, stats[0].Status)
+	assert.NoError(t, kc.CancelRun(id, stats[0].Id))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The run should have been cancelled")
+	}
+	stats, err = kc.GetJobStats(id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, job.Status.Cancelled, stats[0].Status)
+	}
+	assert.Error(t, kc.CancelRun(id, stats[0].Id))
+
+	cleanUp()
+}
+
 func TestGetKalaStats(t *testing.T) {
 	ts := NewTestServer()
 	defer ts.Close()
@@ -333,3 +400,162 @@ func TestDisableEnableJob(t *testing.T) {
 
 	cleanUp()
 }
+
+func TestGetNextRuns(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Close()
+	kc := New(ts.URL)
+	j := NewJobMap()
+
+	id, err := kc.CreateJob(j)
+	assert.NoError(t, err)
+
+	// R1 means two runs in all.
+	runs, err := kc.GetNextRuns(id, 5)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	_, err = kc.GetNextRuns("not-a-real-id", 5)
+	assert.Equal(t, ErrJobNotFound, err)
+
+	cleanUp()
+}
+
+func TestPreviewSchedule(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Close()
+	kc := New(ts.URL)
+
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	j := &job.Job{
+		Schedule: fmt.Sprintf("R/%s/P1M", start.Format(time.RFC3339)),
+	}
+
+	runs, err := kc.PreviewSchedule(j, 3)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	for i, run := range runs {
+		assert.True(t, start.AddDate(0, i, 0).Equal(run))
+	}
+
+	j.Schedule = "not a schedule"
+	_, err = kc.PreviewSchedule(j, 3)
+	assert.Error(t, err)
+
+	// Nothing was created.
+	jobs, err := kc.GetAllJobs()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 0)
+}
+
+func TestCreateGetDeleteCalendar(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Close()
+	kc := New(ts.URL)
+
+	c := &job.Calendar{
+		Name:   "maintenance",
+		Weekly: []job.WeeklyWindow{{Days: []string{"sun"}, Start: "02:00", End: "04:00"}},
+	}
+	assert.NoError(t, kc.CreateCalendar(c))
+	assert.Equal(t, ErrCalendarCreationError, kc.CreateCalendar(c))
+
+	respCalendar, err := kc.GetCalendar("maintenance")
+	assert.NoError(t, err)
+	assert.Equal(t, c.Weekly[0].Days, respCalendar.Weekly[0].Days)
+
+	calendars, err := kc.GetAllCalendars()
+	assert.NoError(t, err)
+	assert.Len(t, calendars, 1)
+
+	ok, err := kc.DeleteCalendar("maintenance")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = kc.GetCalendar("maintenance")
+	assert.Equal(t, ErrCalendarNotFound, err)
+}
+
+func TestCreateGetDeleteResourcePool(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Close()
+	kc := New(ts.URL)
+
+	p := &job.ResourcePool{Name: "reporting-db", Slots: 2}
+	assert.NoError(t, kc.CreateResourcePool(p))
+	assert.Equal(t, ErrResourcePoolCreationError, kc.CreateResourcePool(p))
+
+	respPool, err := kc.GetResourcePool("reporting-db")
+	assert.NoError(t, err)
+	assert.Equal(t, p, respPool)
+
+	pools, err := kc.GetAllResourcePools()
+	assert.NoError(t, err)
+	assert.Len(t, pools, 1)
+
+	ok, err := kc.DeleteResourcePool("reporting-db")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = kc.GetResourcePool("reporting-db")
+	assert.Equal(t, ErrResourcePoolNotFound, err)
+}
+
+func TestWorkflowRuns(t *testing.T) {
+	// Workflows run in the background, so the test server's database has to be safe to use meanwhile.
+	r := mux.NewRouter()
+	api.SetupApiRoutes(r, job.NewLockFreeJobCache(job.NewMemoryDB()), "", false, false)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	kc := New(ts.URL)
+
+	id, err := kc.CreateJob(NewJobMap())
+	assert.NoError(t, err)
+
+	w := &job.Workflow{
+		Name:  "nightly",
+		Nodes: []job.WorkflowNode{{Name: "a", Job: id}, {Name: "b", Job: id}},
+		Edges: []job.WorkflowEdge{{From: "a", To: "b", On: job.EdgeAlways}},
+	}
+	assert.NoError(t, kc.CreateWorkflow(w))
+	assert.Equal(t, ErrWorkflowCreationError, kc.CreateWorkflow(w))
+
+	respWorkflow, err := kc.GetWorkflow("nightly")
+	assert.NoError(t, err)
+	assert.Equal(t, w, respWorkflow)
+
+	workflows, err := kc.GetAllWorkflows()
+	assert.NoError(t, err)
+	assert.Len(t, workflows, 1)
+
+	run, err := kc.StartWorkflow("nightly")
+	assert.NoError(t, err)
+	for i := 0; i < 100 && run.Status == job.Status.Running; i++ {
+		time.Sleep(10 * time.Millisecond)
+		run, _, err = kc.GetWorkflowRun("nightly", run.Id)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, job.Status.Success, run.Status)
+
+	_, stats, err := kc.GetWorkflowRun("nightly", run.Id)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+
+	rerun, err := kc.RerunWorkflow("nightly", run.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, run.Id, rerun.RerunOf)
+
+	runs, err := kc.GetWorkflowRuns("nightly")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	_, err = kc.RerunWorkflow("nightly", "missing")
+	assert.Equal(t, ErrWorkflowRunNotFound, err)
+
+	_, err = kc.StartWorkflow("missing")
+	assert.Equal(t, ErrWorkflowNotFound, err)
+
+	ok, err := kc.DeleteWorkflow("nightly")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -56,6 +56,20 @@ var serveCmd = &cobra.Command{
 
 		job.InitAuth()
 		job.InitMailer()
+		job.AllowedRunAsUsers = viper.GetStringSlice("run-as-users")
+		job.MaxConcurrentRuns = viper.GetInt("max-concurrent-runs")
+		job.MaxOutputSize = viper.GetInt("max-output-size")
+		job.AllowInsecureSkipVerify = viper.GetBool("allow-insecure-skip-verify")
+		job.TLSFilesDir = viper.GetString("tls-files-dir")
+		job.DefaultTLS = job.TLSProperties{
+			CAFile:   viper.GetString("remote-ca-file"),
+			CertFile: viper.GetString("remote-cert-file"),
+			KeyFile:  viper.GetString("remote-key-file"),
+		}
+		job.DefaultProxy = viper.GetString("remote-proxy")
+		if secret := viper.GetString("callback-secret"); secret != "" {
+			job.CallbackSecret = []byte(secret)
+		}
 
 		// Create cache
 		log.Infof("Preparing cache")
@@ -93,5 +107,15 @@ func init() {
 	serveCmd.Flags().Int("jobstat-ttl", -1, "Sets the jobstat-ttl in minutes. The default -1 value indicates JobStat entries will be kept forever")
 	serveCmd.Flags().Bool("profile", false, "Activate pprof handlers")
 	serveCmd.Flags().Bool("no-delete-all", false, "Disable the delete all jobs endpoint.")
-	serveCmd.Flags().Bool("no-local-jobs", false, "Disable creating local jobs via API.")
+	serveCmd.Flags().Bool("no-local-jobs", false, "Disable creating local and script jobs via API.")
+	serveCmd.Flags().StringSlice("run-as-users", nil, "Users that local jobs may run as, with run_as. By default jobs can't switch user.")
+	serveCmd.Flags().Int("max-output-size", job.DefaultMaxOutputSize, "Most bytes of each of a run's stdout, stderr or response body to keep. Zero or less keeps it all.")
+	serveCmd.Flags().String("remote-ca-file", "", "PEM file of CA certificates to verify the servers remote jobs call with, instead of the system's.")
+	serveCmd.Flags().String("remote-cert-file", "", "PEM file of the client certificate remote jobs present, unless they set their own.")
+	serveCmd.Flags().String("remote-key-file", "", "PEM file of the key of --remote-cert-file.")
+	serveCmd.Flags().String("remote-proxy", "", "URL of the proxy remote jobs' requests go through, unless they set their own. By default it's taken from HTTPS_PROXY and HTTP_PROXY.")
+	serveCmd.Flags().String("tls-files-dir", "", "Directory that the TLS files remote jobs set must be in. By default jobs can't set their own, and use --remote-ca-file, --remote-cert-file and --remote-key-file.")
+	serveCmd.Flags().Bool("allow-insecure-skip-verify", false, "Allow remote jobs to skip verifying the certificates of the servers they call, with tls.insecure_skip_verify.")
+	serveCmd.Flags().String("callback-secret", "", "Secret to sign async remote jobs' callback tokens with. By default it's random, so tokens don't outlive the server.")
+	serveCmd.Flags().Int("max-concurrent-runs", 0, "Most job runs in progress at once; others wait, by job priority. By default there's no limit.")
 }
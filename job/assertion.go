@@ -0,0 +1,182 @@
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/nextiva/nextkala/utils/iso8601"
+	"github.com/nextiva/nextkala/utils/jsonpath"
+)
+
+// MaxAssertedBodySize is the most bytes of a response body that JSON and BodyMatches assertions look at.
+// Responses with more than that fail them.
+const MaxAssertedBodySize = 1 << 20
+
+var ErrInvalidAssertions = errors.New("Invalid Job assertions. JSON paths must be JSONPaths like $.items[0].ok, " +
+	"body_matches a regular expression and max_latency an ISO 8601 duration")
+
+// ErrAssertionFailed is returned by RemoteRun when the response fails one of the job's Assertions.
+type ErrAssertionFailed struct {
+	// e.g. "$.ok" or "header Content-Type"
+	Assertion string
+	Reason    string
+}
+
+func (e *ErrAssertionFailed) Error() string {
+	return fmt.Sprintf("Response assertion %s failed: %s", e.Assertion, e.Reason)
+}
+
+// ResponseAssertions are checks on a remote job's response, besides its status code, that it must pass
+// for the run to succeed.
+// e.g. {"json": [{"path": "$.ok", "equals": true}], "headers": {"Content-Type": "application/json"}, "max_latency": "PT5S"}
+type ResponseAssertions struct {
+	// Values the response body, as JSON, must have.
+	JSON []JSONAssertion `json:"json"`
+
+	// Regular expression the response body must match.
+	BodyMatches string `json:"body_matches"`
+
+	// Headers the response must have, with the given values. An empty value means any.
+	Headers map[string]string `json:"headers"`
+
+	// ISO 8601 Duration the response, including its body, must have taken no longer than.
+	MaxLatency string `json:"max_latency"`
+}
+
+// JSONAssertion is the value a JSON response body must have at a JSONPath.
+type JSONAssertion struct {
+	Path   string          `json:"path"`
+	Equals json.RawMessage `json:"equals"`
+}
+
+func (a *ResponseAssertions) validate() error {
+	for _, assertion := range a.JSON {
+		var expected interface{}
+		if _, err := jsonpath.Parse(assertion.Path); err != nil {
+			return ErrInvalidAssertions
+		}
+		if err := json.Unmarshal(assertion.Equals, &expected); err != nil {
+			return ErrInvalidAssertions
+		}
+	}
+	if _, err := regexp.Compile(a.BodyMatches); err != nil {
+		return ErrInvalidAssertions
+	}
+	if !validDuration(a.MaxLatency) {
+		return ErrInvalidAssertions
+	}
+	return nil
+}
+
+// needBody says whether any of the assertions are on the response body.
+func (a *ResponseAssertions) needBody() bool {
+	return len(a.JSON) != 0 || a.BodyMatches != ""
+}
+
+// check returns an *ErrAssertionFailed for the first assertion the response fails, if any.
+// body is the start of the response body, up to MaxAssertedBodySize bytes, and complete whether
+// that's all of it.
+func (a *ResponseAssertions) check(res *http.Response, body []byte, complete bool, latency time.Duration,
+	now time.Time) error {
+	if a.MaxLatency != "" {
+		if maxLatency, err := iso8601.FromString(a.MaxLatency); err == nil && latency > maxLatency.RelativeTo(now) {
+			return &ErrAssertionFailed{
+				Assertion: "max latency",
+				Reason:    fmt.Sprintf("the response took %s, more than %s", latency, a.MaxLatency),
+			}
+		}
+	}
+
+	for name, want := range a.Headers {
+		values, ok := res.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return &ErrAssertionFailed{Assertion: "header " + name, Reason: "the response doesn't have it"}
+		}
+		if want != "" && !containsString(values, want) {
+			return &ErrAssertionFailed{
+				Assertion: "header " + name,
+				Reason:    fmt.Sprintf("it's %q, expected %q", res.Header.Get(name), want),
+			}
+		}
+	}
+
+	if a.needBody() && !complete {
+		return &ErrAssertionFailed{
+			Assertion: "on the body",
+			Reason:    fmt.Sprintf("the body is more than %d bytes", MaxAssertedBodySize),
+		}
+	}
+
+	if a.BodyMatches != "" {
+		re, err := regexp.Compile(a.BodyMatches)
+		if err != nil {
+			return &ErrAssertionFailed{Assertion: "body matches " + a.BodyMatches, Reason: err.Error()}
+		}
+		if !re.Match(body) {
+			return &ErrAssertionFailed{Assertion: "body matches " + a.BodyMatches, Reason: "the body doesn't match"}
+		}
+	}
+
+	if len(a.JSON) == 0 {
+		return nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return &ErrAssertionFailed{Assertion: a.JSON[0].Path, Reason: "the body isn't JSON: " + err.Error()}
+	}
+	for _, assertion := range a.JSON {
+		if err := assertion.check(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *JSONAssertion) check(doc interface{}) error {
+	path, err := jsonpath.Parse(a.Path)
+	if err != nil {
+		return &ErrAssertionFailed{Assertion: a.Path, Reason: err.Error()}
+	}
+	var expected interface{}
+	if err := json.Unmarshal(a.Equals, &expected); err != nil {
+		return &ErrAssertionFailed{Assertion: a.Path, Reason: err.Error()}
+	}
+
+	got, ok := path.Get(doc)
+	if !ok {
+		return &ErrAssertionFailed{Assertion: a.Path, Reason: "the body has nothing there"}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		gotJSON, _ := json.Marshal(got)
+		return &ErrAssertionFailed{
+			Assertion: a.Path,
+			Reason:    fmt.Sprintf("it's %s, expected %s", gotJSON, bytes.TrimSpace(a.Equals)),
+		}
+	}
+	return nil
+}
+
+// assertedBody keeps the start of a response body, up to MaxAssertedBodySize bytes, for its assertions.
+type assertedBody struct {
+	bytes.Buffer
+	complete bool
+}
+
+func newAssertedBody() *assertedBody {
+	return &assertedBody{complete: true}
+}
+
+func (b *assertedBody) Write(p []byte) (int, error) {
+	if room := MaxAssertedBodySize - b.Len(); len(p) > room {
+		b.complete = false
+		b.Buffer.Write(p[:room])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,131 @@
+package job
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertionsErrors(t *testing.T) {
+	invalid := []ResponseAssertions{
+		{JSON: []JSONAssertion{{Path: "ok", Equals: json.RawMessage(`true`)}}},
+		{JSON: []JSONAssertion{{Path: "$.ok"}}},
+		{BodyMatches: "("},
+		{MaxLatency: "5 seconds"},
+	}
+	for _, a := range invalid {
+		j := GetMockRemoteJob(RemoteProperties{Url: "http://example.com", Assertions: a})
+		assert.Equal(t, ErrInvalidAssertions, j.validation())
+	}
+}
+
+var assertionTableTests = []struct {
+	Name       string
+	Assertions ResponseAssertions
+	// The assertion that fails, if any.
+	Failed string
+}{
+	{
+		Name: "Passing",
+		Assertions: ResponseAssertions{
+			JSON: []JSONAssertion{
+				{Path: "$.ok", Equals: json.RawMessage(`true`)},
+				{Path: "$.items[0]", Equals: json.RawMessage(`{"id": 1}`)},
+			},
+			BodyMatches: `"items":\s*\[`,
+			Headers:     map[string]string{"content-type": "application/json", "X-Request-Id": ""},
+			MaxLatency:  "PT10S",
+		},
+	},
+	{
+		Name:       "Wrong JSON value",
+		Assertions: ResponseAssertions{JSON: []JSONAssertion{{Path: "$.count", Equals: json.RawMessage(`3`)}}},
+		Failed:     "$.count",
+	},
+	{
+		Name:       "Missing JSON value",
+		Assertions: ResponseAssertions{JSON: []JSONAssertion{{Path: "$.items[1]", Equals: json.RawMessage(`null`)}}},
+		Failed:     "$.items[1]",
+	},
+	{
+		Name:       "Body doesn't match",
+		Assertions: ResponseAssertions{BodyMatches: `"ok":\s*false`},
+		Failed:     `body matches "ok":\s*false`,
+	},
+	{
+		Name:       "Missing header",
+		Assertions: ResponseAssertions{Headers: map[string]string{"ETag": ""}},
+		Failed:     "header ETag",
+	},
+	{
+		Name:       "Wrong header",
+		Assertions: ResponseAssertions{Headers: map[string]string{"Content-Type": "text/plain"}},
+		Failed:     "header Content-Type",
+	},
+	{
+		Name:       "Too slow",
+		Assertions: ResponseAssertions{MaxLatency: "PT1S"},
+		Failed:     "max latency",
+	},
+}
+
+func TestAssertions(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(1100 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "abc")
+		w.Write([]byte(`{"ok": true, "count": 2, "items": [{"id": 1}]}`)) //nolint:errcheck // Only a test server
+	}))
+	defer testServer.Close()
+
+	for _, testStruct := range assertionTableTests {
+		url := testServer.URL
+		if testStruct.Assertions.MaxLatency == "PT1S" {
+			url += "?slow=true"
+		}
+		cache := NewMockCache()
+		j := GetMockRemoteJob(RemoteProperties{Url: url, Assertions: testStruct.Assertions})
+		j.Id = "asserted"
+		assert.NoError(t, j.validation(), testStruct.Name)
+		j.Run(cache)
+
+		stats, err := cache.GetAllRuns(j.Id)
+		assert.NoError(t, err, testStruct.Name)
+		if testStruct.Failed == "" {
+			assert.Equal(t, 1, int(j.Metadata.SuccessCount), testStruct.Name)
+			continue
+		}
+		assert.Equal(t, 0, int(j.Metadata.SuccessCount), testStruct.Name)
+		if assert.Len(t, stats, 1, testStruct.Name) {
+			assert.Equal(t, Status.Failed, stats[0].Status, testStruct.Name)
+			assert.True(t, strings.HasPrefix(stats[0].Output, "Response assertion "+testStruct.Failed+" failed: "),
+				testStruct.Name+": "+stats[0].Output)
+		}
+	}
+}
+
+func TestAssertionsBodyTooLarge(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`"` + strings.Repeat("x", MaxAssertedBodySize) + `"`)) //nolint:errcheck // Only a test server
+	}))
+	defer testServer.Close()
+
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL, Assertions: ResponseAssertions{BodyMatches: "x"}})
+	j.Id = "asserted-large"
+	j.Run(cache)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Failed, stats[0].Status)
+		assert.Contains(t, stats[0].Output, "the body is more than")
+	}
+}
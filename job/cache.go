@@ -31,6 +31,21 @@ type JobCache interface {
 	GetAllRuns(jobID string) ([]*JobStat, error)
 	GetRun(runID string) (*JobStat, error)
 	ClearExpiredRuns() error
+	GetCalendar(name string) (*Calendar, error)
+	GetAllCalendars() ([]*Calendar, error)
+	SaveCalendar(c *Calendar) error
+	DeleteCalendar(name string) error
+	GetResourcePool(name string) (*ResourcePool, error)
+	GetAllResourcePools() ([]*ResourcePool, error)
+	SaveResourcePool(p *ResourcePool) error
+	DeleteResourcePool(name string) error
+	GetWorkflow(name string) (*Workflow, error)
+	GetAllWorkflows() ([]*Workflow, error)
+	SaveWorkflow(w *Workflow) error
+	DeleteWorkflow(name string) error
+	GetWorkflowRun(id string) (*WorkflowRun, error)
+	GetWorkflowRuns(workflow string) ([]*WorkflowRun, error)
+	SaveWorkflowRun(run *WorkflowRun) error
 }
 
 type JobsMap struct {
@@ -75,6 +90,8 @@ func (c *MemoryJobCache) Start() {
 			log.Errorln(err)
 		}
 	}
+	failInterruptedWorkflowRuns(c)
+	failInterruptedCallbacks(c, allJobs)
 
 	// Process-level defer for shutting down the db.
 	ch := make(chan os.Signal)
@@ -199,6 +216,66 @@ func (c *MemoryJobCache) ClearExpiredRuns() error {
 	return c.jobDB.ClearExpiredRuns()
 }
 
+func (c *MemoryJobCache) GetCalendar(name string) (*Calendar, error) {
+	return getCalendar(c.jobDB, name)
+}
+
+func (c *MemoryJobCache) GetAllCalendars() ([]*Calendar, error) {
+	return getAllCalendars(c.jobDB)
+}
+
+func (c *MemoryJobCache) SaveCalendar(cal *Calendar) error {
+	return saveCalendar(c.jobDB, cal)
+}
+
+func (c *MemoryJobCache) DeleteCalendar(name string) error {
+	return c.jobDB.DeleteCalendar(name)
+}
+
+func (c *MemoryJobCache) GetResourcePool(name string) (*ResourcePool, error) {
+	return c.jobDB.GetResourcePool(name)
+}
+
+func (c *MemoryJobCache) GetAllResourcePools() ([]*ResourcePool, error) {
+	return getAllResourcePools(c.jobDB)
+}
+
+func (c *MemoryJobCache) SaveResourcePool(p *ResourcePool) error {
+	return saveResourcePool(c.jobDB, p)
+}
+
+func (c *MemoryJobCache) DeleteResourcePool(name string) error {
+	return deleteResourcePool(c.jobDB, name)
+}
+
+func (c *MemoryJobCache) GetWorkflow(name string) (*Workflow, error) {
+	return c.jobDB.GetWorkflow(name)
+}
+
+func (c *MemoryJobCache) GetAllWorkflows() ([]*Workflow, error) {
+	return getAllWorkflows(c.jobDB)
+}
+
+func (c *MemoryJobCache) SaveWorkflow(w *Workflow) error {
+	return saveWorkflow(c, c.jobDB, w)
+}
+
+func (c *MemoryJobCache) DeleteWorkflow(name string) error {
+	return deleteWorkflow(c.jobDB, name)
+}
+
+func (c *MemoryJobCache) GetWorkflowRun(id string) (*WorkflowRun, error) {
+	return c.jobDB.GetWorkflowRun(id)
+}
+
+func (c *MemoryJobCache) GetWorkflowRuns(workflow string) ([]*WorkflowRun, error) {
+	return getWorkflowRuns(c.jobDB, workflow)
+}
+
+func (c *MemoryJobCache) SaveWorkflowRun(run *WorkflowRun) error {
+	return c.jobDB.SaveWorkflowRun(run)
+}
+
 func (c *MemoryJobCache) Persist() error {
 	c.jobs.Lock.RLock()
 	defer c.jobs.Lock.RUnlock()
@@ -233,7 +310,7 @@ func (c *LockFreeJobCache) Start(jobstatTtl time.Duration) {
 		log.Fatal(err)
 	}
 	for _, j := range allJobs {
-		if j.Schedule == "" {
+		if !j.hasSchedule() {
 			log.Infof("Job %s:%s skipped.", j.Name, j.Id)
 			continue
 		}
@@ -246,6 +323,8 @@ func (c *LockFreeJobCache) Start(jobstatTtl time.Duration) {
 			log.Errorln(err)
 		}
 	}
+	failInterruptedWorkflowRuns(c)
+	failInterruptedCallbacks(c, allJobs)
 
 	// Run retention every minute to clean up old job stats entries
 	if jobstatTtl > 0 {
@@ -374,6 +453,66 @@ func (c *LockFreeJobCache) ClearExpiredRuns() error {
 	return c.jobDB.ClearExpiredRuns()
 }
 
+func (c *LockFreeJobCache) GetCalendar(name string) (*Calendar, error) {
+	return getCalendar(c.jobDB, name)
+}
+
+func (c *LockFreeJobCache) GetAllCalendars() ([]*Calendar, error) {
+	return getAllCalendars(c.jobDB)
+}
+
+func (c *LockFreeJobCache) SaveCalendar(cal *Calendar) error {
+	return saveCalendar(c.jobDB, cal)
+}
+
+func (c *LockFreeJobCache) DeleteCalendar(name string) error {
+	return c.jobDB.DeleteCalendar(name)
+}
+
+func (c *LockFreeJobCache) GetResourcePool(name string) (*ResourcePool, error) {
+	return c.jobDB.GetResourcePool(name)
+}
+
+func (c *LockFreeJobCache) GetAllResourcePools() ([]*ResourcePool, error) {
+	return getAllResourcePools(c.jobDB)
+}
+
+func (c *LockFreeJobCache) SaveResourcePool(p *ResourcePool) error {
+	return saveResourcePool(c.jobDB, p)
+}
+
+func (c *LockFreeJobCache) DeleteResourcePool(name string) error {
+	return deleteResourcePool(c.jobDB, name)
+}
+
+func (c *LockFreeJobCache) GetWorkflow(name string) (*Workflow, error) {
+	return c.jobDB.GetWorkflow(name)
+}
+
+func (c *LockFreeJobCache) GetAllWorkflows() ([]*Workflow, error) {
+	return getAllWorkflows(c.jobDB)
+}
+
+func (c *LockFreeJobCache) SaveWorkflow(w *Workflow) error {
+	return saveWorkflow(c, c.jobDB, w)
+}
+
+func (c *LockFreeJobCache) DeleteWorkflow(name string) error {
+	return deleteWorkflow(c.jobDB, name)
+}
+
+func (c *LockFreeJobCache) GetWorkflowRun(id string) (*WorkflowRun, error) {
+	return c.jobDB.GetWorkflowRun(id)
+}
+
+func (c *LockFreeJobCache) GetWorkflowRuns(workflow string) ([]*WorkflowRun, error) {
+	return getWorkflowRuns(c.jobDB, workflow)
+}
+
+func (c *LockFreeJobCache) SaveWorkflowRun(run *WorkflowRun) error {
+	return c.jobDB.SaveWorkflowRun(run)
+}
+
 func (c *LockFreeJobCache) Persist() error {
 	jm := c.GetAll()
 	for _, j := range jm.Jobs {
@@ -397,5 +536,9 @@ func (c *LockFreeJobCache) clearJobStats(retentionWaitTime time.Duration) {
 		if err != nil {
 			log.Errorf("Error occurred during invoking retention. Err: %s", err)
 		}
+		err = clearExpiredWorkflowRuns(c.jobDB, workflowClock(c).Now().Add(-c.retentionPeriod))
+		if err != nil {
+			log.Errorf("Error occurred during invoking retention of workflow runs. Err: %s", err)
+		}
 	}
 }
@@ -1,5 +1,7 @@
 package job
 
+import "time"
+
 func enable(j *Job, cache JobCache) error {
 	j.lock.Lock()
 	defer j.lock.Unlock()
@@ -41,3 +43,144 @@ func disable(j *Job, cache JobCache) error {
 
 	return nil
 }
+
+// Calendars are read from the database each time, as they are only needed when a run is due.
+// Their parsed fields aren't stored, so they are initialized on the way in and out.
+
+func getCalendar(db JobDB, name string) (*Calendar, error) {
+	c, err := db.GetCalendar(name)
+	if err != nil {
+		return nil, err
+	}
+	return c, c.Init()
+}
+
+func getAllCalendars(db JobDB) ([]*Calendar, error) {
+	calendars, err := db.GetAllCalendars()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range calendars {
+		if err := c.Init(); err != nil {
+			return nil, err
+		}
+	}
+	return calendars, nil
+}
+
+func saveCalendar(db JobDB, c *Calendar) error {
+	if err := c.Init(); err != nil {
+		return err
+	}
+	return db.SaveCalendar(c)
+}
+
+// Resource pools are read from the database each time too, so that a change to a pool's slots
+// applies to runs already waiting for them.
+
+func getAllResourcePools(db JobDB) ([]*ResourcePool, error) {
+	pools, err := db.GetAllResourcePools()
+	if err != nil {
+		return nil, err
+	}
+	sortResourcePools(pools)
+	return pools, nil
+}
+
+func saveResourcePool(db JobDB, p *ResourcePool) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := db.SaveResourcePool(p); err != nil {
+		return err
+	}
+	ResourcePoolsChanged()
+	return nil
+}
+
+// deleteResourcePool deletes the pool, unless any job claims slots of it.
+func deleteResourcePool(db JobDB, name string) error {
+	jobs, err := db.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		for _, claim := range j.Pools {
+			if claim.Name == name {
+				return ErrResourcePoolInUse
+			}
+		}
+	}
+	if err := db.DeleteResourcePool(name); err != nil {
+		return err
+	}
+	ResourcePoolsChanged()
+	return nil
+}
+
+// Workflows and their runs are read from the database each time too.
+
+func getAllWorkflows(db JobDB) ([]*Workflow, error) {
+	workflows, err := db.GetAllWorkflows()
+	if err != nil {
+		return nil, err
+	}
+	sortWorkflows(workflows)
+	return workflows, nil
+}
+
+func saveWorkflow(cache JobCache, db JobDB, w *Workflow) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	if err := w.checkJobs(cache); err != nil {
+		return err
+	}
+	return db.SaveWorkflow(w)
+}
+
+// deleteWorkflow deletes the workflow and its runs, unless one of them is still in progress.
+func deleteWorkflow(db JobDB, name string) error {
+	runs, err := db.GetWorkflowRuns(name)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if run.Status == Status.Running {
+			return ErrWorkflowRunInProgress
+		}
+	}
+	return db.DeleteWorkflow(name)
+}
+
+// clearExpiredWorkflowRuns deletes the workflow runs that finished before the time.
+func clearExpiredWorkflowRuns(db JobDB, before time.Time) error {
+	workflows, err := db.GetAllWorkflows()
+	if err != nil {
+		return err
+	}
+	for _, w := range workflows {
+		runs, err := db.GetWorkflowRuns(w.Name)
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			if run.Status == Status.Running || !run.FinishedAt.Before(before) {
+				continue
+			}
+			if err := db.DeleteWorkflowRun(run.Id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getWorkflowRuns(db JobDB, workflow string) ([]*WorkflowRun, error) {
+	runs, err := db.GetWorkflowRuns(workflow)
+	if err != nil {
+		return nil, err
+	}
+	sortWorkflowRuns(runs)
+	return runs, nil
+}
@@ -0,0 +1,365 @@
+package job
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nextiva/nextkala/utils/wallclock"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	calendarDateFormat = "2006-01-02"
+	calendarTimeFormat = "15:04"
+	icalDateFormat     = "20060102"
+	icalDateTimeFormat = "20060102T150405"
+
+	calendarDay = 24 * time.Hour
+
+	// Blackout periods that run into each other are merged into one;
+	// this bounds how many, in case a calendar blacks out everything.
+	maxBlackoutMerges = 1000
+)
+
+var (
+	ErrInvalidCalendar      = errors.New("Invalid Calendar. Calendars must have a name")
+	ErrInvalidBlackoutRange = errors.New("Invalid Calendar. Blackout ranges must end after they start")
+	ErrRecurringICalEvent   = errors.New("Recurring iCalendar events are not supported; list each occurrence")
+)
+
+// ErrCalendarNotFound is raised when a Calendar is unable to be found within a database.
+type ErrCalendarNotFound string
+
+func (name ErrCalendarNotFound) Error() string {
+	return fmt.Sprintf("Calendar with name of %s not found.", string(name))
+}
+
+// Calendar is a named set of blackout periods. Scheduled runs of the jobs that
+// reference it are skipped or deferred (see Job.BlackoutPolicy) while any of them is in effect.
+type Calendar struct {
+	Name string `json:"name"`
+
+	// One-off periods, e.g. a change freeze.
+	Ranges []BlackoutRange `json:"ranges"`
+
+	// Periods that come round every week, e.g. a maintenance window.
+	Weekly []WeeklyWindow `json:"weekly"`
+
+	// Whole days, e.g. public holidays. See ParseICalendar to read them from an iCalendar file.
+	Holidays []Holiday `json:"holidays"`
+
+	// IANA time zone that weekly windows and holidays are in, e.g. "Europe/London".
+	// Empty means UTC.
+	Timezone string `json:"timezone"`
+	location *time.Location
+}
+
+// BlackoutRange is a blackout period from Start until End.
+type BlackoutRange struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// WeeklyWindow is a blackout period on the given days of every week, from Start until End,
+// both on the wall clock. A window ends on the following day if End isn't after Start.
+// e.g. {"days": ["sat", "sun"], "start": "22:00", "end": "06:00"}
+type WeeklyWindow struct {
+	Days  []string `json:"days"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+
+	days     map[time.Weekday]bool
+	start    time.Duration
+	duration time.Duration
+}
+
+// Holiday blacks out a whole day, given as "2006-01-02".
+type Holiday struct {
+	Name string `json:"name"`
+	Date string `json:"date"`
+
+	date time.Time
+}
+
+// Init validates the calendar and parses its fields. It must be called before the calendar is used.
+func (c *Calendar) Init() error {
+	if c.Name == "" {
+		return ErrInvalidCalendar
+	}
+
+	c.location = time.UTC
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			log.Errorf("Error loading timezone %q of calendar %s: %s", c.Timezone, c.Name, err)
+			return err
+		}
+		c.location = loc
+	}
+
+	for _, r := range c.Ranges {
+		if !r.End.After(r.Start) {
+			return ErrInvalidBlackoutRange
+		}
+	}
+
+	for i := range c.Weekly {
+		if err := c.Weekly[i].init(); err != nil {
+			return err
+		}
+	}
+
+	for i := range c.Holidays {
+		h := &c.Holidays[i]
+		date, err := time.Parse(calendarDateFormat, h.Date)
+		if err != nil {
+			return fmt.Errorf("Invalid Calendar. Error parsing holiday date %q: %v", h.Date, err)
+		}
+		h.date = date
+	}
+
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+func (w *WeeklyWindow) init() error {
+	if len(w.Days) == 0 {
+		return errors.New("Invalid Calendar. Weekly windows must have at least one day")
+	}
+	w.days = map[time.Weekday]bool{}
+	for _, name := range w.Days {
+		weekday, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("Invalid Calendar. Unknown day %q in weekly window", name)
+		}
+		w.days[weekday] = true
+	}
+
+	start, err := time.Parse(calendarTimeFormat, w.Start)
+	if err != nil {
+		return fmt.Errorf("Invalid Calendar. Error parsing weekly window start %q: %v", w.Start, err)
+	}
+	end, err := time.Parse(calendarTimeFormat, w.End)
+	if err != nil {
+		return fmt.Errorf("Invalid Calendar. Error parsing weekly window end %q: %v", w.End, err)
+	}
+
+	w.start = start.Sub(start.Truncate(calendarDay))
+	w.duration = end.Sub(start)
+	if w.duration <= 0 {
+		w.duration += calendarDay
+	}
+	return nil
+}
+
+// BlackoutEnd returns when the blackout t falls in ends, or false if t isn't in one.
+// Periods that run into each other count as a single blackout.
+func (c *Calendar) BlackoutEnd(t time.Time) (time.Time, bool) {
+	end, found := t, false
+	for i := 0; i < maxBlackoutMerges; i++ {
+		periodEnd, ok := c.periodEnd(end)
+		if !ok {
+			break
+		}
+		end, found = periodEnd, true
+	}
+	return end, found
+}
+
+// periodEnd returns the latest end of the calendar's periods that t falls in.
+func (c *Calendar) periodEnd(t time.Time) (time.Time, bool) {
+	var end time.Time
+	extend := func(e time.Time) {
+		if e.After(end) {
+			end = e
+		}
+	}
+
+	for _, r := range c.Ranges {
+		if !t.Before(r.Start) && t.Before(r.End) {
+			extend(r.End)
+		}
+	}
+
+	// Wall clock readings as naive times; see the wallclock package.
+	naive := wallclock.Naive(t.In(c.location))
+	midnight := naive.Truncate(calendarDay)
+
+	for _, w := range c.Weekly {
+		// A window is at most a day long, so only ones starting today or yesterday can be in effect.
+		for _, start := range []time.Time{midnight.Add(w.start), midnight.Add(w.start - calendarDay)} {
+			if !w.days[start.Weekday()] {
+				continue
+			}
+			if !naive.Before(start) && naive.Before(start.Add(w.duration)) {
+				extend(wallclock.Resolve(start.Add(w.duration), c.location))
+			}
+		}
+	}
+
+	for _, h := range c.Holidays {
+		if h.date.Equal(midnight) {
+			extend(wallclock.Resolve(midnight.AddDate(0, 0, 1), c.location))
+		}
+	}
+
+	return end, !end.IsZero()
+}
+
+// ParseICalendar reads the events of an iCalendar (RFC 5545) file as holidays, one for each day an event
+// covers in the timezone, as a Calendar's Timezone. Events' times are converted to it from UTC, or their TZID,
+// and those without either are taken to be in it. Dates of all-day events are taken as they are.
+func ParseICalendar(r io.Reader, timezone string) ([]Holiday, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	holidays := []Holiday{}
+
+	// A DTSTART or DTEND, with its parameters.
+	type property struct {
+		value  string
+		params []string
+	}
+	type event struct {
+		summary    string
+		start, end *property
+		rrule      bool
+	}
+	var current *event
+
+	// parse returns the date the property is on, in loc, and its time, unless it's only a date.
+	parse := func(p *property) (date, t time.Time, err error) {
+		isDate := len(p.value) == len(icalDateFormat)
+		tzid := ""
+		for _, param := range p.params {
+			if strings.EqualFold(param, "VALUE=DATE") {
+				isDate = true
+			}
+			if strings.HasPrefix(strings.ToUpper(param), "TZID=") {
+				tzid = strings.Trim(param[len("TZID="):], `"`)
+			}
+		}
+		if isDate {
+			date, err = time.Parse(icalDateFormat, p.value)
+			return date, time.Time{}, err
+		}
+
+		in := loc
+		switch {
+		case strings.HasSuffix(p.value, "Z"):
+			in = time.UTC
+		case tzid != "":
+			if in, err = time.LoadLocation(tzid); err != nil {
+				return date, t, err
+			}
+		}
+		if t, err = time.ParseInLocation(icalDateTimeFormat, strings.TrimSuffix(p.value, "Z"), in); err != nil {
+			return date, t, err
+		}
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), t, nil
+	}
+
+	addEvent := func(e *event) error {
+		if e.rrule {
+			return ErrRecurringICalEvent
+		}
+		if e.start == nil {
+			return fmt.Errorf("Error parsing iCalendar event %q: missing DTSTART", e.summary)
+		}
+		start, startTime, err := parse(e.start)
+		if err != nil {
+			return fmt.Errorf("Error parsing iCalendar event %q: %v", e.summary, err)
+		}
+
+		// Without an end, an all-day event lasts the day, and one at a time of day is over at once.
+		last := start
+		if e.end != nil {
+			end, endTime, err := parse(e.end)
+			if err != nil {
+				return fmt.Errorf("Error parsing iCalendar event %q: %v", e.summary, err)
+			}
+			// The end is when the event is over: an all-day event's end date is the day after its last,
+			// as is the date of an end at midnight.
+			last = end
+			if hour, minute, second := endTime.Clock(); endTime.IsZero() || hour+minute+second == 0 {
+				last = end.AddDate(0, 0, -1)
+			}
+			if !endTime.IsZero() && !endTime.After(startTime) {
+				last = start
+			}
+		}
+
+		for date := start; !date.After(last); date = date.AddDate(0, 0, 1) {
+			holidays = append(holidays, Holiday{Name: e.summary, Date: date.Format(calendarDateFormat), date: date})
+		}
+		return nil
+	}
+
+	handle := func(line string) error {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil
+		}
+		params := strings.Split(line[:colon], ";")
+		name, value := strings.ToUpper(params[0]), line[colon+1:]
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &event{}
+		case current == nil:
+		case name == "END" && value == "VEVENT":
+			err := addEvent(current)
+			current = nil
+			return err
+		case name == "SUMMARY":
+			current.summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+		case name == "DTSTART":
+			current.start = &property{value: value, params: params[1:]}
+		case name == "DTEND":
+			current.end = &property{value: value, params: params[1:]}
+		case name == "RRULE":
+			current.rrule = true
+		}
+		return nil
+	}
+
+	// Long lines are folded onto following lines starting with whitespace.
+	scanner := bufio.NewScanner(r)
+	var line string
+	for scanner.Scan() {
+		next := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(next, " ") || strings.HasPrefix(next, "\t") {
+			line += next[1:]
+			continue
+		}
+		if err := handle(line); err != nil {
+			return nil, err
+		}
+		line = next
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := handle(line); err != nil {
+		return nil, err
+	}
+
+	return holidays, nil
+}
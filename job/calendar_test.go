@@ -0,0 +1,245 @@
+package job
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarInitErrors(t *testing.T) {
+	start := time.Date(2020, time.January, 13, 10, 0, 0, 0, time.UTC)
+
+	calendars := []*Calendar{
+		{},
+		{Name: "zone", Timezone: "Not/AZone"},
+		{Name: "range", Ranges: []BlackoutRange{{Start: start, End: start}}},
+		{Name: "no days", Weekly: []WeeklyWindow{{Start: "22:00", End: "06:00"}}},
+		{Name: "bad day", Weekly: []WeeklyWindow{{Days: []string{"someday"}, Start: "22:00", End: "06:00"}}},
+		{Name: "bad time", Weekly: []WeeklyWindow{{Days: []string{"mon"}, Start: "25:00", End: "06:00"}}},
+		{Name: "bad date", Holidays: []Holiday{{Date: "2020-13-01"}}},
+	}
+	for _, c := range calendars {
+		assert.Error(t, c.Init(), c.Name)
+	}
+}
+
+var blackoutTableTests = []struct {
+	Name     string
+	Calendar Calendar
+	At       string
+	End      string
+}{
+	{
+		Name: "Outside any blackout",
+		Calendar: Calendar{
+			Ranges: []BlackoutRange{{
+				Start: time.Date(2020, time.January, 13, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2020, time.January, 13, 12, 0, 0, 0, time.UTC),
+			}},
+		},
+		At: "2020-01-13T12:00:00Z",
+	},
+	{
+		Name: "In a range",
+		Calendar: Calendar{
+			Ranges: []BlackoutRange{{
+				Start: time.Date(2020, time.January, 13, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2020, time.January, 13, 12, 0, 0, 0, time.UTC),
+			}},
+		},
+		At:  "2020-01-13T10:00:00Z",
+		End: "2020-01-13T12:00:00Z",
+	},
+	{
+		Name: "Overlapping ranges are merged",
+		Calendar: Calendar{
+			Ranges: []BlackoutRange{
+				{
+					Start: time.Date(2020, time.January, 13, 10, 0, 0, 0, time.UTC),
+					End:   time.Date(2020, time.January, 13, 12, 0, 0, 0, time.UTC),
+				},
+				{
+					Start: time.Date(2020, time.January, 13, 11, 0, 0, 0, time.UTC),
+					End:   time.Date(2020, time.January, 13, 14, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		At:  "2020-01-13T10:30:00Z",
+		End: "2020-01-13T14:00:00Z",
+	},
+	{
+		Name: "Weekly window across midnight",
+		Calendar: Calendar{
+			Weekly: []WeeklyWindow{{Days: []string{"sat"}, Start: "22:00", End: "06:00"}},
+		},
+		// Sunday morning, in Saturday's window.
+		At:  "2020-01-19T03:00:00Z",
+		End: "2020-01-19T06:00:00Z",
+	},
+	{
+		Name: "Weekly window on another day",
+		Calendar: Calendar{
+			Weekly: []WeeklyWindow{{Days: []string{"sat"}, Start: "22:00", End: "06:00"}},
+		},
+		// Monday morning.
+		At: "2020-01-20T03:00:00Z",
+	},
+	{
+		Name: "Weekly window runs into a holiday",
+		Calendar: Calendar{
+			Weekly:   []WeeklyWindow{{Days: []string{"Friday"}, Start: "20:00", End: "00:00"}},
+			Holidays: []Holiday{{Name: "Saturday off", Date: "2020-01-18"}},
+		},
+		At:  "2020-01-17T21:00:00Z",
+		End: "2020-01-19T00:00:00Z",
+	},
+	{
+		Name: "Weekly window in a time zone",
+		Calendar: Calendar{
+			Weekly:   []WeeklyWindow{{Days: []string{"sun"}, Start: "01:00", End: "04:00"}},
+			Timezone: "America/Los_Angeles",
+		},
+		// Clocks go forward at 02:00, so the window is two hours long.
+		At:  "2020-03-08T09:30:00Z",
+		End: "2020-03-08T11:00:00Z",
+	},
+	{
+		Name: "Holiday in a time zone",
+		Calendar: Calendar{
+			Holidays: []Holiday{{Name: "Christmas", Date: "2020-12-25"}},
+			Timezone: "Europe/Berlin",
+		},
+		At:  "2020-12-24T23:30:00Z",
+		End: "2020-12-25T23:00:00Z",
+	},
+}
+
+func TestCalendarBlackoutEnd(t *testing.T) {
+	for _, testStruct := range blackoutTableTests {
+		c := testStruct.Calendar
+		c.Name = "test"
+		assert.NoError(t, c.Init(), testStruct.Name)
+
+		at, err := time.Parse(time.RFC3339, testStruct.At)
+		assert.NoError(t, err, testStruct.Name)
+
+		end, ok := c.BlackoutEnd(at)
+		if testStruct.End == "" {
+			assert.False(t, ok, testStruct.Name)
+			continue
+		}
+		assert.True(t, ok, testStruct.Name)
+		assert.Equal(t, testStruct.End, end.UTC().Format(time.RFC3339), testStruct.Name)
+	}
+}
+
+func TestParseICalendar(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20201225",
+		"DTEND;VALUE=DATE:20201227",
+		"SUMMARY:Christmas\\, and Boxing Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20210101T000000Z",
+		"SUMMARY:New Year",
+		" 's Day",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	holidays, err := ParseICalendar(strings.NewReader(ics), "")
+	assert.NoError(t, err)
+	if assert.Len(t, holidays, 3) {
+		assert.Equal(t, "Christmas, and Boxing Day", holidays[0].Name)
+		assert.Equal(t, "2020-12-25", holidays[0].Date)
+		assert.Equal(t, "2020-12-26", holidays[1].Date)
+		assert.Equal(t, "New Year's Day", holidays[2].Name)
+		assert.Equal(t, "2021-01-01", holidays[2].Date)
+	}
+
+	recurring := "BEGIN:VEVENT\nDTSTART;VALUE=DATE:20201225\nRRULE:FREQ=YEARLY\nEND:VEVENT\n"
+	_, err = ParseICalendar(strings.NewReader(recurring), "")
+	assert.Equal(t, ErrRecurringICalEvent, err)
+
+	_, err = ParseICalendar(strings.NewReader("BEGIN:VEVENT\nSUMMARY:No date\nEND:VEVENT\n"), "")
+	assert.Error(t, err)
+}
+
+func TestParseICalendarTimes(t *testing.T) {
+	event := func(props ...string) string {
+		return strings.Join(append(append([]string{"BEGIN:VEVENT"}, props...), "END:VEVENT"), "\r\n")
+	}
+
+	tests := []struct {
+		Name     string
+		Timezone string
+		Event    string
+		Dates    []string
+	}{
+		{
+			Name:     "UTC, on the day before in the calendar's timezone",
+			Timezone: "America/New_York",
+			Event:    event("DTSTART:20210101T030000Z"),
+			Dates:    []string{"2020-12-31"},
+		},
+		{
+			Name:  "TZID, on the day before in UTC",
+			Event: event("DTSTART;TZID=Asia/Tokyo:20210101T080000"),
+			Dates: []string{"2020-12-31"},
+		},
+		{
+			Name:     "Floating, in the calendar's timezone",
+			Timezone: "Asia/Tokyo",
+			Event:    event("DTSTART:20210101T080000"),
+			Dates:    []string{"2021-01-01"},
+		},
+		{
+			Name:  "Several days",
+			Event: event("DTSTART:20210105T090000Z", "DTEND:20210107T170000Z"),
+			Dates: []string{"2021-01-05", "2021-01-06", "2021-01-07"},
+		},
+		{
+			Name:     "Several days, converted",
+			Timezone: "America/New_York",
+			Event:    event(`DTSTART;TZID="Europe/Paris":20210105T030000`, "DTEND;TZID=Europe/Paris:20210106T120000"),
+			Dates:    []string{"2021-01-04", "2021-01-05", "2021-01-06"},
+		},
+		{
+			Name:  "Ending at midnight",
+			Event: event("DTSTART:20210110T220000Z", "DTEND:20210111T000000Z"),
+			Dates: []string{"2021-01-10"},
+		},
+	}
+	for _, test := range tests {
+		holidays, err := ParseICalendar(strings.NewReader(test.Event), test.Timezone)
+		if !assert.NoError(t, err, test.Name) {
+			continue
+		}
+		dates := []string{}
+		for _, h := range holidays {
+			dates = append(dates, h.Date)
+		}
+		assert.Equal(t, test.Dates, dates, test.Name)
+	}
+
+	_, err := ParseICalendar(strings.NewReader(event("DTSTART;TZID=Nowhere/Special:20210101T080000")), "")
+	assert.Error(t, err)
+	_, err = ParseICalendar(strings.NewReader(event("DTSTART:20210101T0800")), "")
+	assert.Error(t, err)
+}
+
+func TestJobCalendarErrors(t *testing.T) {
+	j := GetMockJob()
+	j.BlackoutPolicy = "sometimes"
+	assert.Equal(t, ErrInvalidBlackoutPolicy, j.validation())
+
+	cache := NewMockCache()
+	j = GetMockJobWithGenericSchedule(time.Now())
+	j.Calendars = []string{"missing"}
+	assert.Equal(t, ErrCalendarNotFound("missing"), j.Init(cache))
+}
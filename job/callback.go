@@ -0,0 +1,247 @@
+package job
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nextiva/nextkala/utils/iso8601"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultCallbackTimeout is how long an async remote job's run waits for its callback,
+// unless the job sets a CallbackTimeout.
+const DefaultCallbackTimeout = time.Hour
+
+// CallbackTokenHeader is the header an async remote job's request carries its run's callback token in,
+// and the callback must send back.
+const CallbackTokenHeader = "NextKala-Callback-Token"
+
+var (
+	ErrCallbackTimedOut       = fmt.Errorf("%w No callback was received in time.", ErrJobTimedOut)
+	ErrCallbackFailed         = errors.New("Job run failed, according to its callback.")
+	ErrInvalidCallbackToken   = errors.New("Invalid callback token for the job run.")
+	ErrInvalidCallbackStatus  = errors.New("Invalid callback status. Statuses supported: Success and Failed")
+	ErrRunNotAwaitingCallback = errors.New("Job run isn't waiting for a callback.")
+	ErrCallbackInterrupted    = errors.New("Job run failed, as the server stopped while it waited for a callback.")
+)
+
+// CallbackSecret signs the callback tokens of async remote jobs' runs. It's random unless set,
+// e.g. so that tokens stay valid across restarts.
+var CallbackSecret = randomSecret()
+
+func randomSecret() []byte {
+	b := make([]byte, 32) //nolint:gomnd
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// CallbackResult is what an async remote job's callback reports about its run.
+type CallbackResult struct {
+	// Success or Failed.
+	Status JobStatus `json:"status"`
+	Output string    `json:"output"`
+	// Anything else, recorded as the JobStat's Result.
+	Result json.RawMessage `json:"result"`
+}
+
+// UnmarshalJSON also accepts just a status, as the callback body used to be.
+func (c *CallbackResult) UnmarshalJSON(b []byte) error {
+	var status JobStatus
+	if err := json.Unmarshal(b, &status); err == nil {
+		*c = CallbackResult{Status: status}
+		return nil
+	}
+	type plain CallbackResult
+	return json.Unmarshal(b, (*plain)(c))
+}
+
+// callbackToken returns the token for the given attempt of a run, made up of the attempt and a signature of both.
+// Each attempt has its own, so that a late callback for one doesn't complete the next.
+func callbackToken(runID string, attempt uint) string {
+	mac := hmac.New(sha256.New, CallbackSecret)
+	fmt.Fprintf(mac, "%s/%d", runID, attempt)
+	return strconv.FormatUint(uint64(attempt), 10) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCallbackToken returns the attempt of the run that token is for, if it's been signed for it.
+func verifyCallbackToken(runID, token string) (uint, bool) {
+	parts := strings.SplitN(token, ".", 2) //nolint:gomnd
+	attempt, err := strconv.ParseUint(parts[0], 10, 0)
+	if len(parts) != 2 || err != nil {
+		return 0, false
+	}
+	want := callbackToken(runID, uint(attempt))
+	return uint(attempt), hmac.Equal([]byte(token), []byte(want))
+}
+
+// Runs waiting for their callbacks, by run id.
+var callbacks = struct {
+	sync.Mutex
+	m map[string]*pendingCallback
+}{m: map[string]*pendingCallback{}}
+
+type pendingCallback struct {
+	attempt uint
+	done    chan *CallbackResult
+}
+
+// AwaitingCallback says whether the run with the given id is waiting for its callback.
+func AwaitingCallback(runID string) bool {
+	callbacks.Lock()
+	defer callbacks.Unlock()
+	_, ok := callbacks.m[runID]
+	return ok
+}
+
+// CompleteRun hands the result of an async remote job's run, from its callback, to the run waiting for it.
+// The token must be the one the run's current attempt was sent.
+func CompleteRun(runID, token string, result *CallbackResult) error {
+	if result.Status != Status.Success && result.Status != Status.Failed {
+		return ErrInvalidCallbackStatus
+	}
+
+	attempt, ok := verifyCallbackToken(runID, token)
+	if !ok {
+		return ErrInvalidCallbackToken
+	}
+
+	callbacks.Lock()
+	defer callbacks.Unlock()
+	pending, ok := callbacks.m[runID]
+	if !ok || pending.attempt != attempt {
+		return ErrRunNotAwaitingCallback
+	}
+	delete(callbacks.m, runID)
+	pending.done <- result
+	return nil
+}
+
+// IsAsync says whether the job is an async remote job, whose runs are completed by their callbacks.
+func (j *Job) IsAsync() bool {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	return j.JobType == RemoteJob && j.RemoteProperties.Async
+}
+
+// callbackTimeout returns how long an async remote job's runs wait for their callbacks.
+func (j *Job) callbackTimeout() time.Duration {
+	timeout, err := iso8601.FromString(j.RemoteProperties.CallbackTimeout)
+	if j.RemoteProperties.CallbackTimeout == "" || err != nil {
+		return DefaultCallbackTimeout
+	}
+	return timeout.RelativeTo(j.clk.Time().Now())
+}
+
+// attempt returns which attempt at the run this is, counting from 0.
+func (j *JobRunner) attempt() uint {
+	return j.job.Retries - j.currentRetries
+}
+
+// expectCallback makes the run's current attempt ready for its callback, until done is called.
+func (j *JobRunner) expectCallback() (pending *pendingCallback, done func()) {
+	pending = &pendingCallback{attempt: j.attempt(), done: make(chan *CallbackResult, 1)}
+	runID := j.currentStat.Id
+
+	callbacks.Lock()
+	defer callbacks.Unlock()
+	callbacks.m[runID] = pending
+	return pending, func() {
+		callbacks.Lock()
+		defer callbacks.Unlock()
+		if callbacks.m[runID] == pending {
+			delete(callbacks.m, runID)
+		}
+	}
+}
+
+// awaitCallback records an async remote job's run as Running, once its request has been accepted, and waits
+// for the callback with its result, unless the run is cancelled or replaced, or the CallbackTimeout passes, first.
+// The run gives up its slots and the job's lock while it waits.
+func (j *JobRunner) awaitCallback(pending *pendingCallback) (string, error) {
+	timeout := j.job.callbackTimeout()
+	j.currentStat.Status = Status.Running
+	j.currentStat.CallbackDeadline = j.job.clk.Time().Now().Add(timeout)
+	// For the run to carry on from this attempt, if the server stops.
+	j.currentStat.NumberOfRetries = j.attempt()
+	j.log.setStatus(Status.Running)
+	if j.cache != nil {
+		if err := j.cache.UpdateRun(j.currentStat); err != nil {
+			log.Errorf("Error saving job status: %v", err)
+		}
+	}
+
+	j.releaseSlot()
+	timer := j.job.clk.Time().NewTimer(timeout)
+	defer timer.Stop()
+
+	var result *CallbackResult
+	var err error
+	j.unlocked(func() {
+		select {
+		case result = <-pending.done:
+		case <-timer.Chan():
+			err = ErrCallbackTimedOut
+		case <-j.Context().Done():
+			err = j.Context().Err()
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	j.currentStat.Result = result.Result
+	if result.Status != Status.Success {
+		return result.Output, ErrCallbackFailed
+	}
+	return result.Output, nil
+}
+
+// failInterruptedCallbacks carries on with the runs of the jobs that were waiting for their callbacks when
+// the server stopped, as their callbacks can't be handed to them anymore. Each fails like any other run, with
+// ErrCallbackTimedOut if its CallbackDeadline has passed, or else ErrCallbackInterrupted: it's retried, or
+// recorded as TimedOut or Failed and the job's OnFailureJob started. Runs of disabled jobs, and of workflows,
+// which fail along with their workflow runs, are only recorded as such.
+func failInterruptedCallbacks(cache JobCache, jobs []*Job) {
+	for _, j := range jobs {
+		if j.JobType != RemoteJob || !j.RemoteProperties.Async {
+			continue
+		}
+		runs, err := cache.GetAllRuns(j.Id)
+		if err != nil {
+			log.Errorf("Error getting the runs of job %s:%s: %v", j.Name, j.Id, err)
+			continue
+		}
+		now := j.clk.Time().Now()
+		for _, run := range runs {
+			if run.Status != Status.Running || run.CallbackDeadline.IsZero() {
+				continue
+			}
+			err, status := ErrCallbackInterrupted, Status.Failed
+			if !now.Before(run.CallbackDeadline) {
+				err, status = ErrCallbackTimedOut, Status.TimedOut
+			}
+			log.Infof("Job %s:%s run %s stopped waiting for its callback: %v", j.Name, j.Id, run.Id, err)
+			if !j.Disabled && run.WorkflowRunId == "" {
+				resumed := *run
+				go j.resume(cache, &resumed, err)
+				continue
+			}
+			run.Status = status
+			run.Output = err.Error()
+			if err := cache.UpdateRun(run); err != nil {
+				log.Errorf("Error saving job status: %v", err)
+			}
+		}
+	}
+}
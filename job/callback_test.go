@@ -0,0 +1,247 @@
+package job
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mixer/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallbackToken(t *testing.T) {
+	token := callbackToken("run", 1)
+
+	attempt, ok := verifyCallbackToken("run", token)
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), attempt)
+
+	for _, bad := range []string{"", "1", "1.", "2" + token[1:], token + "x", callbackToken("other", 1)} {
+		_, ok := verifyCallbackToken("run", bad)
+		assert.False(t, ok, bad)
+	}
+}
+
+func TestCallbackResultUnmarshal(t *testing.T) {
+	var result CallbackResult
+	assert.NoError(t, json.Unmarshal([]byte(`"Success"`), &result))
+	assert.Equal(t, CallbackResult{Status: Status.Success}, result)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"status": "Failed", "output": "no rows", "result": {"rows": 0}}`), &result))
+	assert.Equal(t, Status.Failed, result.Status)
+	assert.Equal(t, "no rows", result.Output)
+	assert.JSONEq(t, `{"rows": 0}`, string(result.Result))
+}
+
+// asyncTestServer accepts requests, and sends each one's callback with result, reporting how that went on completed.
+func asyncTestServer(result *CallbackResult, completed chan<- error) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runID, token := r.Header.Get("NextKala-RunId"), r.Header.Get(CallbackTokenHeader)
+		if result != nil {
+			go func() {
+				completed <- CompleteRun(runID, token, result)
+			}()
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func TestAsyncRemoteJob(t *testing.T) {
+	completed := make(chan error, 1)
+	result := &CallbackResult{Status: Status.Success, Output: "done", Result: json.RawMessage(`{"rows":3}`)}
+	testServer := asyncTestServer(result, completed)
+	defer testServer.Close()
+
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL, Async: true})
+	j.Id = "async"
+	j.Run(cache)
+	assert.NoError(t, <-completed)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Success, stats[0].Status)
+		assert.Equal(t, "done", stats[0].Output)
+		assert.Equal(t, http.StatusAccepted, stats[0].StatusCode)
+		assert.JSONEq(t, `{"rows":3}`, string(stats[0].Result))
+	}
+	assert.False(t, AwaitingCallback(stats[0].Id))
+	assert.Nil(t, j.RemoteProperties.Headers[CallbackTokenHeader], "The token shouldn't be kept with the job")
+}
+
+func TestAsyncRemoteJobFailed(t *testing.T) {
+	completed := make(chan error, 1)
+	testServer := asyncTestServer(&CallbackResult{Status: Status.Failed, Output: "no rows"}, completed)
+	defer testServer.Close()
+
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL, Async: true})
+	j.Id = "async-failed"
+	j.Run(cache)
+	assert.NoError(t, <-completed)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Failed, stats[0].Status)
+		assert.Equal(t, "no rows", stats[0].Output)
+	}
+	assert.Equal(t, 1, int(j.Metadata.ErrorCount))
+}
+
+func TestAsyncRemoteJobTimedOut(t *testing.T) {
+	var tokens []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get(CallbackTokenHeader))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer testServer.Close()
+
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL, Async: true, CallbackTimeout: "PT1S"})
+	j.Id = "async-timed-out"
+	j.Retries = 1
+	j.Run(cache)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.TimedOut, stats[0].Status)
+		assert.Equal(t, uint(1), stats[0].NumberOfRetries)
+
+		// Each attempt had a token of its own, which is no use once it's over.
+		if assert.Len(t, tokens, 2) {
+			assert.NotEqual(t, tokens[0], tokens[1])
+			assert.Equal(t, ErrRunNotAwaitingCallback, CompleteRun(stats[0].Id, tokens[1], &CallbackResult{Status: Status.Success}))
+		}
+	}
+}
+
+// waitForRunStatus returns a run of the job with the status, once there is one.
+func waitForRunStatus(t *testing.T, cache JobCache, jobID string, status JobStatus) *JobStat {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		stats, err := cache.GetAllRuns(jobID)
+		assert.NoError(t, err)
+		for _, stat := range stats {
+			if stat.Status == status {
+				return stat
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %s's run didn't get the status %s", jobID, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAsyncRemoteJobWaitsWithoutHoldingOn(t *testing.T) {
+	defer func(max int) { MaxConcurrentRuns = max }(MaxConcurrentRuns)
+	MaxConcurrentRuns = 1
+
+	testServer := asyncTestServer(nil, nil)
+	defer testServer.Close()
+
+	clk := clock.NewMockClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL, Async: true, CallbackTimeout: "PT1H"})
+	j.Id = "async-waiting"
+	j.clk.SetClock(clk)
+	ran := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(ran)
+	}()
+
+	stat := waitForRunStatus(t, cache, j.Id, Status.Running)
+	assert.Equal(t, clk.Now().Add(time.Hour), stat.CallbackDeadline)
+
+	// Neither the job's lock nor the only slot is held meanwhile.
+	j.lock.Lock()
+	j.Name = "renamed"
+	j.lock.Unlock()
+	other := GetMockJob()
+	other.Id = "async-waiting-other"
+	other.Retries = 0
+	other.Run(cache)
+	assert.Equal(t, uint(1), other.Metadata.SuccessCount)
+
+	// The timeout is on the job's clock.
+	clk.AddTime(time.Hour)
+	select {
+	case <-ran:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The run didn't time out")
+	}
+	waitForRunStatus(t, cache, j.Id, Status.TimedOut)
+}
+
+func TestFailInterruptedCallbacks(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	onFailureJob := GetMockJob()
+	onFailureJob.Id = "async-interrupted-on-failure"
+	assert.NoError(t, cache.Set(onFailureJob))
+	j := GetMockRemoteJob(RemoteProperties{Url: "http://example.com", Async: true})
+	j.Id = "async-interrupted"
+	j.OnFailureJob = onFailureJob.Id
+	assert.NoError(t, cache.Set(j))
+	now := j.clk.Time().Now()
+
+	stale, waiting, done := NewJobStat(j.Id), NewJobStat(j.Id), NewJobStat(j.Id)
+	stale.Status, stale.CallbackDeadline = Status.Running, now.Add(-time.Minute)
+	waiting.Status, waiting.CallbackDeadline = Status.Running, now.Add(time.Hour)
+	done.Status, done.CallbackDeadline = Status.Success, now.Add(-time.Minute)
+	for _, stat := range []*JobStat{stale, waiting, done} {
+		assert.NoError(t, cache.SaveRun(stat))
+	}
+
+	failInterruptedCallbacks(cache, []*Job{j})
+
+	stat := waitForRunStatus(t, cache, j.Id, Status.TimedOut)
+	assert.Equal(t, stale.Id, stat.Id)
+	stat = waitForRunStatus(t, cache, j.Id, Status.Failed)
+	assert.Equal(t, waiting.Id, stat.Id)
+	assert.Equal(t, ErrCallbackInterrupted.Error(), stat.Output)
+	stat, err := cache.GetRun(done.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, Status.Success, stat.Status)
+
+	// They failed like any other run.
+	waitForRunStatus(t, cache, onFailureJob.Id, Status.Success)
+}
+
+func TestFailInterruptedCallbacksRetries(t *testing.T) {
+	completed := make(chan error, 1)
+	testServer := asyncTestServer(&CallbackResult{Status: Status.Success}, completed)
+	defer testServer.Close()
+
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL, Async: true})
+	j.Id = "async-interrupted-retried"
+	j.Retries = 1
+	assert.NoError(t, cache.Set(j))
+
+	interrupted := NewJobStat(j.Id)
+	interrupted.Status, interrupted.CallbackDeadline = Status.Running, j.clk.Time().Now().Add(-time.Minute)
+	assert.NoError(t, cache.SaveRun(interrupted))
+
+	failInterruptedCallbacks(cache, []*Job{j})
+	assert.NoError(t, <-completed)
+	for {
+		j.lock.RLock()
+		succeeded := j.Metadata.SuccessCount == 1
+		j.lock.RUnlock()
+		if succeeded {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The run carried on with its next attempt.
+	stat := waitForRunStatus(t, cache, j.Id, Status.Success)
+	assert.Equal(t, interrupted.Id, stat.Id)
+	assert.Equal(t, uint(1), stat.NumberOfRetries)
+}
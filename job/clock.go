@@ -2,6 +2,7 @@ package job
 
 import (
 	"sync"
+	"time"
 
 	// This library abstracts the time functionality of the OS so that it can be controlled during unit tests.
 	// It was selected over thejerf/abtime because abtime is geared towards precision timing rather than scheduling.
@@ -20,18 +21,15 @@ func (clk *Clock) SetClock(in clock.Clock) {
 	clk.Clock = in
 }
 
+// Time returns the clock set with SetClock, or else the system's. The system's isn't kept as the one set,
+// so that the jobs the Clock is part of can be copied, e.g. to serialize them, while they run.
 func (clk *Clock) Time() clock.Clock {
 	clk.lock.RLock()
 	defer clk.lock.RUnlock()
 
 	if clk.Clock == nil {
-		clk.lock.RUnlock()
-		clk.lock.Lock()
-		clk.Clock = clock.C
-		clk.lock.Unlock()
-		clk.lock.RLock()
+		return defaultClock{}
 	}
-
 	return clk.Clock
 }
 
@@ -46,3 +44,26 @@ type Clocker interface {
 	Time() clock.Clock
 	TimeSet() bool
 }
+
+// defaultClock is the clock jobs use unless they're given another. It's clock.C, except that its
+// timers hold the *time.Timer they stop or reset: clock.C's hold a copy of the time.Timer, which
+// can't safely be stopped once it has fired.
+type defaultClock struct {
+	clock.DefaultClock
+}
+
+func (defaultClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	return &defaultTimer{time.AfterFunc(d, f)}
+}
+
+func (defaultClock) NewTimer(d time.Duration) clock.Timer {
+	return &defaultTimer{time.NewTimer(d)}
+}
+
+type defaultTimer struct {
+	*time.Timer
+}
+
+func (t *defaultTimer) Chan() <-chan time.Time {
+	return t.C
+}
@@ -25,6 +25,23 @@ type JobDB interface {
 	GetRun(runID string) (*JobStat, error)
 	DeleteRun(jobId string) error
 	ClearExpiredRuns() error
+	GetCalendar(name string) (*Calendar, error)
+	GetAllCalendars() ([]*Calendar, error)
+	SaveCalendar(*Calendar) error
+	DeleteCalendar(name string) error
+	GetResourcePool(name string) (*ResourcePool, error)
+	GetAllResourcePools() ([]*ResourcePool, error)
+	SaveResourcePool(*ResourcePool) error
+	DeleteResourcePool(name string) error
+	GetWorkflow(name string) (*Workflow, error)
+	GetAllWorkflows() ([]*Workflow, error)
+	SaveWorkflow(*Workflow) error
+	// DeleteWorkflow deletes the workflow along with its runs.
+	DeleteWorkflow(name string) error
+	GetWorkflowRun(id string) (*WorkflowRun, error)
+	GetWorkflowRuns(workflow string) ([]*WorkflowRun, error)
+	SaveWorkflowRun(*WorkflowRun) error
+	DeleteWorkflowRun(id string) error
 }
 
 func (j *Job) Delete(cache JobCache) error {
@@ -0,0 +1,223 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Executor runs the jobs of a type. Besides the built in local, remote and script types, programs
+// using this package can run jobs of their own types, by registering an Executor for each with
+// RegisterExecutor. Jobs of those types keep what they need in their Properties.
+type Executor interface {
+	// Name of the type, e.g. "remote".
+	Name() string
+
+	// Schema is a JSON schema of the fields of jobs of the type that its runs use.
+	Schema() json.RawMessage
+
+	// Validate returns an error saying what's wrong if the job is missing something its runs need.
+	Validate(j *Job) error
+
+	// Execute runs the job once, returning its output. It should give up, with an error,
+	// if the run's Context is cancelled.
+	Execute(r *JobRunner) (string, error)
+}
+
+var executors = struct {
+	sync.RWMutex
+	m map[JobType]Executor
+}{m: map[JobType]Executor{
+	LocalJob:  localExecutor{},
+	RemoteJob: remoteExecutor{},
+	ScriptJob: scriptExecutor{},
+}}
+
+// RegisterExecutor makes jobs of type t run with e. It panics if t already has an Executor,
+// so should be called before any jobs are loaded, e.g. from an init function.
+func RegisterExecutor(t JobType, e Executor) {
+	executors.Lock()
+	defer executors.Unlock()
+
+	if _, ok := executors.m[t]; ok {
+		panic(fmt.Sprintf("job: RegisterExecutor called twice for type %d", t))
+	}
+	executors.m[t] = e
+}
+
+// Executors returns the Executors for all of the job types.
+func Executors() map[JobType]Executor {
+	executors.RLock()
+	defer executors.RUnlock()
+
+	m := make(map[JobType]Executor, len(executors.m))
+	for t, e := range executors.m {
+		m[t] = e
+	}
+	return m
+}
+
+func executorFor(t JobType) (Executor, bool) {
+	executors.RLock()
+	defer executors.RUnlock()
+	e, ok := executors.m[t]
+	return e, ok
+}
+
+// The fields local and script jobs share.
+const localProperties = `
+		"env": {"type": "object", "additionalProperties": {"type": "string"}},
+		"working_dir": {"type": "string"},
+		"stdin": {"type": "string"},
+		"timeout": {"type": "string", "format": "duration"},
+		"kill_grace_period": {"type": "string", "format": "duration"},
+		"run_as": {
+			"type": "object",
+			"properties": {"user": {"type": "string"}, "group": {"type": "string"}}
+		},
+		"rlimits": {
+			"type": "object",
+			"properties": {
+				"cpu_seconds": {"type": "integer", "minimum": 0},
+				"address_space": {"type": "integer", "minimum": 0},
+				"open_files": {"type": "integer", "minimum": 0},
+				"processes": {"type": "integer", "minimum": 0}
+			}
+		}`
+
+type localExecutor struct{}
+
+func (localExecutor) Name() string {
+	return "local"
+}
+
+func (localExecutor) Schema() json.RawMessage {
+	return json.RawMessage(`{
+	"type": "object",
+	"required": ["name", "command"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"command": {"type": "string", "minLength": 1},` + localProperties + `
+	}
+}`)
+}
+
+func (localExecutor) Validate(j *Job) error {
+	if j.Name == "" || j.Command == "" {
+		return ErrInvalidJob
+	}
+	return nil
+}
+
+func (localExecutor) Execute(r *JobRunner) (string, error) {
+	return r.LocalRun()
+}
+
+type remoteExecutor struct{}
+
+func (remoteExecutor) Name() string {
+	return "remote"
+}
+
+func (remoteExecutor) Schema() json.RawMessage {
+	return json.RawMessage(`{
+	"type": "object",
+	"required": ["name", "remote_properties"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"remote_properties": {
+			"type": "object",
+			"required": ["url"],
+			"properties": {
+				"url": {"type": "string", "minLength": 1},
+				"method": {"type": "string"},
+				"body": {"type": "string"},
+				"headers": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}},
+				"timeout": {"type": "integer", "minimum": 0},
+				"expected_response_codes": {"type": "array", "items": {"type": "integer"}},
+				"assertions": {
+					"type": "object",
+					"properties": {
+						"json": {
+							"type": "array",
+							"items": {
+								"type": "object",
+								"required": ["path", "equals"],
+								"properties": {"path": {"type": "string"}, "equals": {}}
+							}
+						},
+						"body_matches": {"type": "string"},
+						"headers": {"type": "object", "additionalProperties": {"type": "string"}},
+						"max_latency": {"type": "string"}
+					}
+				},
+				"tls": {
+					"type": "object",
+					"properties": {
+						"ca_file": {"type": "string"},
+						"cert_file": {"type": "string"},
+						"key_file": {"type": "string"},
+						"server_name": {"type": "string"},
+						"insecure_skip_verify": {"type": "boolean"}
+					}
+				},
+				"proxy": {"type": "string"},
+				"async": {"type": "boolean"},
+				"callback_timeout": {"type": "string"}
+			}
+		}
+	}
+}`)
+}
+
+func (remoteExecutor) Validate(j *Job) error {
+	if j.Name == "" || j.RemoteProperties.Url == "" {
+		return ErrInvalidRemoteJob
+	}
+	return nil
+}
+
+func (remoteExecutor) Execute(r *JobRunner) (string, error) {
+	if !r.job.RemoteProperties.Async || r.currentStat == nil {
+		r.saveStarted(true)
+		return r.RemoteRun()
+	}
+	r.saveStarted(false)
+
+	// Before the request, in case the callback beats the response.
+	pending, done := r.expectCallback()
+	defer done()
+	if _, err := r.RemoteRun(); err != nil {
+		return "", err
+	}
+	return r.awaitCallback(pending)
+}
+
+type scriptExecutor struct{}
+
+func (scriptExecutor) Name() string {
+	return "script"
+}
+
+func (scriptExecutor) Schema() json.RawMessage {
+	return json.RawMessage(`{
+	"type": "object",
+	"required": ["name", "script"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"script": {"type": "string", "minLength": 1},
+		"interpreter": {"type": "string"},` + localProperties + `
+	}
+}`)
+}
+
+func (scriptExecutor) Validate(j *Job) error {
+	if j.Name == "" || j.Script == "" {
+		return ErrInvalidScriptJob
+	}
+	return nil
+}
+
+func (scriptExecutor) Execute(r *JobRunner) (string, error) {
+	return r.ScriptRun()
+}
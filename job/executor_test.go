@@ -0,0 +1,83 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const greetingJob JobType = 100
+
+var errNoGreeting = errors.New("Invalid Greeting Job. Job's must contain a greeting property")
+
+type greetingProperties struct {
+	Greeting string `json:"greeting"`
+}
+
+// greetingExecutor runs jobs that output the greeting in their properties.
+type greetingExecutor struct{}
+
+func (greetingExecutor) Name() string {
+	return "greeting"
+}
+
+func (greetingExecutor) Schema() json.RawMessage {
+	return json.RawMessage(`{"type": "object", "properties": {"properties": {"type": "object"}}}`)
+}
+
+func (greetingExecutor) Validate(j *Job) error {
+	var props greetingProperties
+	if err := json.Unmarshal(j.Properties, &props); err != nil || props.Greeting == "" {
+		return errNoGreeting
+	}
+	return nil
+}
+
+func (greetingExecutor) Execute(r *JobRunner) (string, error) {
+	if err := r.Context().Err(); err != nil {
+		return "", err
+	}
+	var props greetingProperties
+	if err := json.Unmarshal(r.Job().Properties, &props); err != nil {
+		return "", err
+	}
+	return props.Greeting + " from " + r.Stat().Id, nil
+}
+
+func TestRegisterExecutor(t *testing.T) {
+	j := &Job{Name: "greeting", Id: "greeting", JobType: greetingJob}
+	assert.Equal(t, ErrInvalidJobType, j.validation())
+
+	RegisterExecutor(greetingJob, greetingExecutor{})
+	defer func() {
+		executors.Lock()
+		delete(executors.m, greetingJob)
+		executors.Unlock()
+	}()
+	assert.Panics(t, func() { RegisterExecutor(greetingJob, greetingExecutor{}) })
+	assert.Equal(t, "greeting", Executors()[greetingJob].Name())
+
+	assert.Equal(t, errNoGreeting, j.validation())
+	j.Properties = json.RawMessage(`{"greeting": "hello"}`)
+	assert.NoError(t, j.validation())
+
+	cache := NewMockCache()
+	assert.NoError(t, cache.Set(j))
+	j.Run(cache)
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Success, stats[0].Status)
+		assert.Equal(t, "hello from "+stats[0].Id, stats[0].Output)
+	}
+}
+
+func TestBuiltInExecutorSchemas(t *testing.T) {
+	for jobType, executor := range Executors() {
+		var schema map[string]interface{}
+		assert.NoError(t, json.Unmarshal(executor.Schema(), &schema), executor.Name())
+		assert.Equal(t, "object", schema["type"], jobType)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,7 +15,9 @@ import (
 	"time"
 
 	"github.com/mixer/clock"
+	"github.com/nextiva/nextkala/utils/cron"
 	"github.com/nextiva/nextkala/utils/iso8601"
+	"github.com/nextiva/nextkala/utils/wallclock"
 	uuid "github.com/nu7hatch/gouuid"
 
 	log "github.com/sirupsen/logrus"
@@ -25,7 +28,16 @@ var (
 
 	ErrInvalidJob       = errors.New("Invalid Local Job. Job's must contain a Name and a Command field")
 	ErrInvalidRemoteJob = errors.New("Invalid Remote Job. Job's must contain a Name and a url field")
-	ErrInvalidJobType   = errors.New("Invalid Job type. Types supported: 0 for local and 1 for remote")
+	ErrInvalidScriptJob = errors.New("Invalid Script Job. Job's must contain a Name and a script field")
+	ErrInvalidJobType   = errors.New("Invalid Job type. Types supported: 0 for local, 1 for remote, 2 for script, " +
+		"and those registered with RegisterExecutor")
+	ErrScheduleConflict = errors.New("Invalid Job. Only one of schedule and cron_schedule may be set")
+
+	ErrInvalidConcurrencyPolicy = errors.New("Invalid Job concurrency policy. Policies supported: allow, forbid, queue and replace")
+	ErrInvalidMisfirePolicy     = errors.New("Invalid Job misfire policy. Policies supported: run_once, run_all and skip")
+	ErrInvalidBlackoutPolicy    = errors.New("Invalid Job blackout policy. Policies supported: skip and defer")
+	ErrInvalidJitterMode        = errors.New("Invalid Job jitter mode. Modes supported: random and spread")
+	ErrInvalidTimeout           = errors.New("Invalid Job timeout. Timeouts must be ISO 8601 durations")
 )
 
 type Job struct {
@@ -36,6 +48,29 @@ type Job struct {
 	// e.g. "bash /path/to/my/script.sh"
 	Command string `json:"command"`
 
+	// For script jobs, instead of a Command: the script, templated like the Command, and the command
+	// to run it with, given the path of a file with the script in it.
+	// e.g. "python3" or "/bin/bash -e". Empty means DefaultInterpreter.
+	Script      string `json:"script"`
+	Interpreter string `json:"interpreter"`
+
+	// Environment variables for the command, on top of NextKala's own. Values are templated like the Command.
+	// NEXTKALA_JOB_ID and NEXTKALA_RUN_ID are set too, to the ids of the job and the run.
+	// e.g. {"LOG_LEVEL": "debug"}
+	Env map[string]string `json:"env"`
+
+	// Directory to run the command in. Empty means NextKala's working directory.
+	WorkingDir string `json:"working_dir"`
+
+	// Sent to the command on its standard input.
+	Stdin string `json:"stdin"`
+
+	// User to run the command as, instead of NextKala's own. It must be one of AllowedRunAsUsers.
+	RunAs RunAs `json:"run_as"`
+
+	// Resource limits for the command.
+	Rlimits Rlimits `json:"rlimits"`
+
 	// Email of the owner of this job
 	// e.g. "admin@example.com"
 	Owner string `json:"owner"`
@@ -52,7 +87,7 @@ type Job struct {
 	// Job that gets run after all retries have failed consecutively
 	OnFailureJob string `json:"on_failure_job"`
 
-	// ISO 8601 String
+	// ISO 8601 String, as start/interval, start/end or interval/end.
 	// e.g. "R/2014-03-08T20:00:00.000Z/PT2H"
 	Schedule     string `json:"schedule"`
 	scheduleTime time.Time
@@ -64,9 +99,57 @@ type Job struct {
 	// first run.
 	timesToRepeat int64
 
+	// Cron expression, used instead of Schedule. 5 or 6 fields (with seconds)
+	// or one of the @hourly, @daily, @weekly, @monthly or @yearly macros.
+	// e.g. "30 9 * * MON-FRI"
+	CronSchedule string `json:"cron_schedule"`
+	cronSchedule *cron.Schedule
+
+	// IANA time zone the schedule is evaluated in, e.g. "America/Los_Angeles".
+	// Start times without an offset are read in this zone, and intervals and
+	// cron expressions follow its wall clock across daylight saving time changes.
+	// Times skipped when clocks go forward run later by the length of the gap;
+	// times repeated when clocks go back run on their first occurrence only.
+	// Empty means start times without an offset are UTC and cron expressions
+	// follow the server's local time.
+	Timezone string `json:"timezone"`
+	location *time.Location
+
+	// No runs are scheduled after this time, if set.
+	NotAfter time.Time `json:"not_after"`
+
+	// When the job's runs end: the earlier of NotAfter and the last run of a Schedule
+	// given as interval/end. Zero if they don't. It's worked out from those, so setting it has no effect.
+	EndsAt time.Time `json:"ends_at"`
+
+	// ISO 8601 Duration that scheduled runs are started up to this long after they're due,
+	// so that jobs scheduled for the same time don't all start at once.
+	// How long is worked out from the job's id, so it stays the same across restarts.
+	// e.g. "PT5M"
+	Jitter string `json:"jitter"`
+	jitter *iso8601.Duration
+
+	// How runs are spread across the Jitter. Empty is the same as random.
+	JitterMode jitterMode `json:"jitter_mode"`
+
+	// ISO 8601 Duration a local job's command may run for. Past that it's stopped,
+	// and the run fails with the status TimedOut. Empty means it may run for as long as it takes.
+	// e.g. "PT30M"
+	Timeout string `json:"timeout"`
+
+	// ISO 8601 Duration a local job's command is given to stop, after SIGTERM, when it times out
+	// or its run is replaced, before it's killed with SIGKILL. Empty means DefaultKillGracePeriod.
+	KillGracePeriod string `json:"kill_grace_period"`
+
 	// Number of times to retry on failed attempt for each run.
 	Retries uint `json:"retries"`
 
+	// How long to wait before each retry.
+	RetryPolicy RetryPolicy `json:"retry_policy"`
+
+	// Which failures are retried. Empty means all of them.
+	RetryOn RetryConditions `json:"retry_on"`
+
 	// Duration in which it is safe to retry the Job.
 	Epsilon         string `json:"epsilon"`
 	epsilonDuration *iso8601.Duration
@@ -79,7 +162,8 @@ type Job struct {
 	//
 	// If this field is non-empty, then each time this
 	// job is executed, Kala will template its main
-	// content as a Go Template with the job itself as data.
+	// content as a Go Template with the job itself as data,
+	// and the run of a parent job that started it as .Parent (see ParentRun).
 	//
 	// The Command is templated for local jobs,
 	// and Url and Body in RemoteProperties.
@@ -95,15 +179,53 @@ type Job struct {
 	// until the next scheduled run time comes along.
 	ResumeAtNextScheduledTime bool `json:"resume_at_next_scheduled_time"`
 
+	// What to do about runs missed while the job was disabled (or the system inoperative).
+	// Takes precedence over ResumeAtNextScheduledTime if set.
+	MisfirePolicy misfirePolicy `json:"misfire_policy"`
+
+	// ISO 8601 Duration a run may be late by before it counts as missed;
+	// up to then it goes ahead as usual, whatever the MisfirePolicy.
+	// e.g. "PT5M"
+	MisfireThreshold string `json:"misfire_threshold"`
+	misfireThreshold *iso8601.Duration
+
+	// Most missed runs to catch up on with MisfirePolicy run_all; the latest ones are kept.
+	// Zero means DefaultMaxBackfill.
+	MaxBackfill uint `json:"max_backfill"`
+
+	// What to do when the job is due to run while a previous run is still going,
+	// e.g. a long-running job started by its schedule, by hand and by a parent job.
+	// Empty is the same as allow.
+	ConcurrencyPolicy concurrencyPolicy `json:"concurrency_policy"`
+
+	// Names of Calendars whose blackouts the job's scheduled runs are kept out of.
+	// Runs started by hand or by a parent job aren't affected.
+	Calendars []string `json:"calendars"`
+
+	// What happens to a scheduled run that falls in a blackout. Empty is the same as skip.
+	BlackoutPolicy blackoutPolicy `json:"blackout_policy"`
+
+	// Bookkeeping for ConcurrencyPolicy, made by Init. See runs.
+	runState *runState
+
 	// Meta data about successful and failed runs.
 	Metadata Metadata `json:"metadata"`
 
 	// Type of the job
-	JobType jobType `json:"type"`
+	JobType JobType `json:"type"`
+
+	// Runs of jobs with higher priorities get slots under MaxConcurrentRuns before those of others.
+	Priority int `json:"priority"`
+
+	// Slots of ResourcePools each run waits for, and holds until it's done.
+	Pools []PoolClaim `json:"pools"`
 
 	// Custom properties for the remote job type
 	RemoteProperties RemoteProperties `json:"remote_properties"`
 
+	// Properties of jobs of types registered with RegisterExecutor, as described by their Executor's Schema.
+	Properties json.RawMessage `json:"properties"`
+
 	lock sync.RWMutex
 
 	// Says if a job has been executed right numbers of time
@@ -120,13 +242,101 @@ type Job struct {
 	succeedInstantly bool
 }
 
-type jobType int
+// JobType says how a job runs: it's either a built in type, or one registered with RegisterExecutor.
+type JobType int
 
 const (
-	LocalJob jobType = iota
+	LocalJob JobType = iota
 	RemoteJob
+	// A local job running a script it carries, rather than a command.
+	ScriptJob
 )
 
+// IsLocal says whether the job runs on NextKala's host: whether it's a local or a script job.
+func (j *Job) IsLocal() bool {
+	return j.JobType == LocalJob || j.JobType == ScriptJob
+}
+
+type concurrencyPolicy string
+
+const (
+	// Runs go ahead regardless of any runs in progress.
+	ConcurrencyAllow concurrencyPolicy = "allow"
+	// Runs are skipped while a run is in progress.
+	ConcurrencyForbid concurrencyPolicy = "forbid"
+	// A run waits for the runs in progress to finish. At most one run waits at a time;
+	// any others are skipped.
+	ConcurrencyQueue concurrencyPolicy = "queue"
+	// Runs in progress are cancelled, and the run goes ahead once they have stopped.
+	ConcurrencyReplace concurrencyPolicy = "replace"
+)
+
+func (p concurrencyPolicy) valid() bool {
+	switch p {
+	case "", ConcurrencyAllow, ConcurrencyForbid, ConcurrencyQueue, ConcurrencyReplace:
+		return true
+	}
+	return false
+}
+
+type misfirePolicy string
+
+const (
+	// Missed runs are made up for with a single run, straight away.
+	MisfireRunOnce misfirePolicy = "run_once"
+	// Every missed run is made up for, one after the other, up to MaxBackfill of them.
+	MisfireRunAll misfirePolicy = "run_all"
+	// Missed runs are dropped and the job waits for its next scheduled run.
+	MisfireSkip misfirePolicy = "skip"
+)
+
+// DefaultMaxBackfill is the most missed runs a job with MisfirePolicy run_all catches up on,
+// unless it sets MaxBackfill.
+const DefaultMaxBackfill = 100
+
+func (p misfirePolicy) valid() bool {
+	switch p {
+	case "", MisfireRunOnce, MisfireRunAll, MisfireSkip:
+		return true
+	}
+	return false
+}
+
+type blackoutPolicy string
+
+const (
+	// The run is dropped, and the job waits for its first scheduled run after the blackout.
+	BlackoutSkip blackoutPolicy = "skip"
+	// The run goes ahead as soon as the blackout ends.
+	BlackoutDefer blackoutPolicy = "defer"
+)
+
+func (p blackoutPolicy) valid() bool {
+	switch p {
+	case "", BlackoutSkip, BlackoutDefer:
+		return true
+	}
+	return false
+}
+
+type jitterMode string
+
+const (
+	// Each run is delayed by a different amount, picked from a hash of the job's id and the run.
+	JitterRandom jitterMode = "random"
+	// Every run is delayed by the same amount, picked from a hash of the job's id,
+	// so that jobs are spread evenly across the Jitter.
+	JitterSpread jitterMode = "spread"
+)
+
+func (m jitterMode) valid() bool {
+	switch m {
+	case "", JitterRandom, JitterSpread:
+		return true
+	}
+	return false
+}
+
 // RemoteProperties Custom properties for the remote job type
 type RemoteProperties struct {
 	Url    string `json:"url"`
@@ -143,6 +353,25 @@ type RemoteProperties struct {
 
 	// A list of expected response codes (e.g. [200, 201])
 	ExpectedResponseCodes []int `json:"expected_response_codes"`
+
+	// Checks on the response, besides its status code, for the run to succeed.
+	Assertions ResponseAssertions `json:"assertions"`
+
+	// TLS settings for the request, over the server's DefaultTLS.
+	TLS TLSProperties `json:"tls"`
+
+	// URL of the proxy to make the request through, instead of the server's DefaultProxy,
+	// or else the one in the environment (HTTPS_PROXY, HTTP_PROXY and NO_PROXY).
+	Proxy string `json:"proxy"`
+
+	// Whether the request only starts the work, which the remote end reports the result of later
+	// with a callback, carrying the token the request was sent in CallbackTokenHeader.
+	// Without ExpectedResponseCodes, the request is expected to be accepted with 202.
+	Async bool `json:"async"`
+
+	// ISO 8601 Duration an async job's runs wait for their callback. Past that the run fails with the
+	// status TimedOut. Empty means DefaultCallbackTimeout.
+	CallbackTimeout string `json:"callback_timeout"`
 }
 
 type Metadata struct {
@@ -152,6 +381,8 @@ type Metadata struct {
 	LastError            time.Time `json:"last_error"`
 	LastAttemptedRun     time.Time `json:"last_attempted_run"`
 	NumberOfFinishedRuns uint      `json:"number_of_finished_runs"`
+	// The time the last run was scheduled for; it may have started later.
+	LastScheduledRun time.Time `json:"last_scheduled_run"`
 }
 
 // Bytes returns the byte representation of the Job.
@@ -184,6 +415,8 @@ func (j *Job) Init(cache JobCache) error {
 	j.lock.Lock()
 	defer j.lock.Unlock()
 
+	j.runs()
+
 	// use the cache's clock if available (useful for tests)
 	if clker, ok := cache.(Clocker); ok {
 		if clker.TimeSet() {
@@ -197,6 +430,18 @@ func (j *Job) Init(cache JobCache) error {
 		return err
 	}
 
+	for _, name := range j.Calendars {
+		if _, err := cache.GetCalendar(name); err != nil {
+			log.Errorf("Error getting calendar %s of job %s: %s", name, j.Name, err)
+			return err
+		}
+	}
+
+	if err := checkPoolClaims(cache, j.Pools); err != nil {
+		log.Errorf("Error checking the resource pools of job %s: %s", j.Name, err)
+		return err
+	}
+
 	// set the id if not provided.
 	err = j.setID()
 	if err != nil {
@@ -225,7 +470,7 @@ func (j *Job) Init(cache JobCache) error {
 	}
 
 	// TODO: Delete from cache after running.
-	if j.Schedule == "" {
+	if !j.hasSchedule() {
 		// If schedule is empty, its a one-off job.
 		go j.Run(cache)
 		return nil
@@ -249,16 +494,90 @@ func (j *Job) Init(cache JobCache) error {
 	return nil
 }
 
-// InitDelayDuration is used to parsed the iso8601 Schedule notation into its relevant fields in the Job struct.
+// InitDelayDuration is used to parsed the iso8601 Schedule notation (or the CronSchedule expression)
+// into its relevant fields in the Job struct.
 // If checkTime is true, then it will return an error if the Scheduled time has passed.
 func (j *Job) InitDelayDuration(checkTime bool) error {
 	j.lock.Lock()
 	defer j.lock.Unlock()
 
-	if j.Schedule == "" {
+	if !j.hasSchedule() {
 		return nil
 	}
 
+	var err error
+	j.location = nil
+	if j.Timezone != "" {
+		j.location, err = time.LoadLocation(j.Timezone)
+		if err != nil {
+			log.Errorf("Error loading timezone %q: %s", j.Timezone, err)
+			return err
+		}
+	}
+
+	j.EndsAt = j.NotAfter
+	if j.CronSchedule != "" {
+		if j.Schedule != "" {
+			return ErrScheduleConflict
+		}
+		err = j.initCronSchedule()
+	} else {
+		err = j.initIsoSchedule(checkTime)
+	}
+	if err != nil {
+		return err
+	}
+	if checkTime && !j.EndsAt.IsZero() && j.EndsAt.Before(j.clk.Time().Now()) {
+		return fmt.Errorf("Job %s:%s schedule ended at %s", j.Name, j.Id, j.EndsAt)
+	}
+
+	if j.Epsilon != "" {
+		j.epsilonDuration, err = iso8601.FromString(j.Epsilon)
+		if err != nil {
+			log.Errorf("Error converting j.Epsilon to iso8601.Duration: %s", err)
+			return err
+		}
+	}
+
+	j.misfireThreshold = nil
+	if j.MisfireThreshold != "" {
+		j.misfireThreshold, err = iso8601.FromString(j.MisfireThreshold)
+		if err != nil {
+			log.Errorf("Error converting j.MisfireThreshold to iso8601.Duration: %s", err)
+			return err
+		}
+	}
+
+	j.jitter = nil
+	if j.Jitter != "" {
+		j.jitter, err = iso8601.FromString(j.Jitter)
+		if err != nil {
+			log.Errorf("Error converting j.Jitter to iso8601.Duration: %s", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// initCronSchedule parses the CronSchedule expression. Cron jobs repeat forever.
+func (j *Job) initCronSchedule() error {
+	var err error
+	j.cronSchedule, err = cron.Parse(j.CronSchedule)
+	if err != nil {
+		log.Errorf("Error parsing cron schedule %q: %s", j.CronSchedule, err)
+		return err
+	}
+	j.timesToRepeat = -1
+
+	if j.cronSchedule.Next(j.clk.Time().Now()).IsZero() {
+		return fmt.Errorf("Job %s:%s cron schedule %q never fires", j.Name, j.Id, j.CronSchedule)
+	}
+	log.Debugf("Job %s:%s scheduled with cron expression %q", j.Name, j.Id, j.CronSchedule)
+	return nil
+}
+
+// initIsoSchedule parses the ISO 8601 Schedule into its start time, repetitions and interval.
+func (j *Job) initIsoSchedule(checkTime bool) error {
 	var err error
 	splitTime := strings.Split(j.Schedule, "/")
 	if len(splitTime) != 3 { //nolint:gomnd
@@ -279,14 +598,15 @@ func (j *Job) InitDelayDuration(checkTime bool) error {
 		}
 	}
 
-	j.scheduleTime, err = time.Parse(time.RFC3339, splitTime[1])
+	if strings.HasPrefix(splitTime[1], "P") {
+		err = j.initIntervalEndSchedule(splitTime[1], splitTime[2], checkTime)
+	} else {
+		err = j.initStartSchedule(splitTime[1], splitTime[2])
+	}
 	if err != nil {
-		j.scheduleTime, err = time.Parse(RFC3339WithoutTimezone, splitTime[1])
-		if err != nil {
-			log.Errorf("Error converting scheduleTime to a time.Time: %s", err)
-			return err
-		}
+		return err
 	}
+
 	if checkTime {
 		diff := j.scheduleTime.Sub(j.clk.Time().Now())
 		if diff < 0 {
@@ -295,26 +615,115 @@ func (j *Job) InitDelayDuration(checkTime bool) error {
 	}
 	log.Debugf("Job %s:%s scheduled", j.Name, j.Id)
 	log.Debugf("Starting %s will repeat for %d", j.scheduleTime, j.timesToRepeat)
+	if j.delayDuration != nil {
+		log.Debugf("Delay duration is %s", j.delayDuration.RelativeTo(j.clk.Time().Now()))
+	}
+	return nil
+}
+
+// initStartSchedule parses a schedule given as start/interval, or as start/end, in which case
+// the interval is the time from start to end.
+func (j *Job) initStartSchedule(start, next string) error {
+	var err error
+	j.scheduleTime, err = j.parseScheduleTime(start)
+	if err != nil {
+		return err
+	}
+
+	if j.timesToRepeat == 0 {
+		return nil
+	}
 
-	if j.timesToRepeat != 0 {
-		j.delayDuration, err = iso8601.FromString(splitTime[2])
+	if strings.HasPrefix(next, "P") {
+		j.delayDuration, err = iso8601.FromString(next)
 		if err != nil {
 			log.Errorf("Error converting delayDuration to a iso8601.Duration: %s", err)
 			return err
 		}
-		log.Debugf("Delay duration is %s", j.delayDuration.RelativeTo(j.clk.Time().Now()))
+		return nil
 	}
 
-	if j.Epsilon != "" {
-		j.epsilonDuration, err = iso8601.FromString(j.Epsilon)
-		if err != nil {
-			log.Errorf("Error converting j.Epsilon to iso8601.Duration: %s", err)
-			return err
+	end, err := j.parseScheduleTime(next)
+	if err != nil {
+		return err
+	}
+	if end.Sub(j.scheduleTime) < time.Second {
+		return fmt.Errorf("Job %s:%s schedule must end at least a second after it starts", j.Name, j.Id)
+	}
+	j.delayDuration = &iso8601.Duration{Seconds: int(end.Sub(j.scheduleTime) / time.Second)}
+	return nil
+}
+
+// initIntervalEndSchedule parses a schedule given as interval/end. The runs are one interval apart,
+// the last one interval before end. With a number of repetitions, they work back from there;
+// otherwise they start with the first one still to come, or, if the job has run, after its last run.
+func (j *Job) initIntervalEndSchedule(interval, end string, checkTime bool) error {
+	// The runs are fixed by the end, rather than following on from each other as they otherwise
+	// would without a Timezone, so that they don't drift past it.
+	if j.location == nil {
+		j.location = time.UTC
+	}
+
+	var err error
+	j.delayDuration, err = iso8601.FromString(interval)
+	if err != nil {
+		log.Errorf("Error converting delayDuration to a iso8601.Duration: %s", err)
+		return err
+	}
+	if j.delayDuration.IsZero() {
+		return fmt.Errorf("Job %s:%s schedule interval is empty", j.Name, j.Id)
+	}
+
+	endTime, err := j.parseScheduleTime(end)
+	if err != nil {
+		return err
+	}
+
+	back := j.delayDuration.Negated()
+	previous := func(t time.Time) time.Time { return back.AddIn(t, j.location) }
+
+	last := previous(endTime)
+	if j.EndsAt.IsZero() || last.Before(j.EndsAt) {
+		j.EndsAt = last
+	}
+
+	j.scheduleTime = last
+	if j.hasFixedRepetitions() {
+		for i := int64(0); i < j.timesToRepeat; i++ {
+			j.scheduleTime = previous(j.scheduleTime)
 		}
+		return nil
+	}
+
+	isAhead := func(t time.Time) bool { return !t.Before(j.clk.Time().Now()) }
+	if !checkTime && !j.Metadata.LastAttemptedRun.IsZero() {
+		isAhead = func(t time.Time) bool { return t.After(j.Metadata.LastAttemptedRun) }
+	}
+	for run := previous(j.scheduleTime); isAhead(run); run = previous(run) {
+		j.scheduleTime = run
 	}
 	return nil
 }
 
+// parseScheduleTime parses a start or end time of the Schedule. One without an offset is
+// a reading of the job's wall clock, or UTC if it doesn't have a Timezone.
+func (j *Job) parseScheduleTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(RFC3339WithoutTimezone, value)
+		if err != nil {
+			log.Errorf("Error converting scheduleTime to a time.Time: %s", err)
+			return time.Time{}, err
+		}
+		if j.location != nil {
+			t = wallclock.Resolve(t, j.location)
+		}
+	} else if j.location != nil {
+		t = t.In(j.location)
+	}
+	return t, nil
+}
+
 // StartWaiting begins a timer for when it should execute the Jobs .Run() method.
 func (j *Job) StartWaiting(cache JobCache, justRan bool) {
 	waitDuration := j.GetWaitDuration()
@@ -324,20 +733,161 @@ func (j *Job) StartWaiting(cache JobCache, justRan bool) {
 
 	log.Infof("Job %s:%s repeating in %s", j.Name, j.Id, waitDuration)
 
-	j.NextRunAt = j.clk.Time().Now().Add(waitDuration)
-
-	jobRun := func() { j.Run(cache) }
-	j.jobTimer = j.clk.Time().AfterFunc(waitDuration, jobRun)
+	due := j.clk.Time().Now().Add(waitDuration)
+	j.waitUntil(cache, due, j.jitterDelay(due))
 
 	if justRan && j.ranChan != nil {
 		j.ranChan <- struct{}{}
 	}
 }
 
+// waitUntil sets the job's timer for its next scheduled run, due at t and started delay after that.
+// If t is after EndsAt, the job is done instead. The job must be locked.
+func (j *Job) waitUntil(cache JobCache, t time.Time, delay time.Duration) {
+	// A run started by hand or by a parent job reschedules the job too;
+	// don't leave the timer from before it behind.
+	if j.jobTimer != nil {
+		j.jobTimer.Stop()
+	}
+
+	if j.endedBy(t) {
+		log.Infof("Job %s:%s has no runs left before %s", j.Name, j.Id, j.EndsAt)
+		j.NextRunAt = time.Time{}
+		j.IsDone = true
+		return
+	}
+	j.NextRunAt = t
+
+	jobRun := func() { j.runScheduled(cache) }
+	j.jobTimer = j.clk.Time().AfterFunc(t.Add(delay).Sub(j.clk.Time().Now()), jobRun)
+}
+
+// jitterDelay returns how long after it's due, at due, a scheduled run is started, given the job's Jitter.
+func (j *Job) jitterDelay(due time.Time) time.Duration {
+	if j.jitter == nil || !j.hasSchedule() {
+		return 0
+	}
+	window := j.jitter.RelativeTo(due)
+	if window <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(j.Id))
+	if j.JitterMode != JitterSpread {
+		_, _ = h.Write([]byte(due.UTC().Format(time.RFC3339Nano)))
+	}
+	return time.Duration(h.Sum64() % uint64(window))
+}
+
+// runScheduled runs the job for its scheduled run, unless that falls in a blackout of one
+// of its Calendars. The run is then skipped or deferred to the end of the blackout,
+// depending on the BlackoutPolicy, and a stat records which.
+// Calendars are consulted when the run is due, so that changes to them made in the meantime count.
+func (j *Job) runScheduled(cache JobCache) {
+	j.lock.Lock()
+	now := j.clk.Time().Now()
+	calendar, end, blackedOut := j.blackout(cache, now)
+	if !blackedOut {
+		j.lock.Unlock()
+		j.Run(cache)
+		return
+	}
+	defer j.lock.Unlock()
+
+	stat := NewJobStat(j.Id)
+	stat.RanAt = now
+	var next time.Time
+	if j.BlackoutPolicy == BlackoutDefer {
+		stat.Status = Status.Deferred
+		next = end
+	} else {
+		stat.Status = Status.Skipped
+		next = j.nextRunFrom(j.NextRunAt, end)
+	}
+	stat.Output = fmt.Sprintf("Run scheduled for %s falls in a blackout of calendar %s until %s.",
+		j.NextRunAt.Format(time.RFC3339), calendar, end.Format(time.RFC3339))
+	log.Infof("Job %s:%s %s: %s", j.Name, j.Id, strings.ToLower(string(stat.Status)), stat.Output)
+
+	if err := cache.SaveRun(stat); err != nil {
+		log.Warnf("Unable to save stats for run %+v", stat)
+	}
+
+	if next.IsZero() {
+		// That was the job's only run.
+		j.IsDone = true
+		if j.ranChan != nil {
+			j.ranChan <- struct{}{}
+		}
+		return
+	}
+	log.Infof("Job %s:%s next run at %s", j.Name, j.Id, next)
+	j.waitUntil(cache, next, 0)
+	if j.ranChan != nil {
+		j.ranChan <- struct{}{}
+	}
+}
+
+// blackout returns the name of the first of the job's Calendars that has t in a blackout,
+// and when the blackout ends, counting blackouts of the other calendars that run into it.
+// Calendars that can't be read are ignored, so that a missing one doesn't stop the job.
+func (j *Job) blackout(cache JobCache, t time.Time) (string, time.Time, bool) {
+	calendars := make([]*Calendar, 0, len(j.Calendars))
+	for _, name := range j.Calendars {
+		c, err := cache.GetCalendar(name)
+		if err != nil {
+			log.Warnf("Job %s:%s unable to get calendar %s: %s", j.Name, j.Id, name, err)
+			continue
+		}
+		calendars = append(calendars, c)
+	}
+
+	name, end := "", t
+	for i := 0; i < maxBlackoutMerges; i++ {
+		extended := false
+		for _, c := range calendars {
+			if e, ok := c.BlackoutEnd(end); ok {
+				if name == "" {
+					name = c.Name
+				}
+				end, extended = e, true
+			}
+		}
+		if !extended {
+			break
+		}
+	}
+	return name, end, name != ""
+}
+
+// nextRunFrom returns the first scheduled run at or after t, given that due was one,
+// or the zero time if the job has no more runs.
+func (j *Job) nextRunFrom(due, t time.Time) time.Time {
+	switch {
+	case j.cronSchedule != nil:
+		return j.nextRunAfter(t.Add(-time.Nanosecond))
+	case j.delayDuration == nil || j.delayDuration.IsZero():
+		return time.Time{}
+	case j.location != nil:
+		return j.nextRunAfter(t.Add(-time.Nanosecond))
+	}
+	for due.Before(t) {
+		due = j.delayDuration.Add(due)
+	}
+	return due
+}
+
 func (j *Job) GetWaitDuration() time.Duration {
 	j.lock.RLock()
 	defer j.lock.RUnlock()
 
+	if j.cronSchedule != nil {
+		if j.MisfirePolicy != "" {
+			return j.misfireWaitDuration()
+		}
+		return j.cronWaitDuration()
+	}
+
 	waitDuration := time.Duration(j.scheduleTime.UnixNano() - j.clk.Time().Now().UnixNano())
 
 	if waitDuration >= 0 {
@@ -348,6 +898,14 @@ func (j *Job) GetWaitDuration() time.Duration {
 		return 0
 	}
 
+	if j.MisfirePolicy != "" {
+		return j.misfireWaitDuration()
+	}
+
+	if j.location != nil {
+		return j.zonedWaitDuration()
+	}
+
 	if j.ResumeAtNextScheduledTime {
 
 		// In cases where the scheduled point is very long ago,
@@ -372,6 +930,10 @@ func (j *Job) GetWaitDuration() time.Duration {
 		waitDuration = j.delayDuration.RelativeTo(j.clk.Time().Now())
 	} else {
 		lastRun := j.Metadata.LastAttemptedRun
+		// Otherwise the Jitter would make each run later than the one before.
+		if j.jitter != nil && !j.Metadata.LastScheduledRun.IsZero() {
+			lastRun = j.Metadata.LastScheduledRun
+		}
 		// Needs to be recalculated each time because of Months.
 		lastRun = j.delayDuration.Add(lastRun)
 		waitDuration = lastRun.Sub(j.clk.Time().Now())
@@ -380,6 +942,192 @@ func (j *Job) GetWaitDuration() time.Duration {
 	return waitDuration
 }
 
+// NextRuns returns the times of the job's next count runs, or fewer if it doesn't have that many left,
+// in the job's Timezone if it has one.
+// It plays the schedule forward with GetWaitDuration on a copy of the job, assuming each run starts
+// when it's due, after any Jitter.
+func (j *Job) NextRuns(count int) ([]time.Time, error) {
+	j.lock.RLock()
+	preview := &Job{
+		Id:                        j.Id,
+		Schedule:                  j.Schedule,
+		CronSchedule:              j.CronSchedule,
+		Timezone:                  j.Timezone,
+		Disabled:                  j.Disabled,
+		ResumeAtNextScheduledTime: j.ResumeAtNextScheduledTime,
+		MisfirePolicy:             j.MisfirePolicy,
+		MisfireThreshold:          j.MisfireThreshold,
+		MaxBackfill:               j.MaxBackfill,
+		NotAfter:                  j.NotAfter,
+		Jitter:                    j.Jitter,
+		JitterMode:                j.JitterMode,
+		Metadata:                  j.Metadata,
+	}
+	clk := clock.NewMockClock(j.clk.Time().Now())
+	j.lock.RUnlock()
+
+	runs := []time.Time{}
+	if !preview.hasSchedule() {
+		return runs, nil
+	}
+
+	preview.clk.SetClock(clk)
+	if err := preview.InitDelayDuration(false); err != nil {
+		return nil, err
+	}
+
+	for len(runs) < count && preview.ShouldStartWaiting() {
+		due := clk.Now().Add(preview.GetWaitDuration())
+		if preview.endedBy(due) {
+			break
+		}
+		run := due.Add(preview.jitterDelay(due))
+		if run.Before(clk.Now()) {
+			run = clk.Now()
+		}
+		if preview.location != nil {
+			run = run.In(preview.location)
+		}
+		runs = append(runs, run)
+
+		preview.Metadata.LastAttemptedRun = run
+		preview.Metadata.LastScheduledRun = due
+		preview.Metadata.NumberOfFinishedRuns++
+		// A real run takes some time; without any, a run at the start time would be due again.
+		clk.SetTime(run.Add(time.Nanosecond))
+	}
+	return runs, nil
+}
+
+// zonedWaitDuration is GetWaitDuration for jobs with a Timezone, once the start time has passed.
+// Runs are computed from the start time on the zone's wall clock rather than from the
+// previous run, so that a run moved by a daylight saving time change doesn't move the ones after it.
+func (j *Job) zonedWaitDuration() time.Duration {
+	now := j.clk.Time().Now()
+
+	// Same special case as in GetWaitDuration: there would be no next run to find.
+	if j.scheduleTime.IsZero() || j.delayDuration.IsZero() {
+		return 0
+	}
+
+	if j.ResumeAtNextScheduledTime {
+		return j.nextZonedRunAfter(now).Sub(now)
+	}
+
+	if j.Metadata.LastAttemptedRun.IsZero() {
+		return j.delayDuration.AddIn(now, j.location).Sub(now)
+	}
+
+	// Negative if a run was missed, in which case the job runs immediately.
+	return j.nextZonedRunAfter(j.Metadata.LastAttemptedRun).Sub(now)
+}
+
+// nextZonedRunAfter returns the first scheduled run strictly after t.
+func (j *Job) nextZonedRunAfter(t time.Time) time.Time {
+	reading := wallclock.Naive(j.scheduleTime)
+	run := j.scheduleTime
+	for !run.After(t) {
+		reading = j.delayDuration.Add(reading)
+		run = wallclock.Resolve(reading, j.location)
+	}
+	return run
+}
+
+// misfireWaitDuration is GetWaitDuration for jobs with a MisfirePolicy, once the start time has passed.
+// It is negative if the job is due to make up for a missed run; StartWaiting then runs it immediately,
+// with NextRunAt still saying which run it is.
+func (j *Job) misfireWaitDuration() time.Duration {
+	now := j.clk.Time().Now()
+
+	// Same special case as in GetWaitDuration: there would be no next run to find.
+	if j.cronSchedule == nil && (j.scheduleTime.IsZero() || j.delayDuration.IsZero()) {
+		return 0
+	}
+
+	// Catching up on every missed run means carrying on from the last one made up for,
+	// rather than from when that actually ran. Otherwise the Jitter would make each run later than the one before.
+	last := j.Metadata.LastAttemptedRun
+	if (j.MisfirePolicy == MisfireRunAll || j.jitter != nil) && !j.Metadata.LastScheduledRun.IsZero() {
+		last = j.Metadata.LastScheduledRun
+	}
+
+	var due time.Time
+	switch {
+	case !last.IsZero():
+		due = j.nextRunAfter(last)
+	case j.cronSchedule != nil:
+		due = j.nextRunAfter(now)
+	default:
+		due = j.scheduleTime
+	}
+
+	if !due.Before(now) {
+		return due.Sub(now)
+	}
+	if j.misfireThreshold != nil && now.Sub(due) <= j.misfireThreshold.RelativeTo(due) {
+		return due.Sub(now)
+	}
+
+	switch j.MisfirePolicy {
+	case MisfireSkip:
+		return j.nextRunFrom(due, now).Sub(now)
+	case MisfireRunAll:
+		maxBackfill := j.MaxBackfill
+		if maxBackfill == 0 {
+			maxBackfill = DefaultMaxBackfill
+		}
+		// Keep the latest maxBackfill of the missed runs.
+		missed := make([]time.Time, 0, maxBackfill)
+		for ; due.Before(now) && !j.endedBy(due); due = j.nextRunAfter(due) {
+			if uint(len(missed)) == maxBackfill {
+				missed = missed[1:]
+			}
+			missed = append(missed, due)
+		}
+		if len(missed) == 0 {
+			return due.Sub(now)
+		}
+		return missed[0].Sub(now)
+	default:
+		return due.Sub(now)
+	}
+}
+
+// nextRunAfter returns the first scheduled run after t. For ISO 8601 schedules without
+// a Timezone that is one interval after t, as elsewhere in GetWaitDuration.
+func (j *Job) nextRunAfter(t time.Time) time.Time {
+	switch {
+	case j.cronSchedule != nil:
+		if j.location != nil {
+			t = t.In(j.location)
+		}
+		return j.cronSchedule.Next(t)
+	case j.location != nil:
+		return j.nextZonedRunAfter(t)
+	default:
+		return j.delayDuration.Add(t)
+	}
+}
+
+// cronWaitDuration returns the time until the next activation of the cron schedule.
+// As with ISO 8601 schedules, an activation missed while the job was disabled
+// (or the system inoperative) runs immediately unless ResumeAtNextScheduledTime is set.
+func (j *Job) cronWaitDuration() time.Duration {
+	now := j.clk.Time().Now()
+	if j.location != nil {
+		now = now.In(j.location)
+	}
+
+	if !j.ResumeAtNextScheduledTime && !j.Metadata.LastAttemptedRun.IsZero() {
+		missed := j.cronSchedule.Next(j.Metadata.LastAttemptedRun.In(now.Location()))
+		if !missed.IsZero() && missed.Before(now) {
+			return 0
+		}
+	}
+
+	return j.cronSchedule.Next(now).Sub(now)
+}
+
 // Disable stops the job from running by stopping its jobTimer. It also sets Job.Disabled to true,
 // which is reflected in the UI.
 func (j *Job) Disable(cache JobCache) error {
@@ -476,13 +1224,33 @@ func (j *Job) RunOnFailureJob(cache JobCache) {
 }
 
 func (j *Job) Run(cache JobCache) {
+	j.runAfter(cache, nil)
+}
 
-	j.lock.RLock()
-	jobRunner := &JobRunner{job: j, meta: j.Metadata}
-	j.lock.RUnlock()
+// runAfter runs the job as Run does, as a dependent job of the parent run, or nil if none.
+func (j *Job) runAfter(cache JobCache, parent *ParentRun) {
+	j.run(cache, &JobRunner{job: j, parent: parent})
+}
+
+// resume carries on with the job's run that the server stopped while it waited for its callback, as if its
+// attempt had ended with err: it's retried, or else recorded as failed and the OnFailureJob started.
+func (j *Job) resume(cache JobCache, stat *JobStat, err error) {
+	j.run(cache, &JobRunner{job: j, resumed: stat, resumeErr: err})
+}
+
+func (j *Job) run(cache JobCache, jobRunner *JobRunner) {
+	// Only once the results are in, so that a queued run starts from up to date metadata.
+	defer jobRunner.release()
 
 	newStat, newMeta, err := jobRunner.Run(cache)
-	if err != nil {
+	if err == ErrJobSkipped {
+		// Whichever run is in progress takes care of rescheduling.
+		if err := cache.SaveRun(newStat); err != nil {
+			log.Warnf("Unable to save stats for run %+v", newStat)
+		}
+		return
+	}
+	if err != nil && err != ErrJobReplaced && err != ErrJobCancelled {
 		j.lock.RLock()
 		j.RunOnFailureJob(cache)
 		j.lock.RUnlock()
@@ -512,7 +1280,9 @@ func (j *Job) Run(cache JobCache) {
 		j.lock.Lock()
 	}
 
-	if j.ShouldStartWaiting() {
+	if jobRunner.resumed != nil {
+		// The job was scheduled as the server started.
+	} else if j.ShouldStartWaiting() {
 		go j.StartWaiting(cache, true)
 	} else {
 		j.IsDone = true
@@ -534,12 +1304,38 @@ func (j *Job) StopTimer() {
 	}
 }
 
+// CancelRun cancels the job's run in progress with the given id, killing its command or aborting its request.
+// The run is recorded as Cancelled, without retrying it or running the OnFailureJob.
+func (j *Job) CancelRun(runID string) error {
+	runs := j.runs()
+	runs.Lock()
+	defer runs.Unlock()
+
+	for runner, cancel := range runs.running {
+		if runner.runID == runID {
+			runner.cancelled = true
+			cancel()
+			return nil
+		}
+	}
+	return ErrRunNotInProgress
+}
+
 func (j *Job) RunCmd() (string, error) {
 	j.lock.RLock()
 	defer j.lock.RUnlock()
 
 	jobRunner := &JobRunner{job: j}
-	return jobRunner.runCmd()
+	return jobRunner.LocalRun()
+}
+
+func (j *Job) hasSchedule() bool {
+	return j.Schedule != "" || j.CronSchedule != ""
+}
+
+// endedBy says whether t is after the job's runs end.
+func (j *Job) endedBy(t time.Time) bool {
+	return !j.EndsAt.IsZero() && t.After(j.EndsAt)
 }
 
 func (j *Job) hasFixedRepetitions() bool {
@@ -551,6 +1347,10 @@ func (j *Job) ShouldStartWaiting() bool {
 		return false
 	}
 
+	if j.endedBy(j.clk.Time().Now()) {
+		return false
+	}
+
 	if j.hasFixedRepetitions() && int(j.timesToRepeat) < int(j.Metadata.NumberOfFinishedRuns) {
 		return false
 	}
@@ -559,6 +1359,18 @@ func (j *Job) ShouldStartWaiting() bool {
 
 // TryTemplatize returns a string based on a template using data defined in the Job definition.
 func (j *Job) TryTemplatize(content string) (string, error) {
+	return j.templatize(content, nil)
+}
+
+// templateData is what a job's templates are executed with: the job itself,
+// and the parent job's run that started the run, if any.
+type templateData struct {
+	*Job
+	Parent *ParentRun
+}
+
+// templatize is TryTemplatize, for a run started by the parent run, or nil if none.
+func (j *Job) templatize(content string, parent *ParentRun) (string, error) {
 	delims := j.TemplateDelimiters
 
 	if delims == "" {
@@ -580,8 +1392,11 @@ func (j *Job) TryTemplatize(content string) (string, error) {
 		return "", fmt.Errorf("Error parsing template: %v", err)
 	}
 
+	if parent == nil {
+		parent = noParentRun()
+	}
 	b := bytes.NewBuffer(nil)
-	if err := t.Execute(b, j); err != nil {
+	if err := t.Execute(b, templateData{Job: j, Parent: parent}); err != nil {
 		return "", fmt.Errorf("Error executing template: %v", err)
 	}
 
@@ -589,18 +1404,49 @@ func (j *Job) TryTemplatize(content string) (string, error) {
 }
 
 // SetHeaders sets default and user specific headers to the http request
+// The job's own headers are copied rather than changed, as they're shared by its runs.
 func (j *Job) SetHeaders(req *http.Request, token string) {
-	if j.RemoteProperties.Headers == nil {
-		j.RemoteProperties.Headers = http.Header{}
+	req.Header = j.RemoteProperties.Headers.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
 	}
 	if token != "" {
-		j.RemoteProperties.Headers.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 	// A valid assumption is that the user is sending something in json cause we're past 2017
-	if j.RemoteProperties.Headers["Content-Type"] == nil {
-		j.RemoteProperties.Headers["Content-Type"] = []string{"application/json"}
+	if req.Header["Content-Type"] == nil {
+		req.Header["Content-Type"] = []string{"application/json"}
 	}
-	req.Header = j.RemoteProperties.Headers
+}
+
+// DefaultKillGracePeriod is how long a local job's command is given to stop before it's killed,
+// unless the job sets a KillGracePeriod.
+const DefaultKillGracePeriod = 10 * time.Second
+
+// localTimeout returns how long a local job's command may run for, or zero if there's no limit.
+func (j *Job) localTimeout() time.Duration {
+	timeout, err := iso8601.FromString(j.Timeout)
+	if j.Timeout == "" || err != nil {
+		return 0
+	}
+	return timeout.RelativeTo(j.clk.Time().Now())
+}
+
+func (j *Job) killGracePeriod() time.Duration {
+	grace, err := iso8601.FromString(j.KillGracePeriod)
+	if j.KillGracePeriod == "" || err != nil {
+		return DefaultKillGracePeriod
+	}
+	return grace.RelativeTo(j.clk.Time().Now())
+}
+
+// validDuration says whether d is empty or an ISO 8601 Duration.
+func validDuration(d string) bool {
+	if d == "" {
+		return true
+	}
+	_, err := iso8601.FromString(d)
+	return err == nil
 }
 
 // ResponseTimeout sets a default timeout if none specified
@@ -616,13 +1462,43 @@ func (j *Job) ResponseTimeout() time.Duration {
 
 func (j *Job) validation() error {
 	var err error
-	switch {
-	case j.JobType == LocalJob && (j.Name == "" || j.Command == ""):
-		err = ErrInvalidJob
-	case j.JobType == RemoteJob && (j.Name == "" || j.RemoteProperties.Url == ""):
-		err = ErrInvalidRemoteJob
-	case j.JobType != LocalJob && j.JobType != RemoteJob:
+	if executor, ok := executorFor(j.JobType); ok {
+		err = executor.Validate(j)
+	} else {
 		err = ErrInvalidJobType
+	}
+
+	switch {
+	case err != nil:
+	case !j.ConcurrencyPolicy.valid():
+		err = ErrInvalidConcurrencyPolicy
+	case !j.MisfirePolicy.valid():
+		err = ErrInvalidMisfirePolicy
+	case !j.BlackoutPolicy.valid():
+		err = ErrInvalidBlackoutPolicy
+	case !j.JitterMode.valid():
+		err = ErrInvalidJitterMode
+	case j.RetryPolicy.validate() != nil:
+		err = ErrInvalidRetryPolicy
+	case j.RetryOn.validate() != nil:
+		err = ErrInvalidRetryOn
+	case !validDuration(j.Timeout) || !validDuration(j.KillGracePeriod) ||
+		!validDuration(j.RemoteProperties.CallbackTimeout):
+		err = ErrInvalidTimeout
+	case !j.RunAs.allowed():
+		err = ErrRunAsNotAllowed
+	case !validPoolClaims(j.Pools):
+		err = ErrInvalidPoolClaim
+	case j.RemoteProperties.Assertions.validate() != nil:
+		err = ErrInvalidAssertions
+	case !j.RemoteProperties.TLS.valid():
+		err = ErrInvalidTLS
+	case !j.RemoteProperties.TLS.allowed():
+		err = ErrInsecureSkipVerifyNotAllowed
+	case !j.RemoteProperties.TLS.filesAllowed():
+		err = ErrTLSFileNotAllowed
+	case !validProxy(j.RemoteProperties.Proxy):
+		err = ErrInvalidProxy
 	default:
 		return nil
 	}
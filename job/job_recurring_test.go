@@ -171,3 +171,254 @@ func TestRecur(t *testing.T) {
 	}
 
 }
+
+func TestRecurCron(t *testing.T) {
+	// Friday
+	now := parseTimeInLocation(t, "2020-Jan-17 08:59", "UTC")
+	clk := clock.NewMockClock(now)
+
+	cache := NewMockCache()
+	cache.Clock.SetClock(clk)
+
+	j := GetMockJob()
+	j.CronSchedule = "0 9 * * MON-FRI"
+	j.succeedInstantly = true
+	assert.NoError(t, j.Init(cache))
+	j.ranChan = make(chan struct{})
+
+	checkpoints := []string{
+		"2020-Jan-17 09:00",
+		"2020-Jan-20 09:00",
+		"2020-Jan-21 09:00",
+	}
+
+	for i, chk := range checkpoints {
+		clk.SetTime(parseTimeInLocation(t, chk, "UTC").Add(-time.Second))
+
+		select {
+		case <-j.ranChan:
+			t.Fatalf("Expected job not run before checkpoint %d.", i)
+		case <-time.After(time.Millisecond * 500):
+		}
+
+		clk.AddTime(time.Second * 2)
+		awaitJobRan(t, j, time.Second*5)
+
+		j.lock.RLock()
+		assert.Equal(t, i+1, int(j.Metadata.SuccessCount))
+		assert.Equal(t, false, j.IsDone)
+		j.lock.RUnlock()
+	}
+}
+
+var zonedRecurTableTests = []struct {
+	Name         string
+	Timezone     string
+	Schedule     string
+	CronSchedule string
+	Runs         []string
+}{
+	{
+		Name:     "Daily across clocks going forward",
+		Timezone: "America/Los_Angeles",
+		Schedule: "R/2020-03-07T02:30:00/P1D",
+		Runs: []string{
+			"2020-03-07T02:30:00-08:00",
+			// 02:30 doesn't exist on the 8th.
+			"2020-03-08T03:30:00-07:00",
+			"2020-03-09T02:30:00-07:00",
+		},
+	},
+	{
+		Name:     "Daily across clocks going back",
+		Timezone: "America/Los_Angeles",
+		Schedule: "R/2020-10-31T01:30:00/P1D",
+		Runs: []string{
+			"2020-10-31T01:30:00-07:00",
+			// 01:30 happens twice on the 1st.
+			"2020-11-01T01:30:00-07:00",
+			"2020-11-02T01:30:00-08:00",
+		},
+	},
+	{
+		Name:     "Start time with an offset",
+		Timezone: "America/New_York",
+		Schedule: "R/2020-03-07T07:00:00Z/PT24H",
+		Runs: []string{
+			"2020-03-07T02:00:00-05:00",
+			"2020-03-08T03:00:00-04:00",
+			"2020-03-09T02:00:00-04:00",
+		},
+	},
+	{
+		Name:         "Cron across clocks going forward",
+		Timezone:     "Europe/Berlin",
+		CronSchedule: "30 2 * * *",
+		Runs: []string{
+			"2020-03-28T02:30:00+01:00",
+			"2020-03-29T03:30:00+02:00",
+			"2020-03-30T02:30:00+02:00",
+		},
+	},
+	{
+		Name:         "Hourly cron across clocks going back",
+		Timezone:     "America/Los_Angeles",
+		CronSchedule: "0 0-3 * * *",
+		Runs: []string{
+			"2020-11-01T00:00:00-07:00",
+			"2020-11-01T01:00:00-07:00",
+			"2020-11-01T02:00:00-08:00",
+			"2020-11-01T03:00:00-08:00",
+		},
+	},
+}
+
+// Runs zoned jobs on the mock clock, checking each run happens at the expected instant and not a second before.
+func TestRecurTimezone(t *testing.T) {
+	for _, testStruct := range zonedRecurTableTests {
+		func() {
+			runs := make([]time.Time, 0, len(testStruct.Runs))
+			for _, r := range testStruct.Runs {
+				run, err := time.Parse(time.RFC3339, r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				runs = append(runs, run)
+			}
+
+			clk := clock.NewMockClock(runs[0].Add(-time.Minute))
+			cache := NewMockCache()
+			cache.Clock.SetClock(clk)
+
+			j := GetMockJob()
+			j.Timezone = testStruct.Timezone
+			j.Schedule = testStruct.Schedule
+			j.CronSchedule = testStruct.CronSchedule
+			j.succeedInstantly = true
+			assert.NoError(t, j.Init(cache), testStruct.Name)
+			j.ranChan = make(chan struct{})
+
+			for i, run := range runs {
+				clk.SetTime(run.Add(-time.Second))
+
+				select {
+				case <-j.ranChan:
+					t.Fatalf("Expected job not run before %s in test %s.", run, testStruct.Name)
+				case <-time.After(time.Millisecond * 300):
+				}
+
+				// Just past the run, as a job starting exactly at its start time would go again immediately.
+				clk.SetTime(run.Add(time.Millisecond))
+
+				select {
+				case <-j.ranChan:
+				case <-time.After(time.Second * 5):
+					t.Fatalf("Expected job to have run at %s in test %s.", run, testStruct.Name)
+				}
+
+				j.lock.RLock()
+				assert.Equal(t, i+1, int(j.Metadata.SuccessCount), fmt.Sprintf("Test of %s index %d", testStruct.Name, i))
+				assert.True(t, run.Add(time.Millisecond).Equal(j.Metadata.LastSuccess), fmt.Sprintf("Test of %s index %d", testStruct.Name, i))
+				j.lock.RUnlock()
+			}
+		}()
+	}
+}
+
+// Brings a job back after downtime and checks it makes up for each missed run in turn.
+func TestRecurMisfireBackfill(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:20")
+	clk := clock.NewMockClock(now)
+	cache := NewMockCache()
+	cache.Clock.SetClock(clk)
+
+	j := GetMockJob()
+	j.Schedule = "R/2020-01-13T00:00:00Z/PT1H"
+	j.MisfirePolicy = MisfireRunAll
+	j.MaxBackfill = 3
+	j.Metadata.LastAttemptedRun = parseTime(t, "2020-Jan-13 05:00")
+	j.succeedInstantly = true
+	j.ranChan = make(chan struct{})
+	j.clk.SetClock(clk)
+	assert.NoError(t, j.InitDelayDuration(false))
+	assert.NoError(t, cache.Set(j))
+
+	j.StartWaiting(cache, false)
+
+	// The runs at 08:00, 09:00 and 10:00.
+	for i := 0; i < 3; i++ {
+		awaitJobRan(t, j, time.Second*5)
+	}
+
+	select {
+	case <-j.ranChan:
+		t.Fatal("Expected job to have caught up")
+	case <-time.After(time.Millisecond * 300):
+	}
+
+	j.lock.RLock()
+	assert.Equal(t, 3, int(j.Metadata.SuccessCount))
+	assert.Equal(t, parseTime(t, "2020-Jan-13 10:00"), j.Metadata.LastScheduledRun)
+	assert.Equal(t, parseTime(t, "2020-Jan-13 11:00"), j.NextRunAt)
+	j.lock.RUnlock()
+}
+
+func TestRecurBlackout(t *testing.T) {
+	blackoutTableTests := []struct {
+		Policy    blackoutPolicy
+		Status    JobStatus
+		NextRunAt string
+	}{
+		{Policy: "", Status: Status.Skipped, NextRunAt: "2020-Jan-13 13:00"},
+		{Policy: BlackoutDefer, Status: Status.Deferred, NextRunAt: "2020-Jan-13 12:30"},
+	}
+
+	for _, testStruct := range blackoutTableTests {
+		now := parseTime(t, "2020-Jan-13 10:20")
+		clk := clock.NewMockClock(now)
+		cache := NewMockCache()
+		cache.Clock.SetClock(clk)
+
+		assert.NoError(t, cache.SaveCalendar(&Calendar{
+			Name: "freeze",
+			Ranges: []BlackoutRange{{
+				Start: parseTime(t, "2020-Jan-13 10:30"),
+				End:   parseTime(t, "2020-Jan-13 12:30"),
+			}},
+		}))
+
+		j := GetMockJob()
+		j.Schedule = "R/2020-01-13T10:00:00Z/PT1H"
+		j.Calendars = []string{"freeze"}
+		j.BlackoutPolicy = testStruct.Policy
+		j.Metadata.LastAttemptedRun = parseTime(t, "2020-Jan-13 10:00")
+		j.succeedInstantly = true
+		j.ranChan = make(chan struct{})
+		j.clk.SetClock(clk)
+		assert.NoError(t, j.InitDelayDuration(false))
+		assert.NoError(t, cache.Set(j))
+
+		j.StartWaiting(cache, false)
+		clk.SetTime(parseTime(t, "2020-Jan-13 11:00"))
+		awaitJobRan(t, j, time.Second*5)
+
+		j.lock.RLock()
+		assert.Equal(t, 0, int(j.Metadata.SuccessCount), string(testStruct.Status))
+		assert.Equal(t, parseTime(t, testStruct.NextRunAt), j.NextRunAt, string(testStruct.Status))
+		j.lock.RUnlock()
+
+		stats, err := cache.GetAllRuns(j.Id)
+		assert.NoError(t, err)
+		if assert.Len(t, stats, 1, string(testStruct.Status)) {
+			assert.Equal(t, testStruct.Status, stats[0].Status)
+		}
+
+		// The next run goes ahead.
+		clk.SetTime(parseTime(t, testStruct.NextRunAt))
+		awaitJobRan(t, j, time.Second*5)
+
+		j.lock.RLock()
+		assert.Equal(t, 1, int(j.Metadata.SuccessCount), string(testStruct.Status))
+		j.lock.RUnlock()
+	}
+}
@@ -4,6 +4,7 @@ package job
 import (
 	"time"
 
+	"github.com/mixer/clock"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 
@@ -80,3 +81,490 @@ func TestGetWatiDuration(t *testing.T) {
 		assert.InDelta(t, float64(testStruct.ExpectedDuration), float64(actualDuration), float64(time.Millisecond*50), "Test of "+testStruct.Name)
 	}
 }
+
+func TestGetWaitDurationCron(t *testing.T) {
+	// Thursday
+	now := parseTime(t, "2020-Jan-16 10:00")
+	clk := clock.NewMockClock(now)
+
+	j := &Job{CronSchedule: "30 9 * * MON-FRI"}
+	j.clk.SetClock(clk)
+	assert.NoError(t, j.InitDelayDuration(true))
+	assert.Equal(t, 23*time.Hour+30*time.Minute, j.GetWaitDuration())
+
+	// Friday's run is followed by Monday's.
+	clk.SetTime(parseTime(t, "2020-Jan-17 09:30"))
+	j.Metadata.LastAttemptedRun = clk.Now()
+	assert.Equal(t, 72*time.Hour, j.GetWaitDuration())
+
+	// A run missed while the job was inoperative runs immediately...
+	clk.SetTime(parseTime(t, "2020-Jan-21 10:00"))
+	assert.Equal(t, time.Duration(0), j.GetWaitDuration())
+
+	// ...unless it should wait for the next scheduled time.
+	j.ResumeAtNextScheduledTime = true
+	assert.Equal(t, 23*time.Hour+30*time.Minute, j.GetWaitDuration())
+}
+
+func TestCronScheduleErrors(t *testing.T) {
+	j := &Job{CronSchedule: "61 * * * *"}
+	assert.Error(t, j.InitDelayDuration(false))
+
+	j = &Job{CronSchedule: "0 0 30 2 *"}
+	assert.Error(t, j.InitDelayDuration(false))
+
+	j = &Job{CronSchedule: "@daily", Schedule: "R/2015-10-17T11:44:54.389361-07:00/PT10S"}
+	assert.Equal(t, ErrScheduleConflict, j.InitDelayDuration(false))
+}
+
+func TestInvalidTimezone(t *testing.T) {
+	j := &Job{Schedule: "R/2015-10-17T11:44:54/PT10S", Timezone: "Mars/Olympus_Mons"}
+	assert.Error(t, j.InitDelayDuration(false))
+
+	j = &Job{CronSchedule: "@daily", Timezone: "Mars/Olympus_Mons"}
+	assert.Error(t, j.InitDelayDuration(false))
+}
+
+func TestTimezoneStartTime(t *testing.T) {
+	j := &Job{Schedule: "R/2020-01-13T14:09:00/P1D", Timezone: "Asia/Kolkata"}
+	assert.NoError(t, j.InitDelayDuration(false))
+	assert.Equal(t, "2020-01-13T14:09:00+05:30", j.scheduleTime.Format(time.RFC3339))
+
+	// Without a zone, times without an offset are UTC.
+	j = &Job{Schedule: "R/2020-01-13T14:09:00/P1D"}
+	assert.NoError(t, j.InitDelayDuration(false))
+	assert.Equal(t, "2020-01-13T14:09:00Z", j.scheduleTime.Format(time.RFC3339))
+}
+
+func TestGetWaitDurationMisfire(t *testing.T) {
+	// Hourly runs from midnight; the job last ran at 05:00 and it is now 10:20,
+	// so the runs at 06:00 through 10:00 were missed.
+	now := parseTime(t, "2020-Jan-13 10:20")
+	lastRun := parseTime(t, "2020-Jan-13 05:00")
+
+	misfireTableTests := []struct {
+		Name             string
+		Job              *Job
+		NeverRan         bool
+		ExpectedDuration time.Duration
+	}{
+		{
+			Name:             "Run once",
+			Job:              &Job{MisfirePolicy: MisfireRunOnce},
+			ExpectedDuration: -4*time.Hour - 20*time.Minute,
+		},
+		{
+			Name:             "Skip",
+			Job:              &Job{MisfirePolicy: MisfireSkip},
+			ExpectedDuration: 40 * time.Minute,
+		},
+		{
+			Name:             "Skip beyond threshold",
+			Job:              &Job{MisfirePolicy: MisfireSkip, MisfireThreshold: "PT30M"},
+			ExpectedDuration: 40 * time.Minute,
+		},
+		{
+			Name: "Skip within threshold",
+			Job: &Job{MisfirePolicy: MisfireSkip, MisfireThreshold: "PT30M", Metadata: Metadata{
+				LastAttemptedRun: parseTime(t, "2020-Jan-13 09:00"),
+			}},
+			ExpectedDuration: -20 * time.Minute,
+		},
+		{
+			Name:             "Run all",
+			Job:              &Job{MisfirePolicy: MisfireRunAll},
+			ExpectedDuration: -4*time.Hour - 20*time.Minute,
+		},
+		{
+			Name:             "Run all with bounded backfill",
+			Job:              &Job{MisfirePolicy: MisfireRunAll, MaxBackfill: 2},
+			ExpectedDuration: -time.Hour - 20*time.Minute,
+		},
+		{
+			Name: "Run all carries on from the last missed run made up for",
+			Job: &Job{MisfirePolicy: MisfireRunAll, Metadata: Metadata{
+				LastAttemptedRun: now,
+				LastScheduledRun: parseTime(t, "2020-Jan-13 06:00"),
+			}},
+			ExpectedDuration: -3*time.Hour - 20*time.Minute,
+		},
+		{
+			Name: "Run once carries on from the last run",
+			Job: &Job{MisfirePolicy: MisfireRunOnce, Metadata: Metadata{
+				LastAttemptedRun: now,
+				LastScheduledRun: parseTime(t, "2020-Jan-13 06:00"),
+			}},
+			ExpectedDuration: time.Hour,
+		},
+		{
+			Name: "Run once with a jitter carries on from the last scheduled run",
+			Job: &Job{MisfirePolicy: MisfireRunOnce, Jitter: "PT10M", Metadata: Metadata{
+				LastAttemptedRun: parseTime(t, "2020-Jan-13 10:07"),
+				LastScheduledRun: parseTime(t, "2020-Jan-13 10:00"),
+			}},
+			ExpectedDuration: 40 * time.Minute,
+		},
+		{
+			Name: "Skip with a jitter carries on from the last scheduled run",
+			Job: &Job{MisfirePolicy: MisfireSkip, Jitter: "PT10M", Metadata: Metadata{
+				LastAttemptedRun: parseTime(t, "2020-Jan-13 09:07"),
+				LastScheduledRun: parseTime(t, "2020-Jan-13 09:00"),
+			}},
+			ExpectedDuration: 40 * time.Minute,
+		},
+		{
+			Name:             "Never ran",
+			Job:              &Job{MisfirePolicy: MisfireRunAll, MaxBackfill: 3},
+			NeverRan:         true,
+			ExpectedDuration: -2*time.Hour - 20*time.Minute,
+		},
+		{
+			Name:             "Cron skip",
+			Job:              &Job{MisfirePolicy: MisfireSkip, CronSchedule: "0 * * * *"},
+			ExpectedDuration: 40 * time.Minute,
+		},
+		{
+			Name:             "Cron run all with bounded backfill",
+			Job:              &Job{MisfirePolicy: MisfireRunAll, CronSchedule: "0 * * * *", MaxBackfill: 2},
+			ExpectedDuration: -time.Hour - 20*time.Minute,
+		},
+	}
+
+	for _, testStruct := range misfireTableTests {
+		j := testStruct.Job
+		if j.CronSchedule == "" {
+			j.Schedule = "R/2020-01-13T00:00:00Z/PT1H"
+		}
+		if j.Metadata == (Metadata{}) && !testStruct.NeverRan {
+			j.Metadata.LastAttemptedRun = lastRun
+		}
+		j.clk.SetClock(clock.NewMockClock(now))
+		assert.NoError(t, j.InitDelayDuration(false), testStruct.Name)
+		assert.Equal(t, testStruct.ExpectedDuration, j.GetWaitDuration(), "Test of "+testStruct.Name)
+	}
+}
+
+func TestMisfireErrors(t *testing.T) {
+	j := GetMockJob()
+	j.MisfirePolicy = "sometimes"
+	assert.Equal(t, ErrInvalidMisfirePolicy, j.validation())
+
+	j = &Job{Schedule: "R/2015-10-17T11:44:54/PT10S", MisfireThreshold: "5 minutes"}
+	assert.Error(t, j.InitDelayDuration(false))
+}
+
+func TestNextRuns(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:20")
+
+	nextRunsTableTests := []struct {
+		Name     string
+		Job      *Job
+		Count    int
+		Expected []string
+	}{
+		{
+			Name:  "Monthly with Normalization",
+			Job:   &Job{Schedule: "R/2020-01-31T12:00:00Z/P1M"},
+			Count: 4,
+			Expected: []string{
+				"2020-01-31T12:00:00Z",
+				"2020-03-02T12:00:00Z",
+				"2020-04-02T12:00:00Z",
+				"2020-05-02T12:00:00Z",
+			},
+		},
+		{
+			Name:  "Fewer runs left",
+			Job:   &Job{Schedule: "R2/2020-01-14T00:00:00Z/PT12H"},
+			Count: 5,
+			Expected: []string{
+				"2020-01-14T00:00:00Z",
+				"2020-01-14T12:00:00Z",
+				"2020-01-15T00:00:00Z",
+			},
+		},
+		{
+			Name:  "Cron",
+			Job:   &Job{CronSchedule: "30 9 * * MON-FRI"},
+			Count: 3,
+			Expected: []string{
+				"2020-01-14T09:30:00Z",
+				"2020-01-15T09:30:00Z",
+				"2020-01-16T09:30:00Z",
+			},
+		},
+		{
+			Name:  "Time zone",
+			Job:   &Job{Schedule: "R/2020-03-07T02:30:00/P1D", Timezone: "America/Los_Angeles"},
+			Count: 3,
+			Expected: []string{
+				"2020-03-07T02:30:00-08:00",
+				"2020-03-08T03:30:00-07:00",
+				"2020-03-09T02:30:00-07:00",
+			},
+		},
+		{
+			Name: "Missed runs",
+			Job: &Job{Schedule: "R/2020-01-13T00:00:00Z/PT1H", MisfirePolicy: MisfireRunAll, MaxBackfill: 2,
+				Metadata: Metadata{LastAttemptedRun: parseTime(t, "2020-Jan-13 05:00")}},
+			Count: 3,
+			Expected: []string{
+				"2020-01-13T10:20:00Z",
+				"2020-01-13T10:20:00Z",
+				"2020-01-13T11:00:00Z",
+			},
+		},
+		{
+			Name:  "Jitter spread",
+			Job:   &Job{Id: "jittery", Schedule: "R/2020-01-14T00:00:00Z/PT12H", Jitter: "PT1H", JitterMode: JitterSpread},
+			Count: 2,
+			Expected: []string{
+				"2020-01-14T00:05:47Z",
+				"2020-01-14T12:05:47Z",
+			},
+		},
+		{
+			Name:     "Disabled",
+			Job:      &Job{Schedule: "R/2020-01-14T00:00:00Z/PT12H", Disabled: true},
+			Count:    3,
+			Expected: []string{},
+		},
+		{
+			Name:     "No schedule",
+			Job:      &Job{},
+			Count:    3,
+			Expected: []string{},
+		},
+	}
+
+	for _, testStruct := range nextRunsTableTests {
+		j := testStruct.Job
+		j.clk.SetClock(clock.NewMockClock(now))
+		assert.NoError(t, j.InitDelayDuration(false), testStruct.Name)
+
+		runs, err := j.NextRuns(testStruct.Count)
+		assert.NoError(t, err, testStruct.Name)
+
+		actual := make([]string, 0, len(runs))
+		for _, run := range runs {
+			actual = append(actual, run.Format(time.RFC3339))
+		}
+		assert.Equal(t, testStruct.Expected, actual, "Test of "+testStruct.Name)
+	}
+}
+
+func TestScheduleEnd(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:20")
+
+	scheduleEndTableTests := []struct {
+		Name     string
+		Job      *Job
+		EndsAt   string
+		Expected []string
+	}{
+		{
+			Name: "Start and end, repeating every time between them",
+			Job:  &Job{Schedule: "R/2020-01-13T11:00:00Z/2020-01-13T13:30:00Z"},
+			Expected: []string{
+				"2020-01-13T11:00:00Z",
+				"2020-01-13T13:30:00Z",
+				"2020-01-13T16:00:00Z",
+				"2020-01-13T18:30:00Z",
+				"2020-01-13T21:00:00Z",
+			},
+		},
+		{
+			Name:   "Interval and end",
+			Job:    &Job{Schedule: "R/PT1H/2020-01-13T14:00:00Z"},
+			EndsAt: "2020-01-13T13:00:00Z",
+			Expected: []string{
+				"2020-01-13T11:00:00Z",
+				"2020-01-13T12:00:00Z",
+				"2020-01-13T13:00:00Z",
+			},
+		},
+		{
+			Name:   "Repetitions, interval and end",
+			Job:    &Job{Schedule: "R1/P1D/2020-01-16T09:00:00"},
+			EndsAt: "2020-01-15T09:00:00Z",
+			Expected: []string{
+				"2020-01-14T09:00:00Z",
+				"2020-01-15T09:00:00Z",
+			},
+		},
+		{
+			Name:   "Interval and end after the last run",
+			Job:    &Job{Schedule: "R/PT1H/2020-01-13T14:00:00Z", Metadata: Metadata{LastAttemptedRun: parseTime(t, "2020-Jan-13 10:00")}},
+			EndsAt: "2020-01-13T13:00:00Z",
+			Expected: []string{
+				"2020-01-13T11:00:00Z",
+				"2020-01-13T12:00:00Z",
+				"2020-01-13T13:00:00Z",
+			},
+		},
+		{
+			Name:   "Not after",
+			Job:    &Job{Schedule: "R/2020-01-13T11:00:00Z/PT1H", NotAfter: parseTime(t, "2020-Jan-13 12:30")},
+			EndsAt: "2020-01-13T12:30:00Z",
+			Expected: []string{
+				"2020-01-13T11:00:00Z",
+				"2020-01-13T12:00:00Z",
+			},
+		},
+		{
+			Name:   "Not after before the end of the schedule",
+			Job:    &Job{Schedule: "R/PT1H/2020-01-14T00:00:00Z", NotAfter: parseTime(t, "2020-Jan-13 11:00")},
+			EndsAt: "2020-01-13T11:00:00Z",
+			Expected: []string{
+				"2020-01-13T11:00:00Z",
+			},
+		},
+		{
+			Name:   "Cron not after",
+			Job:    &Job{CronSchedule: "30 9 * * MON-FRI", NotAfter: parseTime(t, "2020-Jan-15 10:00")},
+			EndsAt: "2020-01-15T10:00:00Z",
+			Expected: []string{
+				"2020-01-14T09:30:00Z",
+				"2020-01-15T09:30:00Z",
+			},
+		},
+	}
+
+	for _, testStruct := range scheduleEndTableTests {
+		j := testStruct.Job
+		j.clk.SetClock(clock.NewMockClock(now))
+		assert.NoError(t, j.InitDelayDuration(false), testStruct.Name)
+
+		endsAt := ""
+		if !j.EndsAt.IsZero() {
+			endsAt = j.EndsAt.UTC().Format(time.RFC3339)
+		}
+		assert.Equal(t, testStruct.EndsAt, endsAt, "Test of "+testStruct.Name)
+
+		runs, err := j.NextRuns(5)
+		assert.NoError(t, err, testStruct.Name)
+
+		actual := make([]string, 0, len(runs))
+		for _, run := range runs {
+			actual = append(actual, run.Format(time.RFC3339))
+		}
+		assert.Equal(t, testStruct.Expected, actual, "Test of "+testStruct.Name)
+	}
+}
+
+func TestScheduleEndErrors(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:20")
+
+	schedules := []string{
+		"R/2020-01-13T11:00:00Z/2020-01-13T11:00:00Z",
+		"R/PT0S/2020-01-14T00:00:00Z",
+		"R/PT1H/not a time",
+		"R/PT1H/2020-01-13T10:00:00Z",
+	}
+	for _, schedule := range schedules {
+		j := &Job{Schedule: schedule}
+		j.clk.SetClock(clock.NewMockClock(now))
+		assert.Error(t, j.InitDelayDuration(true), schedule)
+	}
+
+	j := &Job{Schedule: "R/2020-01-13T11:00:00Z/PT1H", NotAfter: parseTime(t, "2020-Jan-13 10:00")}
+	j.clk.SetClock(clock.NewMockClock(now))
+	assert.Error(t, j.InitDelayDuration(true))
+}
+
+func TestJobIsDoneAfterEnd(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:20")
+	clk := clock.NewMockClock(now)
+	cache := NewMockCache()
+	cache.Clock.SetClock(clk)
+
+	j := GetMockJob()
+	j.Schedule = "R/PT1H/2020-01-13T13:00:00Z"
+	j.succeedInstantly = true
+	j.ranChan = make(chan struct{})
+	j.clk.SetClock(clk)
+	assert.NoError(t, j.InitDelayDuration(true))
+	assert.NoError(t, cache.Set(j))
+
+	j.StartWaiting(cache, false)
+	for _, run := range []string{"2020-Jan-13 11:00", "2020-Jan-13 12:00"} {
+		// A run takes some time; without any, a run at the start time would be due again.
+		clk.SetTime(parseTime(t, run).Add(time.Millisecond))
+		awaitJobRan(t, j, time.Second*5)
+	}
+
+	j.lock.RLock()
+	assert.Equal(t, 2, int(j.Metadata.SuccessCount))
+	assert.True(t, j.IsDone)
+	j.lock.RUnlock()
+}
+
+func TestJitterDelay(t *testing.T) {
+	due := parseTime(t, "2020-Jan-13 00:00")
+
+	j := GetMockRecurringJobWithSchedule(due, "P1D")
+	j.Id = "job-a"
+	assert.Equal(t, time.Duration(0), j.jitterDelay(due))
+
+	j.Jitter = "PT10M"
+	assert.NoError(t, j.InitDelayDuration(false))
+	delay := j.jitterDelay(due)
+	assert.True(t, delay >= 0 && delay < 10*time.Minute, delay)
+	assert.Equal(t, delay, j.jitterDelay(due), "The delay depends only on the job and the run")
+
+	other := GetMockRecurringJobWithSchedule(due, "P1D")
+	other.Id = "job-b"
+	other.Jitter = "PT10M"
+	assert.NoError(t, other.InitDelayDuration(false))
+	assert.NotEqual(t, delay, other.jitterDelay(due))
+
+	// Spread across the window, the delay doesn't change from one run to the next.
+	j.JitterMode = JitterSpread
+	next := due.Add(24 * time.Hour)
+	assert.Equal(t, j.jitterDelay(due), j.jitterDelay(next))
+	j.JitterMode = JitterRandom
+	assert.NotEqual(t, j.jitterDelay(due), j.jitterDelay(next))
+
+	j.JitterMode = "sometimes"
+	assert.Equal(t, ErrInvalidJitterMode, j.validation())
+}
+
+func TestJobJitter(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:20")
+	clk := clock.NewMockClock(now)
+	cache := NewMockCache()
+	cache.Clock.SetClock(clk)
+
+	start := parseTime(t, "2020-Jan-13 11:00")
+	j := GetMockRecurringJobWithSchedule(start, "PT1H")
+	j.Jitter = "PT10M"
+	j.succeedInstantly = true
+	j.ranChan = make(chan struct{})
+	j.clk.SetClock(clk)
+	assert.NoError(t, j.InitDelayDuration(true))
+	assert.NoError(t, cache.Set(j))
+
+	j.StartWaiting(cache, false)
+	for _, due := range []time.Time{start, start.Add(time.Hour)} {
+		j.lock.RLock()
+		assert.Equal(t, due, j.NextRunAt)
+		delay := j.jitterDelay(due)
+		j.lock.RUnlock()
+
+		clk.SetTime(due.Add(delay - time.Millisecond))
+		select {
+		case <-j.ranChan:
+			t.Fatal("Job ran before its jitter was up")
+		case <-time.After(time.Millisecond * 100):
+		}
+
+		clk.SetTime(due.Add(delay + time.Millisecond))
+		awaitJobRan(t, j, time.Second*5)
+	}
+
+	// Runs stay on the schedule rather than following the jittered start times.
+	j.lock.RLock()
+	assert.Equal(t, 2, int(j.Metadata.SuccessCount))
+	assert.Equal(t, start.Add(2*time.Hour), j.NextRunAt)
+	j.lock.RUnlock()
+}
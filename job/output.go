@@ -0,0 +1,75 @@
+package job
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultMaxOutputSize is the most bytes of each of a run's outputs kept, unless MaxOutputSize says otherwise.
+const DefaultMaxOutputSize = 64 * 1024
+
+// MaxOutputSize is the most bytes of each of a run's outputs (a local job's stdout and stderr, or a
+// remote job's response body) that are kept in its JobStat. Of longer outputs the start and the end
+// are kept, and the middle dropped. Zero or less means there's no limit.
+var MaxOutputSize = DefaultMaxOutputSize
+
+// outputBuffer is a writer that keeps the first and last MaxOutputSize/2 bytes written to it.
+// It's safe for concurrent use.
+type outputBuffer struct {
+	max   int
+	head  []byte
+	tail  []byte
+	total int
+	lock  sync.Mutex
+}
+
+func newOutputBuffer() *outputBuffer {
+	return &outputBuffer{max: MaxOutputSize}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.total += len(p)
+	if b.max <= 0 {
+		b.head = append(b.head, p...)
+		return len(p), nil
+	}
+
+	written := len(p)
+	tailMax := b.max / 2 //nolint:gomnd
+	headMax := b.max - tailMax
+	if len(b.head) < headMax {
+		n := headMax - len(b.head)
+		if n > len(p) {
+			n = len(p)
+		}
+		b.head = append(b.head, p[:n]...)
+		p = p[n:]
+	}
+
+	b.tail = append(b.tail, p...)
+	if len(b.tail) > tailMax {
+		b.tail = b.tail[len(b.tail)-tailMax:]
+	}
+	return written, nil
+}
+
+// Truncated says whether any of the output has been dropped.
+func (b *outputBuffer) Truncated() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.total > len(b.head)+len(b.tail)
+}
+
+func (b *outputBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	dropped := b.total - len(b.head) - len(b.tail)
+	if dropped == 0 {
+		return string(b.head) + string(b.tail)
+	}
+	return fmt.Sprintf("%s\n... %d bytes truncated ...\n%s", b.head, dropped, b.tail)
+}
@@ -0,0 +1,26 @@
+package job
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	b := &outputBuffer{max: 10}
+	b.Write([]byte("hello"))
+	b.Write([]byte("world"))
+	assert.False(t, b.Truncated())
+	assert.Equal(t, "helloworld", b.String())
+
+	b.Write([]byte("!"))
+	b.Write([]byte("goodbye"))
+	assert.True(t, b.Truncated())
+	assert.Equal(t, "hello\n... 8 bytes truncated ...\nodbye", b.String())
+
+	b = &outputBuffer{max: 0}
+	b.Write([]byte(strings.Repeat("a", 1000)))
+	assert.False(t, b.Truncated())
+	assert.Len(t, b.String(), 1000)
+}
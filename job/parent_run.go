@@ -0,0 +1,82 @@
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// ParentRun is what a dependent job's run is told of the run of its parent job that started it.
+// Its templates have it as .Parent, e.g. {{.Parent.JSON.id}}, its command as NEXTKALA_PARENT_*
+// environment variables and its request as NextKala-Parent-* headers.
+type ParentRun struct {
+	JobId  string
+	RunId  string
+	Status JobStatus
+	// The parent run's output: its command's output, or its response's body.
+	Output string
+	// The Output parsed as JSON, or else the result an async run was called back with.
+	// Nil if neither is JSON. Numbers are json.Numbers, so that long IDs are templated as they were.
+	JSON interface{}
+}
+
+// noParentRun is the .Parent of a run that wasn't started by a parent job's run,
+// so that templates using it still execute, with empty values.
+func noParentRun() *ParentRun {
+	return &ParentRun{JSON: map[string]interface{}{}}
+}
+
+// newParentRun returns what the dependent jobs of the run with the stat, done with the status, are told of it.
+func newParentRun(stat *JobStat, status JobStatus) *ParentRun {
+	p := &ParentRun{
+		JobId:  stat.JobId,
+		RunId:  stat.Id,
+		Status: status,
+		Output: stat.Output,
+	}
+	p.JSON = parseJSON([]byte(stat.Output))
+	if p.JSON == nil && len(stat.Result) != 0 {
+		p.JSON = parseJSON(stat.Result)
+	}
+	return p
+}
+
+func parseJSON(b []byte) interface{} {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil || d.More() {
+		return nil
+	}
+	return v
+}
+
+// withoutOutput returns what a local job's command, script and env are templated with. The parent run's output
+// could be anything, e.g. a remote response's body, so it's only passed to commands in NEXTKALA_PARENT_OUTPUT,
+// which isn't substituted into them.
+func (p *ParentRun) withoutOutput() *ParentRun {
+	if p == nil {
+		return nil
+	}
+	return &ParentRun{JobId: p.JobId, RunId: p.RunId, Status: p.Status, JSON: map[string]interface{}{}}
+}
+
+// maxParentOutputEnv is the most of a parent run's output NEXTKALA_PARENT_OUTPUT has, whatever MaxOutputSize is,
+// leaving room for its name and the truncation note under Linux's limit on each environment variable's length.
+const maxParentOutputEnv = 128*1024 - 1024
+
+// setEnv adds the parent run's environment variables to env.
+func (p *ParentRun) setEnv(env map[string]string) {
+	env["NEXTKALA_PARENT_JOB_ID"] = p.JobId
+	env["NEXTKALA_PARENT_RUN_ID"] = p.RunId
+	env["NEXTKALA_PARENT_STATUS"] = string(p.Status)
+	output := &outputBuffer{max: maxParentOutputEnv}
+	output.Write([]byte(p.Output)) //nolint:errcheck // Never fails
+	env["NEXTKALA_PARENT_OUTPUT"] = output.String()
+}
+
+// setHeaders adds the parent run's headers to h.
+func (p *ParentRun) setHeaders(h http.Header) {
+	h.Set("NextKala-Parent-JobId", p.JobId)
+	h.Set("NextKala-Parent-RunId", p.RunId)
+}
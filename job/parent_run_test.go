@@ -0,0 +1,148 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewParentRun(t *testing.T) {
+	stat := &JobStat{Id: "run", JobId: "job", Status: Status.Started, Output: `{"id": 12345678901234567890}`}
+	p := newParentRun(stat, Status.Success)
+	assert.Equal(t, "job", p.JobId)
+	assert.Equal(t, "run", p.RunId)
+	assert.Equal(t, Status.Success, p.Status)
+	assert.Equal(t, map[string]interface{}{"id": json.Number("12345678901234567890")}, p.JSON)
+
+	// An async run's result, when its output isn't JSON.
+	stat = &JobStat{Output: "3 rows exported", Result: json.RawMessage(`{"rows": 3}`)}
+	assert.Equal(t, map[string]interface{}{"rows": json.Number("3")}, newParentRun(stat, Status.Success).JSON)
+
+	for _, output := range []string{"", "done", `{"id": 1} and more`} {
+		assert.Nil(t, newParentRun(&JobStat{Output: output}, Status.Success).JSON, output)
+	}
+}
+
+func TestTemplatizeWithoutParent(t *testing.T) {
+	j := GetMockJob()
+	j.TemplateDelimiters = "{{ }}"
+	out, err := j.TryTemplatize("{{.Name}} after [{{.Parent.RunId}}]")
+	assert.NoError(t, err)
+	assert.Equal(t, "mock_job after []", out)
+
+	// Executes, so that jobs using it can be validated.
+	_, err = j.TryTemplatize("{{.Parent.JSON.id}}")
+	assert.NoError(t, err)
+}
+
+func TestDependentJobsGetParentRun(t *testing.T) {
+	parentServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 12345678901234567890}`)) //nolint:errcheck // Only a test server
+	}))
+	defer parentServer.Close()
+
+	type request struct {
+		body    []byte
+		headers http.Header
+	}
+	requests := make(chan request, 2)
+	childServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{body: body, headers: r.Header}
+	}))
+	defer childServer.Close()
+
+	cache := NewMockCache()
+	parent := GetMockRemoteJob(RemoteProperties{Url: parentServer.URL})
+	parent.Schedule = fmt.Sprintf("R/%s/PT1H", time.Now().Add(time.Hour).Format(time.RFC3339))
+	assert.NoError(t, parent.Init(cache))
+
+	remoteChild := GetMockRemoteJob(RemoteProperties{Url: childServer.URL, Method: http.MethodPost,
+		Body: `{"order": {{.Parent.JSON.id}}}`})
+	remoteChild.TemplateDelimiters = "{{ }}"
+	remoteChild.ParentJobs = []string{parent.Id}
+	assert.NoError(t, remoteChild.Init(cache))
+
+	localChild := GetMockJob()
+	localChild.Command = "bash -c 'echo $NEXTKALA_PARENT_JOB_ID $NEXTKALA_PARENT_OUTPUT'"
+	localChild.TemplateDelimiters = "{{ }}"
+	localChild.ParentJobs = []string{parent.Id}
+	assert.NoError(t, localChild.Init(cache))
+
+	parent.Run(cache)
+
+	parentRuns, err := cache.GetAllRuns(parent.Id)
+	assert.NoError(t, err)
+	if !assert.Len(t, parentRuns, 1) {
+		return
+	}
+	req := <-requests
+	assert.Equal(t, `{"order": 12345678901234567890}`, string(req.body))
+	assert.Equal(t, parent.Id, req.headers.Get("NextKala-Parent-JobId"))
+	assert.Equal(t, parentRuns[0].Id, req.headers.Get("NextKala-Parent-RunId"))
+
+	localRuns, err := cache.GetAllRuns(localChild.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, localRuns, 1) {
+		assert.Equal(t, Status.Success, localRuns[0].Status)
+		assert.Equal(t, parent.Id+` {"id": 12345678901234567890}`, localRuns[0].Output)
+	}
+
+	// The parent's headers aren't left among the child's own.
+	remoteChild.Run(cache)
+	req = <-requests
+	assert.Empty(t, req.headers.Get("NextKala-Parent-RunId"))
+}
+
+func TestLocalJobsAreNotTemplatedWithParentOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "marker")
+
+	j := GetMockJob()
+	j.TemplateDelimiters = "{{ }}"
+	j.Command = "echo [{{.Parent.Status}}] [{{.Parent.Output}}] [{{.Parent.JSON}}] [$ORDER]"
+	j.Env = map[string]string{"ORDER": "{{.Parent.Output}}"}
+	parent := &ParentRun{Status: Status.Success, Output: "`touch " + marker + "`", JSON: map[string]interface{}{"id": 1}}
+	r := &JobRunner{job: j, parent: parent}
+	out, err := r.LocalRun()
+	assert.NoError(t, err)
+	assert.Equal(t, "[Success] [] [map[]] []", out)
+
+	j.JobType = ScriptJob
+	j.Script = "echo {{.Parent.Output}}"
+	_, err = r.ScriptRun()
+	assert.NoError(t, err)
+	// It's passed in the environment instead, for the command's shell to expand.
+	j.JobType = LocalJob
+	j.Command = "bash -c 'echo \"$NEXTKALA_PARENT_OUTPUT\"'"
+	out, err = r.LocalRun()
+	assert.NoError(t, err)
+	assert.Equal(t, parent.Output, out)
+
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "The parent's output shouldn't have been run")
+}
+
+func TestParentOutputEnvIsCapped(t *testing.T) {
+	defer func(size int) { MaxOutputSize = size }(MaxOutputSize)
+	MaxOutputSize = 0
+
+	j := GetMockJob()
+	j.Command = "bash -c 'echo ${#NEXTKALA_PARENT_OUTPUT}'"
+	r := &JobRunner{job: j, parent: &ParentRun{Output: strings.Repeat("x", 1024*1024)}}
+	out, err := r.LocalRun()
+	if assert.NoError(t, err, "The command should start, however long the parent's output") {
+		assert.True(t, len(out) > 0 && len(out) <= len("131072"), out)
+	}
+}
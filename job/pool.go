@@ -0,0 +1,140 @@
+package job
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// MaxConcurrentRuns is the most runs, of all jobs together, in progress at once. Runs past that
+// wait for one of them to finish, those of jobs with the highest Priority first, and then those
+// that have waited longest. Zero or less means there's no limit.
+var MaxConcurrentRuns = 0
+
+// PoolStats says how runs are getting on for slots under MaxConcurrentRuns.
+type PoolStats struct {
+	// Runs in progress.
+	Running int `json:"running_runs"`
+	// Runs waiting for a slot.
+	Queued int `json:"queued_runs"`
+}
+
+var pool = &runPool{}
+
+// runPool hands out slots to runs, up to MaxConcurrentRuns of them.
+type runPool struct {
+	lock    sync.Mutex
+	running int
+	queue   poolQueue
+	// Counts the runs queued, to keep runs of the same priority in order.
+	queued uint64
+}
+
+// acquire waits for a slot for a run of a job with the given priority, calling queued if it has to.
+// It returns ctx's error, without a slot, if ctx is done before there's one.
+func (p *runPool) acquire(ctx context.Context, priority int, queued func()) error {
+	p.lock.Lock()
+	if p.queue.Len() == 0 && p.hasRoom() {
+		p.running++
+		p.lock.Unlock()
+		return nil
+	}
+	w := &poolWaiter{priority: priority, order: p.queued, ready: make(chan struct{})}
+	p.queued++
+	heap.Push(&p.queue, w)
+	p.lock.Unlock()
+
+	queued()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if w.index < 0 {
+		// Given a slot in the meantime; pass it on.
+		p.running--
+		p.grant()
+	} else {
+		heap.Remove(&p.queue, w.index)
+	}
+	return ctx.Err()
+}
+
+// release gives back a slot acquired, for the next run waiting.
+func (p *runPool) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.running--
+	p.grant()
+}
+
+func (p *runPool) stats() PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return PoolStats{Running: p.running, Queued: p.queue.Len()}
+}
+
+func (p *runPool) hasRoom() bool {
+	return MaxConcurrentRuns <= 0 || p.running < MaxConcurrentRuns
+}
+
+// grant hands out the slots there's room for to the runs waiting. The pool must be locked.
+func (p *runPool) grant() {
+	for p.queue.Len() > 0 && p.hasRoom() {
+		w := heap.Pop(&p.queue).(*poolWaiter)
+		p.running++
+		close(w.ready)
+	}
+}
+
+// GetPoolStats returns how many runs are in progress, and how many are waiting for a slot.
+func GetPoolStats() PoolStats {
+	return pool.stats()
+}
+
+type poolWaiter struct {
+	priority int
+	order    uint64
+	ready    chan struct{}
+	// In the queue, or -1 once out of it.
+	index int
+}
+
+// poolQueue is a heap of the runs waiting for a slot, the next to get one first.
+type poolQueue []*poolWaiter
+
+func (q poolQueue) Len() int {
+	return len(q)
+}
+
+func (q poolQueue) Less(i, k int) bool {
+	if q[i].priority != q[k].priority {
+		return q[i].priority > q[k].priority
+	}
+	return q[i].order < q[k].order
+}
+
+func (q poolQueue) Swap(i, k int) {
+	q[i], q[k] = q[k], q[i]
+	q[i].index = i
+	q[k].index = k
+}
+
+func (q *poolQueue) Push(x interface{}) {
+	w := x.(*poolWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *poolQueue) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
@@ -0,0 +1,108 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPool(t *testing.T) {
+	defer func(max int) { MaxConcurrentRuns = max }(MaxConcurrentRuns)
+	MaxConcurrentRuns = 1
+	p := &runPool{}
+
+	assert.NoError(t, p.acquire(context.Background(), 0, func() { t.Error("The first run shouldn't queue") }))
+
+	// Queued one at a time, so that they're queued in order.
+	granted := make(chan int, 3)
+	for i, priority := range []int{0, 5, 0} {
+		i, priority := i, priority
+		go func() {
+			assert.NoError(t, p.acquire(context.Background(), priority, func() {}))
+			granted <- i
+		}()
+		for p.stats().Queued != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// One that gives up waiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, p.acquire(ctx, 10, func() {}))
+	assert.Equal(t, PoolStats{Running: 1, Queued: 3}, p.stats())
+
+	for _, want := range []int{1, 0, 2} {
+		p.release()
+		select {
+		case got := <-granted:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatal("A queued run should have been given the slot")
+		}
+	}
+	p.release()
+	assert.Equal(t, PoolStats{}, p.stats())
+}
+
+func TestRunQueued(t *testing.T) {
+	defer func(max int) { MaxConcurrentRuns = max }(MaxConcurrentRuns)
+	MaxConcurrentRuns = 1
+
+	// Take the only slot.
+	assert.NoError(t, pool.acquire(context.Background(), 0, func() {}))
+	released := false
+	defer func() {
+		if !released {
+			pool.release()
+		}
+	}()
+
+	cache := NewMockCache()
+	j := GetMockJob()
+	j.Id = "queued"
+	assert.NoError(t, cache.Set(j))
+	done := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(done)
+	}()
+
+	var running []*JobStat
+	for i := 0; i < 100 && (len(running) == 0 || running[0].Status != Status.Queued); i++ {
+		time.Sleep(10 * time.Millisecond)
+		running = RunningStats(j.Id)
+	}
+	if assert.Len(t, running, 1) {
+		assert.Equal(t, Status.Queued, running[0].Status)
+	}
+	assert.True(t, GetPoolStats().Queued > 0)
+
+	// The job isn't locked while the run waits.
+	locked := make(chan struct{})
+	go func() {
+		j.lock.Lock()
+		j.lock.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("The job was locked while its run was queued")
+	}
+
+	pool.release()
+	released = true
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The run should have gone ahead once there was a slot")
+	}
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Success, stats[0].Status)
+	}
+}
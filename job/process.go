@@ -0,0 +1,86 @@
+package job
+
+import (
+	"errors"
+	"os/user"
+)
+
+// AllowedRunAsUsers are the names of the users local jobs may run as; see Job.RunAs.
+// Empty means jobs run as NextKala's own user.
+var AllowedRunAsUsers []string
+
+var (
+	ErrRunAsNotAllowed     = errors.New("Invalid Job run_as. Jobs aren't allowed to run as that user")
+	ErrRunAsUnsupported    = errors.New("Running jobs as another user isn't supported on this platform")
+	ErrRlimitsUnsupported  = errors.New("Resource limits for jobs aren't supported on this platform")
+	ErrRlimitsHookMissing  = errors.New("Resource limits for jobs need main to call job.ExecWithRlimits first")
+	ErrRunAsGroupNotMember = errors.New("Invalid Job run_as. The user isn't a member of the group")
+)
+
+// RunAs is the Unix user, and group, to run a local job's command as.
+type RunAs struct {
+	// Name or id of the user.
+	User string `json:"user"`
+
+	// Name or id of one of the user's groups. Empty means the user's primary group.
+	Group string `json:"group"`
+}
+
+// allowed says whether the job may run as the user, according to AllowedRunAsUsers.
+func (r *RunAs) allowed() bool {
+	if r.User == "" {
+		return r.Group == ""
+	}
+	u, err := lookupUser(r.User)
+	if err != nil {
+		return false
+	}
+	for _, name := range AllowedRunAsUsers {
+		if name == u.Username {
+			return true
+		}
+	}
+	return false
+}
+
+// Rlimits are resource limits for a local job's command. Zero leaves a limit as it is.
+type Rlimits struct {
+	// CPU time, in seconds.
+	CPUSeconds uint64 `json:"cpu_seconds"`
+
+	// Size of virtual memory, in bytes.
+	AddressSpace uint64 `json:"address_space"`
+
+	OpenFiles uint64 `json:"open_files"`
+
+	// Number of processes of the user the command runs as, including ones the job didn't start.
+	Processes uint64 `json:"processes"`
+}
+
+func (r *Rlimits) isEmpty() bool {
+	return *r == Rlimits{}
+}
+
+// lookupUser looks up a user by name or else by id.
+func lookupUser(nameOrID string) (*user.User, error) {
+	u, err := user.Lookup(nameOrID)
+	if err == nil {
+		return u, nil
+	}
+	if u, idErr := user.LookupId(nameOrID); idErr == nil {
+		return u, nil
+	}
+	return nil, err
+}
+
+// lookupGroup looks up a group by name or else by id.
+func lookupGroup(nameOrID string) (*user.Group, error) {
+	g, err := user.LookupGroup(nameOrID)
+	if err == nil {
+		return g, nil
+	}
+	if g, idErr := user.LookupGroupId(nameOrID); idErr == nil {
+		return g, nil
+	}
+	return nil, err
+}
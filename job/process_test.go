@@ -0,0 +1,91 @@
+package job
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Commands with resource limits are started by re-running the test binary, as they would be NextKala.
+	ExecWithRlimits()
+	os.Exit(m.Run())
+}
+
+func TestRunAsValidation(t *testing.T) {
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("No nobody user")
+	}
+	defer func() { AllowedRunAsUsers = nil }()
+
+	j := GetMockJob()
+	j.RunAs = RunAs{User: "nobody"}
+	assert.Equal(t, ErrRunAsNotAllowed, j.validation())
+
+	AllowedRunAsUsers = []string{"nobody"}
+	assert.NoError(t, j.validation())
+
+	j.RunAs = RunAs{User: nobody.Uid}
+	assert.NoError(t, j.validation())
+
+	j.RunAs = RunAs{Group: "root"}
+	assert.Equal(t, ErrRunAsNotAllowed, j.validation())
+
+	j.RunAs = RunAs{User: "no-such-user"}
+	assert.Equal(t, ErrRunAsNotAllowed, j.validation())
+}
+
+func TestRunAs(t *testing.T) {
+	if _, err := user.Lookup("nobody"); err != nil || os.Geteuid() != 0 || runtime.GOOS == "windows" {
+		t.Skip("Switching user needs root, and a nobody user")
+	}
+	defer func() { AllowedRunAsUsers = nil }()
+
+	j := GetMockJob()
+	j.Command = "id -un"
+	j.RunAs = RunAs{User: "nobody"}
+	r := &JobRunner{job: j}
+
+	_, err := r.LocalRun()
+	assert.Equal(t, ErrRunAsNotAllowed, err)
+
+	AllowedRunAsUsers = []string{"nobody"}
+	out, err := r.LocalRun()
+	assert.NoError(t, err)
+	assert.Equal(t, "nobody", out)
+
+	// The script, private to NextKala, is given to the user.
+	j.JobType = ScriptJob
+	j.Script = "id -un"
+	out, err = r.ScriptRun()
+	assert.NoError(t, err)
+	assert.Equal(t, "nobody", out)
+}
+
+func TestRlimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Resource limits are only supported on Linux")
+	}
+
+	j := GetMockJob()
+	// The command has the limits from the start, as both the soft and hard limits.
+	j.Command = "bash -c 'ulimit -Sn; ulimit -Hn; ulimit -t; ulimit -u'"
+	j.Rlimits = Rlimits{OpenFiles: 64, CPUSeconds: 30}
+	r := &JobRunner{job: j}
+
+	out, err := r.LocalRun()
+	assert.NoError(t, err)
+	noLimit, err := exec.Command("bash", "-c", "ulimit -u").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "64\n64\n30\n"+strings.TrimSpace(string(noLimit)), out)
+
+	j.Command = "no-such-command"
+	_, err = r.LocalRun()
+	assert.Error(t, err)
+}
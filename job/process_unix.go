@@ -0,0 +1,86 @@
+//go:build !windows
+// +build !windows
+
+package job
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a process group of its own, so that
+// stopping it stops any processes it started too.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// setCredential makes the command run as the given user and group, with the user's other groups.
+func setCredential(cmd *exec.Cmd, runAs RunAs) error {
+	u, err := lookupUser(runAs.User)
+	if err != nil {
+		return err
+	}
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		return err
+	}
+
+	gid := u.Gid
+	if runAs.Group != "" {
+		g, err := lookupGroup(runAs.Group)
+		if err != nil {
+			return err
+		}
+		if g.Gid != gid && !containsString(groupIDs, g.Gid) {
+			return ErrRunAsGroupNotMember
+		}
+		gid = g.Gid
+	}
+
+	credential := &syscall.Credential{}
+	if credential.Uid, err = parseID(u.Uid); err != nil {
+		return err
+	}
+	if credential.Gid, err = parseID(gid); err != nil {
+		return err
+	}
+	for _, id := range groupIDs {
+		groupID, err := parseID(id)
+		if err != nil {
+			return err
+		}
+		credential.Groups = append(credential.Groups, groupID)
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = credential
+	return nil
+}
+
+// chownToCredential makes the file at path the user's and group's the command runs as.
+func chownToCredential(path string, cmd *exec.Cmd) error {
+	credential := cmd.SysProcAttr.Credential
+	return os.Chown(path, int(credential.Uid), int(credential.Gid))
+}
+
+func parseID(id string) (uint32, error) {
+	parsed, err := strconv.ParseUint(id, 10, 32)
+	return uint32(parsed), err
+}
+
+// terminateProcessGroup asks the started command's process group to stop, with SIGTERM.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// killProcessGroup kills the started command's process group, with SIGKILL.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
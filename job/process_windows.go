@@ -0,0 +1,25 @@
+package job
+
+import (
+	"os/exec"
+)
+
+// Windows has no process groups to signal; only the command itself is stopped, and it's killed straight away.
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func setCredential(cmd *exec.Cmd, runAs RunAs) error {
+	return ErrRunAsUnsupported
+}
+
+func chownToCredential(path string, cmd *exec.Cmd) error {
+	return ErrRunAsUnsupported
+}
+
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,196 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrInvalidResourcePool = errors.New("Invalid Resource Pool. Pools must have a name and at least one slot")
+	ErrInvalidPoolClaim    = errors.New("Invalid Job pools. Each must name a pool, and can't claim a negative number of slots")
+	ErrPoolClaimTooLarge   = errors.New("Job claims more slots of a resource pool than it has")
+	ErrResourcePoolInUse   = errors.New("Resource pool is still in the pools of jobs, which would fail without it")
+)
+
+// ErrResourcePoolNotFound is raised when a ResourcePool is unable to be found within a database.
+type ErrResourcePoolNotFound string
+
+func (name ErrResourcePoolNotFound) Error() string {
+	return fmt.Sprintf("Resource pool with name of %s not found.", string(name))
+}
+
+// ResourcePool is a named number of slots shared between the jobs that claim some of them in their Pools,
+// e.g. for the connections a database can take. Runs wait until there are enough slots free in all
+// of the pools their job claims.
+type ResourcePool struct {
+	Name  string `json:"name"`
+	Slots int    `json:"slots"`
+}
+
+func (p *ResourcePool) Validate() error {
+	if p.Name == "" || p.Slots < 1 {
+		return ErrInvalidResourcePool
+	}
+	return nil
+}
+
+// PoolClaim is a job's claim on slots of a ResourcePool, for each of its runs.
+type PoolClaim struct {
+	Name string `json:"name"`
+
+	// Zero means 1.
+	Slots int `json:"slots"`
+}
+
+func (c PoolClaim) slots() int {
+	if c.Slots == 0 {
+		return 1
+	}
+	return c.Slots
+}
+
+func validPoolClaims(claims []PoolClaim) bool {
+	for _, c := range claims {
+		if c.Name == "" || c.Slots < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPoolClaims returns an error if any of the pools claimed don't exist, or don't have
+// as many slots as claimed.
+func checkPoolClaims(cache JobCache, claims []PoolClaim) error {
+	_, err := poolSizes(cache, claims)
+	return err
+}
+
+// poolSizes returns the slots of each of the pools claimed, by name.
+func poolSizes(cache JobCache, claims []PoolClaim) (map[string]int, error) {
+	sizes := make(map[string]int, len(claims))
+	for name, n := range claimedSlots(claims) {
+		p, err := cache.GetResourcePool(name)
+		if err != nil {
+			return nil, err
+		}
+		if n > p.Slots {
+			return nil, ErrPoolClaimTooLarge
+		}
+		sizes[name] = p.Slots
+	}
+	return sizes, nil
+}
+
+// claimedSlots adds up the slots claimed of each pool, by name.
+func claimedSlots(claims []PoolClaim) map[string]int {
+	wanted := make(map[string]int, len(claims))
+	for _, c := range claims {
+		wanted[c.Name] += c.slots()
+	}
+	return wanted
+}
+
+// The slots of resource pools in use, by pool name.
+var resourceSlots = struct {
+	sync.Mutex
+	used map[string]int
+	// Closed, and replaced, when slots are freed or pools change.
+	changed chan struct{}
+}{used: map[string]int{}, changed: make(chan struct{})}
+
+// notifyResourcePools wakes runs waiting for slots, to check again.
+// It must be called with resourceSlots locked.
+func notifyResourcePools() {
+	close(resourceSlots.changed)
+	resourceSlots.changed = make(chan struct{})
+}
+
+// ResourcePoolsChanged is to be called when resource pools are saved or deleted,
+// for runs waiting for slots to check them again.
+func ResourcePoolsChanged() {
+	resourceSlots.Lock()
+	defer resourceSlots.Unlock()
+	notifyResourcePools()
+}
+
+// acquirePools waits until there are enough slots free in each of the pools claimed, and takes them
+// all together, so that runs holding some slots don't wait on each other for the rest.
+// It gives up if the run's context is done first, or the pools no longer fit the claims.
+func (j *JobRunner) acquirePools(cache JobCache, claims []PoolClaim, queued func()) error {
+	if len(claims) == 0 {
+		return nil
+	}
+	wanted := claimedSlots(claims)
+
+	for waited := false; ; waited = true {
+		// Looked up each time, as pools can be resized while runs wait for them.
+		sizes, err := poolSizes(cache, claims)
+		if err != nil {
+			return err
+		}
+
+		resourceSlots.Lock()
+		if poolsHaveRoom(wanted, sizes) {
+			for name, n := range wanted {
+				resourceSlots.used[name] += n
+			}
+			resourceSlots.Unlock()
+			j.pools = wanted
+			return nil
+		}
+		changed := resourceSlots.changed
+		resourceSlots.Unlock()
+
+		if !waited {
+			queued()
+		}
+		select {
+		case <-changed:
+		case <-j.Context().Done():
+			return j.Context().Err()
+		}
+	}
+}
+
+// poolsHaveRoom says whether there are enough slots free for those wanted. resourceSlots must be locked.
+func poolsHaveRoom(wanted, sizes map[string]int) bool {
+	for name, n := range wanted {
+		if resourceSlots.used[name]+n > sizes[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// releasePools gives back the slots of resource pools taken by acquirePools.
+func (j *JobRunner) releasePools() {
+	resourceSlots.Lock()
+	defer resourceSlots.Unlock()
+
+	for name, n := range j.pools {
+		resourceSlots.used[name] -= n
+		if resourceSlots.used[name] <= 0 {
+			delete(resourceSlots.used, name)
+		}
+	}
+	notifyResourcePools()
+}
+
+// ResourcePoolUsage returns how many slots of each resource pool are in use, by pool name.
+func ResourcePoolUsage() map[string]int {
+	resourceSlots.Lock()
+	defer resourceSlots.Unlock()
+
+	usage := make(map[string]int, len(resourceSlots.used))
+	for name, n := range resourceSlots.used {
+		usage[name] = n
+	}
+	return usage
+}
+
+// sortResourcePools sorts pools by name.
+func sortResourcePools(pools []*ResourcePool) {
+	sort.Slice(pools, func(i, k int) bool { return pools[i].Name < pools[k].Name })
+}
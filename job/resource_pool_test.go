@@ -0,0 +1,108 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourcePoolErrors(t *testing.T) {
+	for _, p := range []*ResourcePool{{Slots: 1}, {Name: "empty"}, {Name: "negative", Slots: -1}} {
+		assert.Equal(t, ErrInvalidResourcePool, p.Validate(), p.Name)
+	}
+
+	j := GetMockJob()
+	j.Pools = []PoolClaim{{Name: "db", Slots: -1}}
+	assert.Equal(t, ErrInvalidPoolClaim, j.validation())
+
+	cache := NewMockCache()
+	j = GetMockJobWithGenericSchedule(time.Now())
+	j.Pools = []PoolClaim{{Name: "missing"}}
+	assert.Equal(t, ErrResourcePoolNotFound("missing"), j.Init(cache))
+
+	assert.NoError(t, cache.SaveResourcePool(&ResourcePool{Name: "db", Slots: 2}))
+	j.Pools = []PoolClaim{{Name: "db"}, {Name: "db", Slots: 2}}
+	assert.Equal(t, ErrPoolClaimTooLarge, j.Init(cache))
+}
+
+func TestResourcePoolQueued(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	assert.NoError(t, cache.SaveResourcePool(&ResourcePool{Name: "db", Slots: 1}))
+
+	// Take the only slot.
+	resourceSlots.Lock()
+	resourceSlots.used["db"] = 1
+	resourceSlots.Unlock()
+	defer ResourcePoolsChanged()
+	defer func() {
+		resourceSlots.Lock()
+		delete(resourceSlots.used, "db")
+		resourceSlots.Unlock()
+	}()
+
+	j := GetMockJob()
+	j.Id = "pooled"
+	j.Pools = []PoolClaim{{Name: "db"}}
+	assert.NoError(t, cache.Set(j))
+	done := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(done)
+	}()
+
+	var running []*JobStat
+	for i := 0; i < 100 && (len(running) == 0 || running[0].Status != Status.Queued); i++ {
+		time.Sleep(10 * time.Millisecond)
+		running = RunningStats(j.Id)
+	}
+	if assert.Len(t, running, 1) {
+		assert.Equal(t, Status.Queued, running[0].Status)
+	}
+
+	// The job isn't locked while the run waits, and changing its claims doesn't change the run's.
+	j.lock.Lock()
+	j.Pools = []PoolClaim{{Name: "db", Slots: 2}}
+	j.lock.Unlock()
+
+	// Making room in the pool lets the run go ahead.
+	assert.NoError(t, cache.SaveResourcePool(&ResourcePool{Name: "db", Slots: 2}))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The run should have gone ahead once there was a slot")
+	}
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Success, stats[0].Status)
+		assert.True(t, stats[0].WaitDuration > 0)
+	}
+	assert.Equal(t, 1, ResourcePoolUsage()["db"], "The run's slot should have been given back")
+}
+
+func TestResourcePoolDeleted(t *testing.T) {
+	db := NewMemoryDB()
+	cache := NewLockFreeJobCache(db)
+	assert.NoError(t, cache.SaveResourcePool(&ResourcePool{Name: "gone", Slots: 1}))
+
+	j := GetMockJob()
+	j.Id = "pool-deleted"
+	j.Pools = []PoolClaim{{Name: "gone"}}
+	assert.NoError(t, cache.Set(j))
+	// Not while the job claims it.
+	assert.Equal(t, ErrResourcePoolInUse, cache.DeleteResourcePool("gone"))
+
+	// Should it go missing all the same, the job's runs fail.
+	assert.NoError(t, db.DeleteResourcePool("gone"))
+	ResourcePoolsChanged()
+
+	j.Run(cache)
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Failed, stats[0].Status)
+		assert.Equal(t, ErrResourcePoolNotFound("gone").Error(), stats[0].Output)
+	}
+	assert.Empty(t, ResourcePoolUsage())
+}
@@ -0,0 +1,240 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/nextiva/nextkala/utils/iso8601"
+)
+
+// DefaultRetryMultiplier is what each delay between retries is multiplied by for the next one,
+// unless the RetryPolicy sets a Multiplier.
+const DefaultRetryMultiplier = 2
+
+// MaxRetryAfter is the longest a response's Retry-After header can hold up a retry,
+// unless the RetryPolicy sets a MaxDelay.
+const MaxRetryAfter = time.Hour
+
+// Classes of network error that remote jobs can be retried on.
+const (
+	// The request timed out.
+	NetworkErrorTimeout = "timeout"
+	// The connection was refused, reset or closed before a response.
+	NetworkErrorConnection = "connection"
+	// The host name couldn't be resolved.
+	NetworkErrorDNS = "dns"
+)
+
+var (
+	ErrInvalidRetryPolicy = errors.New("Invalid Job retry policy. Delays must be ISO 8601 durations, " +
+		"the multiplier at least 1 and the jitter from 0 to 1")
+	ErrInvalidRetryOn = errors.New("Invalid Job retry_on. Network errors supported: timeout, connection and dns")
+)
+
+// ErrUnexpectedStatus is returned by RemoteRun when the response's status code isn't one of the expected ones.
+type ErrUnexpectedStatus struct {
+	StatusCode int
+	// e.g. "503 Service Unavailable"
+	Status string
+	Body   string
+	// The response's Retry-After header, if any.
+	RetryAfter string
+}
+
+func (e *ErrUnexpectedStatus) Error() string {
+	return e.Status + e.Body
+}
+
+// RetryPolicy says how long to wait before each of a job's Retries. Each delay is Multiplier times
+// the one before, starting from InitialDelay, up to MaxDelay.
+// e.g. {"initial_delay": "PT1S", "multiplier": 2, "max_delay": "PT1M", "jitter": 0.2}
+type RetryPolicy struct {
+	// ISO 8601 Duration to wait before the first retry. Empty means retries follow failures straight away.
+	InitialDelay string `json:"initial_delay"`
+
+	// Zero means DefaultRetryMultiplier.
+	Multiplier float64 `json:"multiplier"`
+
+	// ISO 8601 Duration the delays stop growing at. Empty means they don't.
+	MaxDelay string `json:"max_delay"`
+
+	// Fraction of each delay, from 0 to 1, to take off at random,
+	// so that runs that failed together don't all retry together.
+	Jitter float64 `json:"jitter"`
+}
+
+func (p *RetryPolicy) validate() error {
+	for _, d := range []string{p.InitialDelay, p.MaxDelay} {
+		if d == "" {
+			continue
+		}
+		if _, err := iso8601.FromString(d); err != nil {
+			return ErrInvalidRetryPolicy
+		}
+	}
+	if (p.Multiplier != 0 && p.Multiplier < 1) || p.Jitter < 0 || p.Jitter > 1 {
+		return ErrInvalidRetryPolicy
+	}
+	return nil
+}
+
+// delay returns how long to wait, from now, before retry number retry (counting from 0).
+func (p *RetryPolicy) delay(retry uint, now time.Time) time.Duration {
+	if p.InitialDelay == "" {
+		return 0
+	}
+	initial, err := iso8601.FromString(p.InitialDelay)
+	if err != nil {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+	d := float64(initial.RelativeTo(now)) * math.Pow(multiplier, float64(retry))
+
+	if p.MaxDelay != "" {
+		if maxDelay, err := iso8601.FromString(p.MaxDelay); err == nil {
+			d = math.Min(d, float64(maxDelay.RelativeTo(now)))
+		}
+	}
+	d = math.Min(d, math.MaxInt64)
+
+	d -= d * p.Jitter * rand.Float64() //nolint:gosec // Doesn't need to be unpredictable
+	return time.Duration(d)
+}
+
+// maxRetryAfter returns the longest a response's Retry-After header can hold up a retry: MaxDelay,
+// or else MaxRetryAfter.
+func (p *RetryPolicy) maxRetryAfter(now time.Time) time.Duration {
+	if p.MaxDelay != "" {
+		if maxDelay, err := iso8601.FromString(p.MaxDelay); err == nil {
+			return maxDelay.RelativeTo(now)
+		}
+	}
+	return MaxRetryAfter
+}
+
+// RetryConditions says which failures of a job are retried. A failure that matches none of them
+// fails the run straight away. Without any conditions, every failure is retried.
+// e.g. {"status_codes": [429, 502, 503], "network_errors": ["timeout", "connection"]}
+type RetryConditions struct {
+	// Exit codes of a local job's command.
+	ExitCodes []int `json:"exit_codes"`
+
+	// Status codes of a remote job's response.
+	StatusCodes []int `json:"status_codes"`
+
+	// Classes of network error making a remote job's request: timeout, connection or dns.
+	NetworkErrors []string `json:"network_errors"`
+}
+
+func (c *RetryConditions) validate() error {
+	for _, class := range c.NetworkErrors {
+		switch class {
+		case NetworkErrorTimeout, NetworkErrorConnection, NetworkErrorDNS:
+		default:
+			return ErrInvalidRetryOn
+		}
+	}
+	return nil
+}
+
+func (c *RetryConditions) isEmpty() bool {
+	return len(c.ExitCodes) == 0 && len(c.StatusCodes) == 0 && len(c.NetworkErrors) == 0
+}
+
+// matches says whether the run failing with err should be retried.
+func (c *RetryConditions) matches(err error) bool {
+	if c.isEmpty() {
+		return true
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return containsInt(c.ExitCodes, exitErr.ExitCode())
+	}
+
+	var statusErr *ErrUnexpectedStatus
+	if errors.As(err, &statusErr) {
+		return containsInt(c.StatusCodes, statusErr.StatusCode)
+	}
+
+	class := networkErrorClass(err)
+	for _, want := range c.NetworkErrors {
+		if class == want {
+			return true
+		}
+	}
+	return false
+}
+
+// networkErrorClass returns which of the classes of network error err is, or "" if none.
+func networkErrorClass(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return NetworkErrorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return NetworkErrorTimeout
+	case errors.As(err, &opErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return NetworkErrorConnection
+	}
+	return ""
+}
+
+// retryAfter returns how long from now the Retry-After header of the 429 or 503 response
+// that err is for asks to wait, up to limit, or zero if there isn't one.
+func retryAfter(err error, now time.Time, limit time.Duration) time.Duration {
+	var statusErr *ErrUnexpectedStatus
+	if !errors.As(err, &statusErr) || statusErr.RetryAfter == "" {
+		return 0
+	}
+	if statusErr.StatusCode != http.StatusTooManyRequests && statusErr.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	// Either a number of seconds or an HTTP date.
+	var d time.Duration
+	if seconds, err := strconv.Atoi(statusErr.RetryAfter); err == nil {
+		if seconds > int(limit/time.Second) {
+			return limit
+		}
+		d = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(statusErr.RetryAfter); err == nil {
+		d = t.Sub(now)
+	}
+	if d > limit {
+		return limit
+	}
+	return d
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
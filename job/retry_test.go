@@ -0,0 +1,245 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mixer/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:00")
+
+	p := RetryPolicy{InitialDelay: "PT1S", MaxDelay: "PT5S"}
+	for retry, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		assert.Equal(t, expected, p.delay(uint(retry), now), "Retry %d", retry)
+	}
+
+	p = RetryPolicy{InitialDelay: "PT10S", Multiplier: 1.5, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := p.delay(1, now)
+		assert.True(t, d > 7500*time.Millisecond && d <= 15*time.Second, d)
+	}
+
+	p = RetryPolicy{}
+	assert.Equal(t, time.Duration(0), p.delay(3, now))
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	for _, p := range []RetryPolicy{
+		{InitialDelay: "1s"},
+		{InitialDelay: "PT1S", MaxDelay: "forever"},
+		{InitialDelay: "PT1S", Multiplier: 0.5},
+		{InitialDelay: "PT1S", Jitter: 1.5},
+	} {
+		j := GetMockJob()
+		j.RetryPolicy = p
+		assert.Equal(t, ErrInvalidRetryPolicy, j.validation(), "%+v", p)
+	}
+
+	j := GetMockJob()
+	j.RetryPolicy = RetryPolicy{InitialDelay: "PT1S", Multiplier: 3, MaxDelay: "PT1M", Jitter: 0.1}
+	assert.NoError(t, j.validation())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var requests int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "something failed", http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	clk := clock.NewMockClock(parseTime(t, "2020-Jan-13 10:00"))
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL})
+	j.Retries = 3
+	j.RetryPolicy = RetryPolicy{InitialDelay: "PT1S"}
+	j.clk.SetClock(clk)
+
+	done := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(done)
+	}()
+
+	for _, step := range []struct {
+		wait     time.Duration
+		requests int32
+	}{
+		{0, 1},
+		{999 * time.Millisecond, 1},
+		{time.Millisecond, 2},
+		{2 * time.Second, 3},
+		{4 * time.Second, 4},
+	} {
+		clk.AddTime(step.wait)
+		briefPause()
+		assert.Equal(t, step.requests, atomic.LoadInt32(&requests), "After another %s", step.wait)
+
+		// The job isn't locked while the run waits to retry.
+		locked := make(chan struct{})
+		go func() {
+			j.lock.Lock()
+			j.lock.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			t.Fatalf("The job was locked after another %s", step.wait)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Job failed to finish")
+	}
+	j.lock.RLock()
+	assert.Equal(t, 4, int(j.Metadata.ErrorCount))
+	j.lock.RUnlock()
+}
+
+func TestRetryBackoffRespectsEpsilon(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:00")
+	j := GetMockRecurringJobWithSchedule(now, "PT1H")
+	j.Epsilon = "PT5S"
+	j.NextRunAt = now
+	j.clk.SetClock(clock.NewMockClock(now))
+	assert.NoError(t, j.InitDelayDuration(false))
+
+	r := &JobRunner{job: j, currentRetries: 1}
+	assert.True(t, r.shouldRetry(4*time.Second))
+	assert.False(t, r.shouldRetry(6*time.Second))
+}
+
+func TestRetryConditionsMatch(t *testing.T) {
+	r := &JobRunner{job: &Job{Name: "mock_job", Command: "bash -c 'exit 3'"}}
+	_, exitErr := r.LocalRun()
+	assert.Error(t, exitErr)
+
+	statusErr := fmt.Errorf("wrapped: %w", &ErrUnexpectedStatus{StatusCode: http.StatusServiceUnavailable})
+	dnsErr := &url.Error{Op: "Get", URL: "http://nowhere.invalid", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}
+	refusedErr := &url.Error{Op: "Get", URL: "http://127.0.0.1:1", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}
+	timeoutErr := &url.Error{Op: "Get", URL: "http://127.0.0.1:1", Err: context.DeadlineExceeded}
+
+	matchesTableTests := []struct {
+		Name       string
+		Conditions RetryConditions
+		Err        error
+		Expected   bool
+	}{
+		{"No conditions", RetryConditions{}, errors.New("anything"), true},
+		{"Exit code", RetryConditions{ExitCodes: []int{1, 3}}, exitErr, true},
+		{"Other exit code", RetryConditions{ExitCodes: []int{1}}, exitErr, false},
+		{"Status code", RetryConditions{StatusCodes: []int{503}}, statusErr, true},
+		{"Other status code", RetryConditions{StatusCodes: []int{429}, NetworkErrors: []string{"timeout"}}, statusErr, false},
+		{"DNS", RetryConditions{NetworkErrors: []string{"dns"}}, dnsErr, true},
+		{"Connection", RetryConditions{NetworkErrors: []string{"connection"}}, refusedErr, true},
+		{"Connection, not timeout", RetryConditions{NetworkErrors: []string{"timeout"}}, refusedErr, false},
+		{"Timeout", RetryConditions{NetworkErrors: []string{"timeout"}}, timeoutErr, true},
+		{"Other error", RetryConditions{NetworkErrors: []string{"timeout", "connection", "dns"}}, errors.New("anything"), false},
+	}
+	for _, testStruct := range matchesTableTests {
+		assert.Equal(t, testStruct.Expected, testStruct.Conditions.matches(testStruct.Err), testStruct.Name)
+	}
+
+	j := GetMockJob()
+	j.RetryOn = RetryConditions{NetworkErrors: []string{"flaky"}}
+	assert.Equal(t, ErrInvalidRetryOn, j.validation())
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := parseTime(t, "2020-Jan-13 10:00")
+
+	err := &ErrUnexpectedStatus{StatusCode: http.StatusTooManyRequests, RetryAfter: "120"}
+	assert.Equal(t, 2*time.Minute, retryAfter(err, now, MaxRetryAfter))
+	assert.Equal(t, time.Minute, retryAfter(err, now, time.Minute))
+
+	err = &ErrUnexpectedStatus{StatusCode: http.StatusTooManyRequests, RetryAfter: "9223372036854775807"}
+	assert.Equal(t, MaxRetryAfter, retryAfter(err, now, MaxRetryAfter))
+
+	err = &ErrUnexpectedStatus{StatusCode: http.StatusServiceUnavailable, RetryAfter: "Mon, 13 Jan 2020 10:00:30 GMT"}
+	assert.Equal(t, 30*time.Second, retryAfter(err, now, MaxRetryAfter))
+
+	err = &ErrUnexpectedStatus{StatusCode: http.StatusServiceUnavailable, RetryAfter: "Mon, 13 Jan 2100 10:00:30 GMT"}
+	assert.Equal(t, MaxRetryAfter, retryAfter(err, now, MaxRetryAfter))
+
+	err = &ErrUnexpectedStatus{StatusCode: http.StatusInternalServerError, RetryAfter: "120"}
+	assert.Equal(t, time.Duration(0), retryAfter(err, now, MaxRetryAfter))
+
+	assert.Equal(t, time.Duration(0), retryAfter(errors.New("anything"), now, MaxRetryAfter))
+
+	p := RetryPolicy{InitialDelay: "PT1S", MaxDelay: "PT5M"}
+	assert.Equal(t, 5*time.Minute, p.maxRetryAfter(now))
+	p = RetryPolicy{InitialDelay: "PT1S"}
+	assert.Equal(t, MaxRetryAfter, p.maxRetryAfter(now))
+}
+
+func TestRetryOnFailsFast(t *testing.T) {
+	var requests int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer testServer.Close()
+
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL})
+	j.Retries = 3
+	j.RetryOn = RetryConditions{StatusCodes: []int{http.StatusServiceUnavailable}}
+	j.Run(cache)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 1, int(j.Metadata.ErrorCount))
+}
+
+func TestRetryAfterHonored(t *testing.T) {
+	var requests int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "10")
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer testServer.Close()
+
+	clk := clock.NewMockClock(parseTime(t, "2020-Jan-13 10:00"))
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL})
+	j.Retries = 1
+	j.RetryPolicy = RetryPolicy{InitialDelay: "PT1S"}
+	j.RetryOn = RetryConditions{StatusCodes: []int{http.StatusServiceUnavailable}}
+	j.clk.SetClock(clk)
+
+	done := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(done)
+	}()
+
+	briefPause()
+	clk.AddTime(9 * time.Second)
+	briefPause()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	clk.AddTime(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Job failed to finish")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, 1, int(j.Metadata.SuccessCount))
+}
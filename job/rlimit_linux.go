@@ -0,0 +1,83 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// rlimitsArg is what NextKala is re-run with, first, to start a local job's command with resource limits.
+const rlimitsArg = "__nextkala_rlimits"
+
+// Exit code of a re-run that couldn't start the command, as a shell's for a command it can't execute.
+const rlimitsExitCode = 126
+
+// Whether ExecWithRlimits has been called, so that re-running the program starts the command.
+var rlimitsHooked bool
+
+// setRlimits makes the command start with the resource limits, as both its soft and hard limits, by re-running
+// NextKala to set them on itself, then exec the command in its place. Limits can't be set on the command
+// any other way before it has started.
+func setRlimits(cmd *exec.Cmd, limits Rlimits) error {
+	if limits.isEmpty() {
+		return nil
+	}
+	if !rlimitsHooked {
+		return ErrRlimitsHookMissing
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	path, err := exec.LookPath(cmd.Path)
+	if err != nil {
+		return err
+	}
+
+	args := []string{self, rlimitsArg}
+	for _, value := range []uint64{limits.CPUSeconds, limits.AddressSpace, limits.OpenFiles, limits.Processes} {
+		args = append(args, strconv.FormatUint(value, 10))
+	}
+	cmd.Path = self
+	cmd.Args = append(append(args, path), cmd.Args...)
+	return nil
+}
+
+// ExecWithRlimits sets the resource limits and execs the command that setRlimits re-ran NextKala with,
+// if it was re-run for that, and otherwise does nothing. It must be called before anything else in main.
+// Programs that run jobs with the package, rather than NextKala itself, must call it too: commands with
+// Rlimits are started by re-running the program, and their runs fail with ErrRlimitsHookMissing unless
+// it has been called.
+func ExecWithRlimits() {
+	rlimitsHooked = true
+	const fixedArgs = 7 // NextKala, rlimitsArg, the 4 limits and the command's path.
+	if len(os.Args) <= fixedArgs || os.Args[1] != rlimitsArg {
+		return
+	}
+
+	err := execWithRlimits(os.Args[2:6], os.Args[6], os.Args[fixedArgs:])
+	fmt.Fprintf(os.Stderr, "Error setting resource limits: %v\n", err)
+	os.Exit(rlimitsExitCode)
+}
+
+// execWithRlimits sets the limits, in the order of Rlimits' fields, and execs the command in place of NextKala.
+// It only returns if that fails.
+func execWithRlimits(limits []string, path string, argv []string) error {
+	for i, resource := range []int{unix.RLIMIT_CPU, unix.RLIMIT_AS, unix.RLIMIT_NOFILE, unix.RLIMIT_NPROC} {
+		value, err := strconv.ParseUint(limits[i], 10, 64)
+		if err != nil {
+			return err
+		}
+		if value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: value, Max: value}); err != nil {
+			return err
+		}
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
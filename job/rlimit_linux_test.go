@@ -0,0 +1,20 @@
+package job
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRlimitsHookMissing(t *testing.T) {
+	// Without the hook in main, re-running the program wouldn't start the command.
+	rlimitsHooked = false
+	defer func() { rlimitsHooked = true }()
+
+	j := GetMockJob()
+	j.Command = "bash -c 'ulimit -Sn'"
+	j.Rlimits = Rlimits{OpenFiles: 64}
+	_, err := (&JobRunner{job: j}).LocalRun()
+	assert.True(t, errors.Is(err, ErrRlimitsHookMissing))
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package job
+
+import "os/exec"
+
+func setRlimits(cmd *exec.Cmd, limits Rlimits) error {
+	if limits.isEmpty() {
+		return nil
+	}
+	return ErrRlimitsUnsupported
+}
+
+// ExecWithRlimits does nothing, as resource limits for jobs are only supported on Linux,
+// where programs that run jobs with the package must call it first in main.
+func ExecWithRlimits() {}
@@ -0,0 +1,150 @@
+package job
+
+import (
+	"sync"
+	"time"
+)
+
+// RunLog is the log of a run in progress: what a local job's command writes to stdout and
+// stderr, across all of the run's attempts. The last MaxOutputSize bytes of it are kept for
+// following as it grows, and its start and end, like the other outputs, for the JobStat.
+// Runs of other types of job have logs too, which stay empty, for their stats while in progress.
+type RunLog struct {
+	stat JobStat
+
+	lock sync.Mutex
+	// The last size bytes written.
+	recent []byte
+	size   int
+	// Bytes written in all.
+	total int64
+	saved *outputBuffer
+	done  bool
+	// Closed, and replaced, on each write and once the run is done.
+	changed chan struct{}
+}
+
+var runLogs = struct {
+	sync.Mutex
+	m map[string]*RunLog
+}{m: map[string]*RunLog{}}
+
+// startRunLog starts the log of the run that stat is for.
+func startRunLog(stat *JobStat) *RunLog {
+	l := &RunLog{
+		stat:    *stat,
+		size:    MaxOutputSize,
+		saved:   newOutputBuffer(),
+		changed: make(chan struct{}),
+	}
+	l.stat.Status = Status.Started
+
+	runLogs.Lock()
+	defer runLogs.Unlock()
+	runLogs.m[stat.Id] = l
+	return l
+}
+
+// GetRunLog returns the log of the run with the given id, if it's still in progress.
+func GetRunLog(runID string) (*RunLog, bool) {
+	runLogs.Lock()
+	defer runLogs.Unlock()
+	l, ok := runLogs.m[runID]
+	return l, ok
+}
+
+// RunningStats returns stats for the runs of a job in progress, Queued or Started.
+func RunningStats(jobID string) []*JobStat {
+	runLogs.Lock()
+	defer runLogs.Unlock()
+
+	var stats []*JobStat
+	for _, l := range runLogs.m {
+		if stat := l.Stat(); stat.JobId == jobID {
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// Stat returns a stat for the run as it started, with its status now.
+func (l *RunLog) Stat() *JobStat {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	stat := l.stat
+	return &stat
+}
+
+func (l *RunLog) setStatus(status JobStatus) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.stat.Status = status
+}
+
+// started records that the run has stopped waiting for slots, after wait.
+func (l *RunLog) started(wait time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.stat.Status = Status.Started
+	l.stat.WaitDuration = wait
+}
+
+func (l *RunLog) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.total += int64(len(p))
+	l.recent = append(l.recent, p...)
+	if l.size > 0 && len(l.recent) > l.size {
+		l.recent = l.recent[len(l.recent)-l.size:]
+	}
+	l.saved.Write(p) //nolint:errcheck // Never fails
+	l.notify()
+	return len(p), nil
+}
+
+// Since returns what's been written to the log from offset on, or from as far back as it still
+// has if that's later, with the offset to ask for next. It also returns whether the run is done,
+// and, if not, a channel that's closed when there's more to read.
+func (l *RunLog) Since(offset int64) ([]byte, int64, bool, <-chan struct{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	unread := l.total - offset
+	if unread > int64(len(l.recent)) || unread < 0 {
+		unread = int64(len(l.recent))
+	}
+	data := make([]byte, unread)
+	copy(data, l.recent[int64(len(l.recent))-unread:])
+	return data, l.total, l.done, l.changed
+}
+
+// String returns the log as kept for the JobStat.
+func (l *RunLog) String() string {
+	return l.saved.String()
+}
+
+// end marks the run done, and drops the log from those in progress.
+func (l *RunLog) end() {
+	l.lock.Lock()
+	l.done = true
+	l.notify()
+	l.lock.Unlock()
+
+	runLogs.Lock()
+	defer runLogs.Unlock()
+	delete(runLogs.m, l.stat.Id)
+}
+
+func (l *RunLog) notify() {
+	select {
+	case <-l.changed:
+		// Already done.
+		return
+	default:
+	}
+	close(l.changed)
+	if !l.done {
+		l.changed = make(chan struct{})
+	}
+}
@@ -0,0 +1,54 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunLog(t *testing.T) {
+	defer func(size int) { MaxOutputSize = size }(MaxOutputSize)
+	MaxOutputSize = 10
+
+	stat := NewJobStat("job-id")
+	l := startRunLog(stat)
+	got, ok := GetRunLog(stat.Id)
+	assert.True(t, ok)
+	assert.Equal(t, l, got)
+	if running := RunningStats("job-id"); assert.Len(t, running, 1) {
+		assert.Equal(t, stat.Id, running[0].Id)
+		assert.Equal(t, Status.Started, running[0].Status)
+	}
+
+	l.Write([]byte("hello "))
+	data, offset, done, changed := l.Since(0)
+	assert.Equal(t, "hello ", string(data))
+	assert.False(t, done)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Write([]byte("world"))
+	}()
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("The log should have said it changed")
+	}
+	data, offset, _, _ = l.Since(offset)
+	assert.Equal(t, "world", string(data))
+
+	// Only the last 10 bytes are kept for following.
+	l.Write([]byte(", and goodbye"))
+	data, _, _, _ = l.Since(offset)
+	assert.Equal(t, "nd goodbye", string(data))
+	assert.Equal(t, "hello\n... 14 bytes truncated ...\nodbye", l.String())
+
+	l.end()
+	_, _, done, changed = l.Since(0)
+	assert.True(t, done)
+	<-changed
+	_, ok = GetRunLog(stat.Id)
+	assert.False(t, ok)
+	assert.Empty(t, RunningStats("job-id"))
+}
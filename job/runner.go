@@ -5,22 +5,51 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mattn/go-shellwords"
 	log "github.com/sirupsen/logrus"
 )
 
 type JobRunner struct {
-	job  *Job
-	meta Metadata
+	job   *Job
+	meta  Metadata
+	cache JobCache
+	// Cancelled when the run is replaced by another.
+	ctx context.Context
 
 	numberOfAttempts uint
 	currentRetries   uint
 	currentStat      *JobStat
+	// Of the run, while it's in progress.
+	log *RunLog
+	// Whether the run has a slot in the pool of runs in progress.
+	slot bool
+	// Slots of its job's resource Pools the run has, by pool name.
+	pools map[string]int
+	// The run of a parent job that started this one, if any.
+	parent *ParentRun
+	// Id of the WorkflowRun the run is part of, if any.
+	workflowRunID string
+	// What the run's dependents are told of it, once it has succeeded.
+	result *ParentRun
+	// Stat of a run that the server stopped while it waited for its callback, carried on with as if
+	// its attempt had ended with resumeErr. See Job.resume.
+	resumed   *JobStat
+	resumeErr error
+	// Whether its executor saved the run's stat as it started, for it not to be saved again once it succeeds.
+	statSaved bool
+
+	// Guarded by the job's runState.
+	runID     string
+	cancelled bool
 }
 
 var (
@@ -28,15 +57,38 @@ var (
 	ErrCmdIsEmpty        = errors.New("Job Command is empty.")
 	ErrJobTypeInvalid    = errors.New("Job Type is not valid.")
 	ErrInvalidDelimiters = errors.New("Job has invalid templating delimiters.")
+	ErrJobSkipped        = errors.New("Job run skipped, as a previous run is still in progress.")
+	ErrJobReplaced       = errors.New("Job run cancelled, as it was replaced by a newer run.")
+	ErrJobTimedOut       = errors.New("Job run timed out.")
+	ErrJobCancelled      = errors.New("Job run cancelled.")
+	ErrRunNotInProgress  = errors.New("Job run not found among the job's runs in progress.")
 )
 
 // Run calls the appropriate run function, collects metadata around the success
 // or failure of the Job's execution, and schedules the next run.
+//
+// The job's ConcurrencyPolicy is applied first. A run that is skipped because of it returns
+// ErrJobSkipped, and a stat recording that. Otherwise the caller must call release once it
+// is done with the results.
 func (j *JobRunner) Run(cache JobCache) (*JobStat, Metadata, error) {
+	if !j.acquire() {
+		log.Infof("Job %s:%s skipped, as a previous run is still in progress.", j.job.Name, j.job.Id)
+		return j.skippedStat(), j.meta, ErrJobSkipped
+	}
+
 	j.job.lock.RLock()
 	defer j.job.lock.RUnlock()
 
-	j.meta.LastAttemptedRun = j.job.clk.Time().Now()
+	j.meta = j.job.Metadata
+	// A resumed run was attempted, and scheduled, before the server stopped.
+	if j.resumed == nil {
+		j.meta.LastAttemptedRun = j.job.clk.Time().Now()
+		// Unless the run was started early, e.g. by hand.
+		j.meta.LastScheduledRun = j.job.NextRunAt
+		if j.meta.LastScheduledRun.IsZero() || j.meta.LastScheduledRun.After(j.meta.LastAttemptedRun) {
+			j.meta.LastScheduledRun = j.meta.LastAttemptedRun
+		}
+	}
 
 	if j.job.Disabled {
 		log.Infof("Job %s tried to run, but exited early because its disabled.", j.job.Name)
@@ -45,29 +97,81 @@ func (j *JobRunner) Run(cache JobCache) (*JobStat, Metadata, error) {
 
 	log.Infof("Job %s:%s started.", j.job.Name, j.job.Id)
 
+	j.cache = cache
 	j.runSetup()
+	runs := j.job.runs()
+	runs.Lock()
+	j.runID = j.currentStat.Id
+	runs.Unlock()
+	j.log = startRunLog(j.currentStat)
+	defer j.releaseSlot()
+	// A resumed run takes its slots if it's retried.
+	if j.resumeErr != nil {
+		log.Infof("Job %s:%s run %s resumed, as it stopped waiting for its callback: %v", j.job.Name, j.job.Id,
+			j.currentStat.Id, j.resumeErr)
+	} else if err := j.acquireSlot(cache); err != nil && j.ctx.Err() == nil {
+		log.Errorf("Error getting the resource pools of job %s:%s: %v", j.job.Name, j.job.Id, err)
+
+		j.currentStat.Output = err.Error()
+		j.collectStats(Status.Failed)
+		j.meta.ErrorCount++
+		j.meta.LastError = j.job.clk.Time().Now()
+		j.meta.NumberOfFinishedRuns++
+
+		return j.currentStat, j.meta, err
+	}
 
 	var out string
 	for {
 		var err error
 		switch {
+		case j.ctx.Err() != nil:
+			// Replaced while waiting to retry.
+			err = j.ctx.Err()
+		case j.resumeErr != nil:
+			// What the resumed run's last attempt ended with.
+			out, err, j.resumeErr = j.resumeErr.Error(), j.resumeErr, nil
 		case j.job.succeedInstantly:
 			out = "Job succeeded instantly for test purposes."
-		case j.job.JobType == LocalJob:
-			out, err = j.LocalRun()
-		case j.job.JobType == RemoteJob:
-			j.currentStat.Status = Status.Started
-			err = cache.SaveRun(j.currentStat)
-			if err != nil {
-				log.Errorf("Error saving initial job status: %v", err)
-			}
-			out, err = j.RemoteRun()
 		default:
-			err = ErrJobTypeInvalid
+			if !j.slot {
+				// Given up by the previous attempt, while it waited for its callback, or not taken by a resumed run.
+				if err = j.takeSlots(cache); err != nil {
+					break
+				}
+				j.log.setStatus(Status.Started)
+			}
+			executor, ok := executorFor(j.job.JobType)
+			if !ok {
+				err = ErrJobTypeInvalid
+				break
+			}
+			out, err = executor.Execute(j)
 		}
 
 		j.currentStat.Output = out
 
+		if err != nil && j.wasCancelled() {
+			log.Infof("Job %s:%s with execution id %s was cancelled.", j.job.Name, j.job.Id, j.currentStat.Id)
+
+			j.currentStat.Output = ErrJobCancelled.Error()
+			j.collectStats(Status.Cancelled)
+			j.meta.NumberOfFinishedRuns++
+
+			return j.currentStat, j.meta, ErrJobCancelled
+		}
+
+		if err != nil && j.ctx.Err() != nil {
+			log.Infof("Job %s:%s with execution id %s was replaced by a newer run.", j.job.Name, j.job.Id,
+				j.currentStat.Id)
+
+			j.currentStat.Output = err.Error()
+			j.collectStats(Status.Failed)
+			j.meta.NumberOfFinishedRuns++
+
+			return j.currentStat, j.meta, ErrJobReplaced
+		}
+
 		if err != nil {
 			// Log Error in Metadata
 			log.Errorf("Error running job %s with execution id %s: %v", j.currentStat.JobId, j.currentStat.Id,
@@ -82,12 +186,26 @@ func (j *JobRunner) Run(cache JobCache) (*JobStat, Metadata, error) {
 			j.meta.LastError = j.job.clk.Time().Now()
 
 			// Handle retrying
-			if j.shouldRetry() {
-				j.currentRetries--
-				continue
+			if j.job.RetryOn.matches(err) {
+				now := j.job.clk.Time().Now()
+				delay := j.job.RetryPolicy.delay(j.job.Retries-j.currentRetries, now)
+				if after := retryAfter(err, now, j.job.RetryPolicy.maxRetryAfter(now)); after > delay {
+					delay = after
+				}
+				if j.shouldRetry(delay) {
+					j.currentRetries--
+					j.waitToRetry(delay)
+					continue
+				}
+			} else if j.currentRetries > 0 {
+				log.Infof("Job %s:%s not retrying, as the error isn't one it retries on.", j.job.Name, j.job.Id)
 			}
 
-			j.collectStats(Status.Failed)
+			if errors.Is(err, ErrJobTimedOut) {
+				j.collectStats(Status.TimedOut)
+			} else {
+				j.collectStats(Status.Failed)
+			}
 			j.meta.NumberOfFinishedRuns++
 
 			// TODO: Wrap error into something better.
@@ -104,21 +222,24 @@ func (j *JobRunner) Run(cache JobCache) (*JobStat, Metadata, error) {
 	j.meta.NumberOfFinishedRuns++
 	j.meta.LastSuccess = j.job.clk.Time().Now()
 
-	if j.job.JobType == RemoteJob {
-		// Stats have already been saved.
+	j.result = newParentRun(j.currentStat, Status.Success)
+	if j.statSaved {
 		j.currentStat = nil
 	} else {
 		j.collectStats(Status.Success)
 	}
 
-	// Run Dependent Jobs
-	if len(j.job.DependentJobs) != 0 {
+	// Dependent jobs need slots of their own.
+	j.releaseSlot()
+
+	// Run Dependent Jobs, unless the run is part of a workflow run, whose edges say what runs next.
+	if len(j.job.DependentJobs) != 0 && j.workflowRunID == "" {
 		for _, id := range j.job.DependentJobs {
 			newJob, err := cache.Get(id)
 			if err != nil {
 				log.Errorf("Error retrieving dependent job with id of %s", id)
 			} else {
-				newJob.Run(cache)
+				newJob.runAfter(cache, j.result)
 			}
 		}
 	}
@@ -128,7 +249,44 @@ func (j *JobRunner) Run(cache JobCache) (*JobStat, Metadata, error) {
 
 // LocalRun executes the Job's local shell command
 func (j *JobRunner) LocalRun() (string, error) {
-	return j.runCmd()
+	// Get the actual command we're going to be running,
+	// including any necessary templating.
+	cmdText, err := j.templatizeLocal(j.job.Command)
+	if err != nil {
+		return "", fmt.Errorf("Error templatizing command: %v", err)
+	}
+	args, err := initShParser().Parse(cmdText)
+	if err != nil {
+		return "", err
+	}
+	return j.runCmd(args, "")
+}
+
+// ScriptRun executes the Job's script with its interpreter
+func (j *JobRunner) ScriptRun() (string, error) {
+	script, err := j.writeScript()
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(script)
+	args, err := j.job.interpreterArgs(script)
+	if err != nil {
+		return "", err
+	}
+	return j.runCmd(args, script)
+}
+
+// saveStarted saves the run's stat as Started, for it to be seen while the run is in progress.
+// If final, it isn't saved again once the run succeeds.
+func (j *JobRunner) saveStarted(final bool) {
+	if j.currentStat == nil {
+		return
+	}
+	j.currentStat.Status = Status.Started
+	if err := j.cache.SaveRun(j.currentStat); err != nil {
+		log.Errorf("Error saving initial job status: %v", err)
+	}
+	j.statSaved = final
 }
 
 // RemoteRun sends a http request, and checks if the response is valid in time,
@@ -136,7 +294,7 @@ func (j *JobRunner) RemoteRun() (string, error) {
 	// Calculate a response timeout
 	timeout := j.job.ResponseTimeout()
 
-	ctx := context.Background()
+	ctx := j.Context()
 	if timeout > 0 {
 		var cncl func()
 		ctx, cncl = context.WithTimeout(ctx, timeout)
@@ -144,11 +302,11 @@ func (j *JobRunner) RemoteRun() (string, error) {
 	}
 	// Get the actual url and body we're going to be using,
 	// including any necessary templating.
-	url, err := j.job.TryTemplatize(j.job.RemoteProperties.Url)
+	url, err := j.templatize(j.job.RemoteProperties.Url)
 	if err != nil {
 		return "", fmt.Errorf("Error templatizing url: %v", err)
 	}
-	body, err := j.job.TryTemplatize(j.job.RemoteProperties.Body)
+	body, err := j.templatize(j.job.RemoteProperties.Body)
 	if err != nil {
 		return "", fmt.Errorf("Error templatizing body: %v", err)
 	}
@@ -180,73 +338,261 @@ func (j *JobRunner) RemoteRun() (string, error) {
 
 	// Set default or user's passed headers
 	j.setHeaders(req, token)
+	if j.job.RemoteProperties.Async && j.currentStat != nil {
+		// Not among the job's own headers, which are shared by its runs.
+		req.Header = req.Header.Clone()
+		req.Header.Set(CallbackTokenHeader, callbackToken(j.currentStat.Id, j.attempt()))
+	}
+
+	if j.currentStat != nil {
+		j.currentStat.clearOutput()
+	}
+
+	client, err := j.job.HTTPClient()
+	if err != nil {
+		return "", err
+	}
 
 	// Do the request
-	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	start := time.Now()
+	res, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
 	defer res.Body.Close()
-	b, err := ioutil.ReadAll(res.Body)
-	if err != nil {
+	resBody := newOutputBuffer()
+	asserted := newAssertedBody()
+	var w io.Writer = resBody
+	if j.job.RemoteProperties.Assertions.needBody() {
+		w = io.MultiWriter(resBody, asserted)
+	}
+	if _, err := io.Copy(w, res.Body); err != nil {
 		return "", err
 	}
+	latency := time.Since(start)
+	b := resBody.String()
+
+	if j.currentStat != nil {
+		j.currentStat.Stdout = b
+		j.currentStat.OutputTruncated = resBody.Truncated()
+		j.currentStat.StatusCode = res.StatusCode
+	}
 
 	// Check if we got any of the status codes the user asked for
 	if j.checkExpected(res.StatusCode) {
-		return string(b), nil
+		err := j.job.RemoteProperties.Assertions.check(res, asserted.Bytes(), asserted.complete, latency,
+			j.job.clk.Time().Now())
+		if err != nil {
+			return err.Error(), err
+		}
+		return b, nil
 	} else {
-		return "", errors.New(res.Status + string(b))
+		return "", &ErrUnexpectedStatus{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Body:       b,
+			RetryAfter: res.Header.Get("Retry-After"),
+		}
 	}
 }
 
+// Environment variables in commands are expanded by expandEnv rather than by the parser,
+// which only knows about NextKala's own environment.
 func initShParser() *shellwords.Parser {
 	shParser := shellwords.NewParser()
-	shParser.ParseEnv = true
+	shParser.ParseEnv = false
 	shParser.ParseBacktick = true
 	return shParser
 }
 
-func (j *JobRunner) runCmd() (string, error) {
-	j.numberOfAttempts++
+// Same as shellwords' own.
+var envVarRe = regexp.MustCompile(`\$({[a-zA-Z0-9_]+}|[a-zA-Z0-9_]+)`)
 
-	// Get the actual command we're going to be running,
-	// including any necessary templating.
-	cmdText, err := j.job.TryTemplatize(j.job.Command)
-	if err != nil {
-		return "", fmt.Errorf("Error templatizing command: %v", err)
-	}
+// Environment variables left in commands for their shells to expand, if they run one, as they could hold anything.
+var unexpandedEnv = map[string]bool{"NEXTKALA_PARENT_OUTPUT": true}
+
+// expandEnv replaces the environment variables in a word of a command with their values,
+// from env or else NextKala's environment.
+func expandEnv(word string, env map[string]string) string {
+	return envVarRe.ReplaceAllStringFunc(word, func(s string) string {
+		name := strings.Trim(s[1:], "{}")
+		if unexpandedEnv[name] {
+			return s
+		}
+		if value, ok := env[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	})
+}
+
+// runCmd runs the command args, in the job's environment. script is the file of the job's script
+// the command runs, if any, for it to be given to the user the job runs as.
+func (j *JobRunner) runCmd(args []string, script string) (string, error) {
+	j.numberOfAttempts++
 
-	// Execute command
-	shParser := initShParser()
-	args, err := shParser.Parse(cmdText)
+	env, err := j.env()
 	if err != nil {
 		return "", err
 	}
+
 	if len(args) == 0 {
 		return "", ErrCmdIsEmpty
 	}
+	for i := range args {
+		args[i] = expandEnv(args[i], env)
+	}
 
 	cmd := exec.Command(args[0], args[1:]...) //nolint:gosec // That's the job description
-	out, err := cmd.CombinedOutput()
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Dir = j.job.WorkingDir
+	if j.job.Stdin != "" {
+		cmd.Stdin = strings.NewReader(j.job.Stdin)
+	}
+	stdout, stderr, out := newOutputBuffer(), newOutputBuffer(), newOutputBuffer()
+	combined := io.Writer(out)
+	if j.log != nil {
+		combined = io.MultiWriter(out, j.log)
+	}
+	cmd.Stdout = io.MultiWriter(stdout, combined)
+	cmd.Stderr = io.MultiWriter(stderr, combined)
+	setProcessGroup(cmd)
+	if j.job.RunAs.User != "" {
+		// The allowed users may have changed since the job was saved.
+		if !j.job.RunAs.allowed() {
+			return "", ErrRunAsNotAllowed
+		}
+		if err := setCredential(cmd, j.job.RunAs); err != nil {
+			return "", fmt.Errorf("Error setting the user to run as: %v", err)
+		}
+		if script != "" {
+			if err := chownToCredential(script, cmd); err != nil {
+				return "", fmt.Errorf("Error giving the script to the user to run as: %v", err)
+			}
+		}
+	}
+	if err := setRlimits(cmd, j.job.Rlimits); err != nil {
+		return "", fmt.Errorf("Error setting resource limits: %w", err)
+	}
+
+	if j.currentStat != nil {
+		j.currentStat.clearOutput()
+	}
+	err = j.waitForCmd(cmd)
+	if j.currentStat != nil {
+		j.currentStat.Stdout = stdout.String()
+		j.currentStat.Stderr = stderr.String()
+		j.currentStat.OutputTruncated = stdout.Truncated() || stderr.Truncated()
+		if cmd.ProcessState != nil {
+			exitCode := cmd.ProcessState.ExitCode()
+			j.currentStat.ExitCode = &exitCode
+		}
+	}
 	if err != nil {
-		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(out.String()))
 	}
-	return strings.TrimSpace(string(out)), nil
+	return strings.TrimSpace(out.String()), nil
 }
 
-func (j *JobRunner) shouldRetry() bool {
+// env returns the job's environment variables for its command, including the ids
+// of the job and the run as set in the headers of remote jobs, the parent run's, and the workflow run's.
+func (j *JobRunner) env() (map[string]string, error) {
+	env := make(map[string]string, len(j.job.Env)+2) //nolint:gomnd
+	for key, value := range j.job.Env {
+		value, err := j.templatizeLocal(value)
+		if err != nil {
+			return nil, fmt.Errorf("Error templatizing env %s: %v", key, err)
+		}
+		env[key] = value
+	}
+
+	env["NEXTKALA_JOB_ID"] = j.job.Id
+	if j.currentStat != nil {
+		env["NEXTKALA_RUN_ID"] = j.currentStat.Id
+	}
+	if j.parent != nil {
+		j.parent.setEnv(env)
+	}
+	if j.workflowRunID != "" {
+		env["NEXTKALA_WORKFLOW_RUN_ID"] = j.workflowRunID
+	}
+	return env, nil
+}
+
+// templatize returns content templated for the run.
+func (j *JobRunner) templatize(content string) (string, error) {
+	return j.job.templatize(content, j.parent)
+}
+
+// templatizeLocal returns a local job's command, script or environment variable templated for the run,
+// without the parent run's output.
+func (j *JobRunner) templatizeLocal(content string) (string, error) {
+	return j.job.templatize(content, j.parent.withoutOutput())
+}
+
+// waitForCmd starts the command and waits for it to finish. If it runs past the job's Timeout,
+// or the run is replaced, its process group is asked to stop, and killed if it hasn't
+// within the KillGracePeriod.
+func (j *JobRunner) waitForCmd(cmd *exec.Cmd) error {
+	ctx := j.Context()
+	if timeout := j.job.localTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Infof("Job %s:%s stopping command: %s", j.job.Name, j.job.Id, ctx.Err())
+	if err := terminateProcessGroup(cmd); err != nil {
+		log.Errorf("Error stopping command of job %s:%s: %s", j.job.Name, j.job.Id, err)
+	}
+	select {
+	case <-done:
+	case <-time.After(j.job.killGracePeriod()):
+		log.Warnf("Job %s:%s command didn't stop in time; killing it.", j.job.Name, j.job.Id)
+		if err := killProcessGroup(cmd); err != nil {
+			log.Errorf("Error killing command of job %s:%s: %s", j.job.Name, j.job.Id, err)
+		}
+		<-done
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && j.Context().Err() == nil {
+		return ErrJobTimedOut
+	}
+	return ctx.Err()
+}
+
+// shouldRetry says whether the job should be retried after the given delay.
+func (j *JobRunner) shouldRetry(delay time.Duration) bool {
 	// Check number of retries left
 	if j.currentRetries == 0 {
 		return false
 	}
 
 	// Check Epsilon
-	if j.job.Epsilon != "" && j.job.Schedule != "" {
+	if j.job.Epsilon != "" && j.job.hasSchedule() {
 		if !j.job.epsilonDuration.IsZero() {
-			timeSinceStart := j.job.clk.Time().Now().Sub(j.job.NextRunAt)
+			// Counted from when the run was started, after any Jitter.
+			startedAt := j.job.NextRunAt.Add(j.job.jitterDelay(j.job.NextRunAt))
+			timeSinceStart := j.job.clk.Time().Now().Sub(startedAt)
 			timeLeftToRetry := j.job.epsilonDuration.RelativeTo(j.job.clk.Time().Now()) - timeSinceStart
-			if timeLeftToRetry < 0 {
+			if timeLeftToRetry < delay {
 				return false
 			}
 		}
@@ -255,9 +601,219 @@ func (j *JobRunner) shouldRetry() bool {
 	return true
 }
 
+// waitToRetry waits on the job's clock for the delay before a retry, or until the run is replaced,
+// without the job's lock.
+func (j *JobRunner) waitToRetry(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	log.Infof("Job %s:%s retrying in %s.", j.job.Name, j.job.Id, delay)
+
+	after := j.job.clk.Time().After(delay)
+	j.unlocked(func() {
+		select {
+		case <-after:
+		case <-j.Context().Done():
+		}
+	})
+}
+
+// runState is the bookkeeping for a job's ConcurrencyPolicy. It's kept behind a pointer, rather than in
+// the job, so that copying the job, e.g. to serialize it, doesn't copy what runs change as they start.
+// It's guarded by its own lock rather than the job's, as runs hold a read lock on the job for as long as they take.
+type runState struct {
+	sync.Mutex
+	done    *sync.Cond
+	running map[*JobRunner]context.CancelFunc
+	queued  bool
+}
+
+// Guards making runStates for jobs that haven't been through Init.
+var runStatesLock sync.Mutex
+
+// runs returns the job's runState, making it if it hasn't been made yet.
+func (j *Job) runs() *runState {
+	runStatesLock.Lock()
+	defer runStatesLock.Unlock()
+
+	if j.runState == nil {
+		s := &runState{running: make(map[*JobRunner]context.CancelFunc)}
+		s.done = sync.NewCond(s)
+		j.runState = s
+	}
+	return j.runState
+}
+
+// acquire applies the job's ConcurrencyPolicy, waiting for or cancelling runs in progress as needed.
+// It returns false if this run should be skipped.
+func (j *JobRunner) acquire() bool {
+	j.job.lock.RLock()
+	policy := j.job.ConcurrencyPolicy
+	j.job.lock.RUnlock()
+	if j.resumed != nil {
+		// It was already in progress.
+		policy = ConcurrencyAllow
+	}
+
+	runs := j.job.runs()
+	runs.Lock()
+	defer runs.Unlock()
+
+	switch policy {
+	case ConcurrencyForbid:
+		if len(runs.running) > 0 {
+			return false
+		}
+	case ConcurrencyQueue:
+		if len(runs.running) > 0 {
+			if runs.queued {
+				return false
+			}
+			runs.queued = true
+			for len(runs.running) > 0 {
+				runs.done.Wait()
+			}
+			runs.queued = false
+		}
+	case ConcurrencyReplace:
+		for len(runs.running) > 0 {
+			for _, cancel := range runs.running {
+				cancel()
+			}
+			runs.done.Wait()
+		}
+	}
+
+	var cancel context.CancelFunc
+	j.ctx, cancel = context.WithCancel(context.Background())
+	runs.running[j] = cancel
+	return true
+}
+
+// release marks the run as no longer in progress. It does nothing for a run that didn't go ahead.
+func (j *JobRunner) release() {
+	runs := j.job.runs()
+	runs.Lock()
+	defer runs.Unlock()
+
+	if cancel, ok := runs.running[j]; ok {
+		cancel()
+		delete(runs.running, j)
+		runs.done.Broadcast()
+	}
+	if j.log != nil {
+		// The run's stat has been saved by now, with the log.
+		j.log.end()
+	}
+}
+
+// acquireSlot waits for slots of the job's resource Pools, then for one in the pool of runs in progress,
+// unless the run is cancelled or replaced first, recording the run as Queued meanwhile and how long
+// it waited in the stat. Slots that are waited for at once are held until the run is done,
+// so resource pools come first, in order not to hold up other jobs' runs while waiting for them.
+func (j *JobRunner) acquireSlot(cache JobCache) error {
+	start := j.job.clk.Time().Now()
+
+	if err := j.takeSlots(cache); err != nil {
+		return err
+	}
+
+	j.currentStat.WaitDuration = j.job.clk.Time().Now().Sub(start)
+	j.log.started(j.currentStat.WaitDuration)
+	return nil
+}
+
+// takeSlots waits for the slots acquireSlot does, recording the run as Queued meanwhile.
+func (j *JobRunner) takeSlots(cache JobCache) error {
+	// Without the job's lock, so that the job can be read and updated while the run waits.
+	name, id, priority, claims := j.job.Name, j.job.Id, j.job.Priority, j.job.Pools
+	var err error
+	j.unlocked(func() {
+		err = j.acquirePools(cache, claims, func() {
+			log.Infof("Job %s:%s queued, waiting for slots of its resource pools.", name, id)
+			j.log.setStatus(Status.Queued)
+		})
+		if err != nil {
+			return
+		}
+		err = pool.acquire(j.Context(), priority, func() {
+			log.Infof("Job %s:%s queued, as %d runs are in progress.", name, id, MaxConcurrentRuns)
+			j.log.setStatus(Status.Queued)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	j.slot = true
+	return nil
+}
+
+func (j *JobRunner) releaseSlot() {
+	if j.slot {
+		pool.release()
+		j.slot = false
+	}
+	if j.pools != nil {
+		j.releasePools()
+		j.pools = nil
+	}
+}
+
+// unlocked calls wait without the job's lock, which the run holds otherwise,
+// so that the job can be read and updated meanwhile.
+func (j *JobRunner) unlocked(wait func()) {
+	j.job.lock.RUnlock()
+	defer j.job.lock.RLock()
+	wait()
+}
+
+// wasCancelled says whether the run was cancelled with CancelRun.
+func (j *JobRunner) wasCancelled() bool {
+	runs := j.job.runs()
+	runs.Lock()
+	defer runs.Unlock()
+	return j.cancelled
+}
+
+// Job returns the job being run.
+func (j *JobRunner) Job() *Job {
+	return j.job
+}
+
+// Stat returns the stat of the run in progress, if any.
+func (j *JobRunner) Stat() *JobStat {
+	return j.currentStat
+}
+
+// Context returns the run's context, which is cancelled if the run is cancelled or replaced.
+func (j *JobRunner) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+func (j *JobRunner) skippedStat() *JobStat {
+	stat := NewJobStat(j.job.Id)
+	stat.WorkflowRunId = j.workflowRunID
+	stat.Status = Status.Skipped
+	stat.Output = ErrJobSkipped.Error()
+	return stat
+}
+
 func (j *JobRunner) runSetup() {
+	if j.resumed != nil {
+		j.currentStat = j.resumed
+		j.currentRetries = 0
+		if j.resumed.NumberOfRetries < j.job.Retries {
+			j.currentRetries = j.job.Retries - j.resumed.NumberOfRetries
+		}
+		return
+	}
+
 	// Setup Job Stat
 	j.currentStat = NewJobStat(j.job.Id)
+	j.currentStat.WorkflowRunId = j.workflowRunID
 	j.currentStat.Status = Status.Success
 
 	// Init retries
@@ -268,9 +824,16 @@ func (j *JobRunner) collectStats(status JobStatus) {
 	j.currentStat.ExecutionDuration = j.job.clk.Time().Now().Sub(j.currentStat.RanAt)
 	j.currentStat.Status = status
 	j.currentStat.NumberOfRetries = j.job.Retries - j.currentRetries
+	if j.log != nil && j.numberOfAttempts > 1 {
+		j.currentStat.Log = j.log.String()
+	}
 }
 
 func (j *JobRunner) checkExpected(statusCode int) bool {
+	// Async jobs' requests are accepted, to be completed later.
+	if len(j.job.RemoteProperties.ExpectedResponseCodes) == 0 && j.job.RemoteProperties.Async {
+		return statusCode == http.StatusAccepted
+	}
 	// If no expected response codes passed, add 200 status code as expected
 	if len(j.job.RemoteProperties.ExpectedResponseCodes) == 0 {
 		j.job.RemoteProperties.ExpectedResponseCodes = append(j.job.RemoteProperties.ExpectedResponseCodes, 200)
@@ -288,7 +851,13 @@ func (j *JobRunner) checkExpected(statusCode int) bool {
 func (j *JobRunner) setHeaders(req *http.Request, token string) {
 	j.job.SetHeaders(req, token)
 	if j.currentStat != nil {
-		j.job.RemoteProperties.Headers.Set("NextKala-JobId", j.job.Id)
-		j.job.RemoteProperties.Headers.Set("NextKala-RunId", j.currentStat.Id)
+		req.Header.Set("NextKala-JobId", j.job.Id)
+		req.Header.Set("NextKala-RunId", j.currentStat.Id)
+	}
+	if j.parent != nil {
+		j.parent.setHeaders(req.Header)
+	}
+	if j.workflowRunID != "" {
+		req.Header.Set("NextKala-WorkflowRunId", j.workflowRunID)
 	}
 }
@@ -1,10 +1,18 @@
 package job
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -137,3 +145,318 @@ func TestTemplatize(t *testing.T) {
 	})
 
 }
+
+var concurrencyPolicyTableTests = []struct {
+	Policy   concurrencyPolicy
+	Statuses map[JobStatus]int
+}{
+	{Policy: "", Statuses: map[JobStatus]int{Status.Success: 3}},
+	{Policy: ConcurrencyAllow, Statuses: map[JobStatus]int{Status.Success: 3}},
+	{Policy: ConcurrencyForbid, Statuses: map[JobStatus]int{Status.Success: 1, Status.Skipped: 2}},
+	// The first run finishes, one of the others waits for it and the last is skipped.
+	{Policy: ConcurrencyQueue, Statuses: map[JobStatus]int{Status.Success: 2, Status.Skipped: 1}},
+	// Each run cancels the one before.
+	{Policy: ConcurrencyReplace, Statuses: map[JobStatus]int{Status.Success: 1, Status.Failed: 2}},
+}
+
+// Starts two more runs while a first one is in progress, and checks the history of the three.
+func TestConcurrencyPolicy(t *testing.T) {
+	for _, testStruct := range concurrencyPolicyTableTests {
+		cache := NewLockFreeJobCache(NewMemoryDB())
+		j := GetMockJob()
+		j.Id = "concurrent_" + string(testStruct.Policy)
+		j.Command = "sleep 0.5"
+		j.ConcurrencyPolicy = testStruct.Policy
+		assert.NoError(t, cache.Set(j))
+
+		var wg sync.WaitGroup
+		run := func() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				j.Run(cache)
+			}()
+		}
+
+		run()
+		awaitRunning(t, j)
+		run()
+		run()
+		wg.Wait()
+
+		stats, err := cache.GetAllRuns(j.Id)
+		assert.NoError(t, err)
+		statuses := map[JobStatus]int{}
+		for _, stat := range stats {
+			statuses[stat.Status]++
+		}
+		assert.Equal(t, testStruct.Statuses, statuses, "Policy %q", testStruct.Policy)
+	}
+}
+
+// Serializing a job while its runs start and end mustn't race with them; run with -race.
+func TestMarshalJobWhileRunning(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	j := GetMockJob()
+	j.ConcurrencyPolicy = ConcurrencyQueue
+	assert.NoError(t, cache.Set(j))
+	// As Init does.
+	j.runs()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.Run(cache)
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		_, err := json.Marshal(j)
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+}
+
+func TestConcurrencyPolicyValidation(t *testing.T) {
+	j := GetMockJob()
+	j.ConcurrencyPolicy = "sometimes"
+	assert.Equal(t, ErrInvalidConcurrencyPolicy, j.validation())
+
+	j.ConcurrencyPolicy = ConcurrencyQueue
+	assert.NoError(t, j.validation())
+}
+
+func awaitRunning(t *testing.T, j *Job) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < time.Second*5; time.Sleep(time.Millisecond) {
+		runs := j.runs()
+		runs.Lock()
+		running := len(runs.running)
+		runs.Unlock()
+		if running > 0 {
+			return
+		}
+	}
+	t.Fatal("Job failed to start running")
+}
+
+func TestLocalJobTimeout(t *testing.T) {
+	cache := NewMockCache()
+	j := GetMockJob()
+	j.Command = "bash -c 'sleep 30'"
+	j.Timeout = "PT1S"
+	j.Retries = 0
+	assert.NoError(t, cache.Set(j))
+
+	start := time.Now()
+	j.Run(cache)
+	assert.WithinDuration(t, start.Add(time.Second), time.Now(), time.Second)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.TimedOut, stats[0].Status)
+	}
+	assert.Equal(t, 1, int(j.Metadata.ErrorCount))
+}
+
+func TestLocalJobTimeoutKillsProcessGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timeout")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "marker")
+
+	// Ignores SIGTERM, and leaves a child behind that would touch the marker if it outlived the job.
+	j := GetMockJob()
+	j.Command = fmt.Sprintf(`bash -c "trap '' TERM; (sleep 3; touch %s) & sleep 30"`, marker)
+	j.Timeout = "PT1S"
+	j.KillGracePeriod = "PT1S"
+
+	start := time.Now()
+	r := &JobRunner{job: j}
+	_, err = r.LocalRun()
+	assert.True(t, errors.Is(err, ErrJobTimedOut), err)
+	assert.WithinDuration(t, start.Add(2*time.Second), time.Now(), time.Second)
+
+	time.Sleep(2 * time.Second)
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "The command's child should have been killed too")
+}
+
+func TestTimeoutValidation(t *testing.T) {
+	j := GetMockJob()
+	j.Timeout = "30m"
+	assert.Equal(t, ErrInvalidTimeout, j.validation())
+
+	j.Timeout = "PT30M"
+	j.KillGracePeriod = "PT5S"
+	assert.NoError(t, j.validation())
+}
+
+func TestLocalJobEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workdir")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// The temporary directory may be behind a symlink.
+	dir, err = filepath.EvalSymlinks(dir)
+	assert.NoError(t, err)
+
+	j := &Job{
+		Name:               "mock_job",
+		Id:                 "job-id",
+		Command:            `bash -c 'echo "$GREETING/$NEXTKALA_JOB_ID/$NEXTKALA_RUN_ID"; pwd; cat'`,
+		Env:                map[string]string{"GREETING": "hello {{$.Name}}"},
+		WorkingDir:         dir,
+		Stdin:              "from stdin",
+		TemplateDelimiters: "{{ }}",
+	}
+	r := &JobRunner{job: j}
+	r.runSetup()
+
+	out, err := r.LocalRun()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello mock_job/job-id/"+r.currentStat.Id+"\n"+dir+"\nfrom stdin", out)
+
+	j.Env["BROKEN"] = "{{$.Nope}}"
+	_, err = r.LocalRun()
+	assert.Error(t, err)
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("NEXTKALA_TEST_VAR", "from nextkala")
+	defer os.Unsetenv("NEXTKALA_TEST_VAR")
+
+	env := map[string]string{"JOB_VAR": "from job", "NEXTKALA_TEST_VAR": "overridden"}
+	assert.Equal(t, "from job-overridden-", expandEnv("$JOB_VAR-${NEXTKALA_TEST_VAR}-$UNSET_VAR", env))
+	assert.Equal(t, "from nextkala", expandEnv("$NEXTKALA_TEST_VAR", nil))
+	assert.Equal(t, "$NEXTKALA_PARENT_OUTPUT", expandEnv("$NEXTKALA_PARENT_OUTPUT", map[string]string{"NEXTKALA_PARENT_OUTPUT": "`id`"}))
+}
+
+func TestLocalJobOutput(t *testing.T) {
+	cache := NewMockCache()
+	j := GetMockJob()
+	j.Command = `bash -c 'echo out; echo err >&2; exit 3'`
+	j.Retries = 0
+	assert.NoError(t, cache.Set(j))
+	j.Run(cache)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, "out\n", stats[0].Stdout)
+		assert.Equal(t, "err\n", stats[0].Stderr)
+		assert.False(t, stats[0].OutputTruncated)
+		if assert.NotNil(t, stats[0].ExitCode) {
+			assert.Equal(t, 3, *stats[0].ExitCode)
+		}
+	}
+}
+
+func TestLocalJobLog(t *testing.T) {
+	cache := NewMockCache()
+	j := GetMockJob()
+	j.Command = `bash -c 'echo out; echo err >&2; exit 3'`
+	j.Retries = 0
+	assert.NoError(t, cache.Set(j))
+	j.Run(cache)
+
+	// A single attempt's log is its stdout and stderr, so isn't kept twice.
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Empty(t, stats[0].Log)
+		assert.Equal(t, "out\nerr\n", stats[0].CombinedLog())
+	}
+
+	cache = NewMockCache()
+	j.Retries = 1
+	assert.NoError(t, cache.Set(j))
+	j.Run(cache)
+
+	stats, err = cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, "out\nerr\nout\nerr\n", stats[0].Log)
+		assert.Equal(t, stats[0].Log, stats[0].CombinedLog())
+	}
+}
+
+func TestOutputTruncated(t *testing.T) {
+	defer func(size int) { MaxOutputSize = size }(MaxOutputSize)
+	MaxOutputSize = 100
+
+	r := &JobRunner{job: GetMockJob()}
+	r.job.Command = `bash -c 'yes | head -n 1000'`
+	r.runSetup()
+	_, err := r.LocalRun()
+	assert.NoError(t, err)
+	assert.True(t, r.currentStat.OutputTruncated)
+	assert.Equal(t, strings.Repeat("y\n", 25)+"\n... 1900 bytes truncated ...\n"+strings.Repeat("y\n", 25),
+		r.currentStat.Stdout)
+	if assert.NotNil(t, r.currentStat.ExitCode) {
+		assert.Equal(t, 0, *r.currentStat.ExitCode)
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, strings.Repeat("x", 1000))
+	}))
+	defer testServer.Close()
+
+	r = &JobRunner{job: GetMockRemoteJob(RemoteProperties{Url: testServer.URL, ExpectedResponseCodes: []int{http.StatusAccepted}})}
+	r.runSetup()
+	_, err = r.RemoteRun()
+	assert.NoError(t, err)
+	assert.True(t, r.currentStat.OutputTruncated)
+	assert.Len(t, r.currentStat.Stdout, 100+len("\n... 900 bytes truncated ...\n"))
+	assert.Equal(t, http.StatusAccepted, r.currentStat.StatusCode)
+	assert.Nil(t, r.currentStat.ExitCode)
+}
+
+func TestCancelRun(t *testing.T) {
+	cache := NewMockCache()
+	onFailureJob := GetMockJob()
+	onFailureJob.Id = "on-failure"
+	assert.NoError(t, cache.Set(onFailureJob))
+	j := GetMockJob()
+	j.Id = "cancelled"
+	j.Command = "bash -c 'sleep 30'"
+	j.OnFailureJob = onFailureJob.Id
+	assert.NoError(t, cache.Set(j))
+	assert.Equal(t, ErrRunNotInProgress, j.CancelRun("not-a-run"))
+
+	done := make(chan struct{})
+	go func() {
+		j.Run(cache)
+		close(done)
+	}()
+
+	var running []*JobStat
+	for i := 0; i < 100 && len(running) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		running = RunningStats(j.Id)
+	}
+	if !assert.Len(t, running, 1) {
+		return
+	}
+	start := time.Now()
+	assert.NoError(t, j.CancelRun(running[0].Id))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The run should have been cancelled")
+	}
+	assert.WithinDuration(t, start, time.Now(), time.Second)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Cancelled, stats[0].Status)
+		assert.Equal(t, uint(0), stats[0].NumberOfRetries)
+	}
+	assert.Equal(t, uint(0), j.Metadata.ErrorCount)
+	assert.Equal(t, uint(0), onFailureJob.Metadata.NumberOfFinishedRuns)
+	assert.Equal(t, ErrRunNotInProgress, j.CancelRun(running[0].Id))
+}
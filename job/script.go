@@ -0,0 +1,47 @@
+package job
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// DefaultInterpreter runs the scripts of script jobs that don't give an Interpreter.
+const DefaultInterpreter = "/bin/sh"
+
+// writeScript writes the job's script, templated, to a file only NextKala can read,
+// and returns its path. The caller must remove the file once it's done with it.
+func (j *JobRunner) writeScript() (string, error) {
+	script, err := j.templatizeLocal(j.job.Script)
+	if err != nil {
+		return "", fmt.Errorf("Error templatizing script: %v", err)
+	}
+
+	f, err := ioutil.TempFile("", "nextkala-script-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(script); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// interpreterArgs returns the command running the script in the file at path.
+func (j *Job) interpreterArgs(path string) ([]string, error) {
+	interpreter := j.Interpreter
+	if interpreter == "" {
+		interpreter = DefaultInterpreter
+	}
+	args, err := initShParser().Parse(interpreter)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, path), nil
+}
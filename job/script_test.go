@@ -0,0 +1,57 @@
+package job
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptJob(t *testing.T) {
+	cache := NewMockCache()
+	j := &Job{
+		Name:               "mock_script_job",
+		Id:                 "script-job",
+		JobType:            ScriptJob,
+		Script:             "set -u\necho \"{{$.Name}} $GREETING\"\necho \"$0\"\n",
+		Interpreter:        "/bin/bash -e",
+		Env:                map[string]string{"GREETING": "hello"},
+		TemplateDelimiters: "{{ }}",
+	}
+	assert.NoError(t, j.validation())
+	assert.NoError(t, cache.Set(j))
+	j.Run(cache)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, Status.Success, stats[0].Status, stats[0].Output)
+		lines := strings.Split(strings.TrimSpace(stats[0].Stdout), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.Equal(t, "mock_script_job hello", lines[0])
+			_, err := os.Stat(lines[1])
+			assert.True(t, os.IsNotExist(err), "The script should have been removed")
+		}
+	}
+}
+
+func TestScriptJobDefaultInterpreter(t *testing.T) {
+	j := &Job{Name: "mock_script_job", JobType: ScriptJob, Script: "echo \"$0\" >&2; exit 4"}
+	r := &JobRunner{job: j}
+	r.runSetup()
+	_, err := r.ScriptRun()
+	assert.Error(t, err)
+	if assert.NotNil(t, r.currentStat.ExitCode) {
+		assert.Equal(t, 4, *r.currentStat.ExitCode)
+	}
+	assert.Contains(t, r.currentStat.Stderr, "nextkala-script-")
+}
+
+func TestScriptJobValidation(t *testing.T) {
+	j := &Job{Name: "mock_script_job", JobType: ScriptJob}
+	assert.Equal(t, ErrInvalidScriptJob, j.validation())
+
+	j.JobType = 3
+	assert.Equal(t, ErrInvalidJobType, j.validation())
+}
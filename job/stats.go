@@ -1,6 +1,7 @@
 package job
 
 import (
+	"encoding/json"
 	"time"
 
 	uuid "github.com/nu7hatch/gouuid"
@@ -19,12 +20,16 @@ type KalaStats struct {
 	LastAttemptedRun time.Time `json:"last_attempted_run"`
 
 	CreatedAt time.Time `json:"created"`
+
+	// Runs in progress, and waiting for one of the MaxConcurrentRuns slots.
+	PoolStats
 }
 
 // NewKalaStats is used to easily generate a current app-level metrics report.
 func NewKalaStats(cache JobCache) *KalaStats {
 	ks := &KalaStats{
 		CreatedAt: time.Now(),
+		PoolStats: GetPoolStats(),
 	}
 	jobs := cache.GetAll()
 	jobs.Lock.RLock()
@@ -75,18 +80,28 @@ func NewKalaStats(cache JobCache) *KalaStats {
 type JobStatus string
 
 type jobStatus struct {
-	Started JobStatus
-	Running JobStatus
-	Failed  JobStatus
-	Success JobStatus
+	Started   JobStatus
+	Running   JobStatus
+	Failed    JobStatus
+	Success   JobStatus
+	Skipped   JobStatus
+	Deferred  JobStatus
+	TimedOut  JobStatus
+	Cancelled JobStatus
+	Queued    JobStatus
 }
 
 var (
 	Status = &jobStatus{
-		Started: JobStatus("Started"),
-		Running: JobStatus("Running"),
-		Failed:  JobStatus("Failed"),
-		Success: JobStatus("Success"),
+		Started:   JobStatus("Started"),
+		Running:   JobStatus("Running"),
+		Failed:    JobStatus("Failed"),
+		Success:   JobStatus("Success"),
+		Skipped:   JobStatus("Skipped"),
+		Deferred:  JobStatus("Deferred"),
+		TimedOut:  JobStatus("TimedOut"),
+		Cancelled: JobStatus("Cancelled"),
+		Queued:    JobStatus("Queued"),
 	}
 )
 
@@ -99,6 +114,51 @@ type JobStat struct {
 	Status            JobStatus     `json:"status"`
 	ExecutionDuration time.Duration `json:"execution_duration"`
 	Output            string        `json:"output"`
+
+	// What a local job's command wrote to its stdout and stderr, or the body of a remote job's response,
+	// in Stdout. Long outputs are cut down to MaxOutputSize, saying so in OutputTruncated.
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	OutputTruncated bool   `json:"output_truncated"`
+
+	// Exit code of a local job's command, if it ran; -1 if it was killed by a signal.
+	ExitCode *int `json:"exit_code"`
+
+	// HTTP status code of a remote job's response, if there was one.
+	StatusCode int `json:"status_code"`
+
+	// What an async remote job's callback reported, besides its status and output.
+	Result json.RawMessage `json:"result"`
+
+	// When an async remote job's run stops waiting for its callback, while it's Running.
+	CallbackDeadline time.Time `json:"callback_deadline"`
+
+	// A local job's stdout and stderr together, from all of the run's attempts, cut down like the other outputs.
+	// It's only kept of runs with more than one attempt, as the others' is their Stdout and Stderr;
+	// CombinedLog returns it either way.
+	Log string `json:"log"`
+
+	// How long the run waited for slots of its job's resource Pools, and under MaxConcurrentRuns,
+	// before starting. The ExecutionDuration includes it.
+	WaitDuration time.Duration `json:"wait_duration"`
+
+	// Id of the WorkflowRun the run was part of, if any.
+	WorkflowRunId string `json:"workflow_run_id"`
+}
+
+// CombinedLog returns the run's log: its Log, if it has one, or else its Stdout followed by its Stderr.
+func (s *JobStat) CombinedLog() string {
+	if s.Log != "" {
+		return s.Log
+	}
+	return s.Stdout + s.Stderr
+}
+
+// clearOutput clears what was recorded of a previous attempt at the run.
+func (s *JobStat) clearOutput() {
+	s.Stdout, s.Stderr, s.OutputTruncated = "", "", false
+	s.ExitCode, s.StatusCode = nil, 0
+	s.Result = nil
 }
 
 func NewJobStat(jobId string) *JobStat {
@@ -14,8 +14,12 @@ import (
 )
 
 var (
-	jobBucket    = []byte("jobs")
-	jobRunBucket = []byte("job_runs")
+	jobBucket          = []byte("jobs")
+	jobRunBucket       = []byte("job_runs")
+	calendarBucket     = []byte("calendars")
+	resourcePoolBucket = []byte("resource_pools")
+	workflowBucket     = []byte("workflows")
+	workflowRunBucket  = []byte("workflow_runs")
 )
 
 func GetBoltDB(path string) *BoltJobDB {
@@ -234,3 +238,349 @@ func (db *BoltJobDB) DeleteRun(id string) error {
 func (db *BoltJobDB) ClearExpiredRuns() error {
 	return nil
 }
+
+// GetCalendar returns a persisted calendar.
+func (db *BoltJobDB) GetCalendar(name string) (*job.Calendar, error) {
+	c := new(job.Calendar)
+
+	err := db.dbConn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(calendarBucket)
+		if b == nil {
+			return job.ErrCalendarNotFound(name)
+		}
+
+		v := b.Get([]byte(name))
+		if v == nil {
+			return job.ErrCalendarNotFound(name)
+		}
+
+		buf := bytes.NewBuffer(v)
+		return gob.NewDecoder(buf).Decode(c)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetAllCalendars returns all persisted calendars.
+func (db *BoltJobDB) GetAllCalendars() ([]*job.Calendar, error) {
+	allCalendars := []*job.Calendar{}
+
+	err := db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(calendarBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			c := new(job.Calendar)
+			if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(c); err != nil {
+				return err
+			}
+			allCalendars = append(allCalendars, c)
+			return nil
+		})
+	})
+
+	return allCalendars, err
+}
+
+// SaveCalendar persists a calendar.
+func (db *BoltJobDB) SaveCalendar(c *job.Calendar) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(calendarBucket)
+		if err != nil {
+			return err
+		}
+
+		buffer := new(bytes.Buffer)
+		if err := gob.NewEncoder(buffer).Encode(c); err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(c.Name), buffer.Bytes())
+	})
+}
+
+// DeleteCalendar deletes a persisted calendar.
+func (db *BoltJobDB) DeleteCalendar(name string) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(calendarBucket)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(name)) == nil {
+			return job.ErrCalendarNotFound(name)
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+// GetResourcePool returns a persisted resource pool.
+func (db *BoltJobDB) GetResourcePool(name string) (*job.ResourcePool, error) {
+	p := new(job.ResourcePool)
+
+	err := db.dbConn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(resourcePoolBucket)
+		if b == nil {
+			return job.ErrResourcePoolNotFound(name)
+		}
+
+		v := b.Get([]byte(name))
+		if v == nil {
+			return job.ErrResourcePoolNotFound(name)
+		}
+
+		buf := bytes.NewBuffer(v)
+		return gob.NewDecoder(buf).Decode(p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// GetAllResourcePools returns all persisted resource pools.
+func (db *BoltJobDB) GetAllResourcePools() ([]*job.ResourcePool, error) {
+	allPools := []*job.ResourcePool{}
+
+	err := db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(resourcePoolBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			p := new(job.ResourcePool)
+			if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(p); err != nil {
+				return err
+			}
+			allPools = append(allPools, p)
+			return nil
+		})
+	})
+
+	return allPools, err
+}
+
+// SaveResourcePool persists a resource pool.
+func (db *BoltJobDB) SaveResourcePool(p *job.ResourcePool) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(resourcePoolBucket)
+		if err != nil {
+			return err
+		}
+
+		buffer := new(bytes.Buffer)
+		if err := gob.NewEncoder(buffer).Encode(p); err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(p.Name), buffer.Bytes())
+	})
+}
+
+// DeleteResourcePool deletes a persisted resource pool.
+func (db *BoltJobDB) DeleteResourcePool(name string) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(resourcePoolBucket)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(name)) == nil {
+			return job.ErrResourcePoolNotFound(name)
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+// GetWorkflow returns a persisted workflow.
+func (db *BoltJobDB) GetWorkflow(name string) (*job.Workflow, error) {
+	w := new(job.Workflow)
+
+	err := db.dbConn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(workflowBucket)
+		if b == nil {
+			return job.ErrWorkflowNotFound(name)
+		}
+
+		v := b.Get([]byte(name))
+		if v == nil {
+			return job.ErrWorkflowNotFound(name)
+		}
+
+		buf := bytes.NewBuffer(v)
+		return gob.NewDecoder(buf).Decode(w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// GetAllWorkflows returns all persisted workflows.
+func (db *BoltJobDB) GetAllWorkflows() ([]*job.Workflow, error) {
+	allWorkflows := []*job.Workflow{}
+
+	err := db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(workflowBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			w := new(job.Workflow)
+			if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(w); err != nil {
+				return err
+			}
+			allWorkflows = append(allWorkflows, w)
+			return nil
+		})
+	})
+
+	return allWorkflows, err
+}
+
+// SaveWorkflow persists a workflow.
+func (db *BoltJobDB) SaveWorkflow(w *job.Workflow) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(workflowBucket)
+		if err != nil {
+			return err
+		}
+
+		buffer := new(bytes.Buffer)
+		if err := gob.NewEncoder(buffer).Encode(w); err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(w.Name), buffer.Bytes())
+	})
+}
+
+// DeleteWorkflow deletes a persisted workflow, along with its runs.
+func (db *BoltJobDB) DeleteWorkflow(name string) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(workflowBucket)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(name)) == nil {
+			return job.ErrWorkflowNotFound(name)
+		}
+		if err := bucket.Delete([]byte(name)); err != nil {
+			return err
+		}
+
+		runBucket, err := tx.CreateBucketIfNotExists(workflowRunBucket)
+		if err != nil {
+			return err
+		}
+		// Keys can't be deleted while iterating over them.
+		var ids [][]byte
+		err = runBucket.ForEach(func(k, v []byte) error {
+			run := new(job.WorkflowRun)
+			if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(run); err != nil {
+				return err
+			}
+			if run.Workflow == name {
+				ids = append(ids, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := runBucket.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetWorkflowRun returns a persisted workflow run.
+func (db *BoltJobDB) GetWorkflowRun(id string) (*job.WorkflowRun, error) {
+	run := new(job.WorkflowRun)
+
+	err := db.dbConn.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(workflowRunBucket)
+		if b == nil {
+			return job.ErrWorkflowRunNotFound(id)
+		}
+
+		v := b.Get([]byte(id))
+		if v == nil {
+			return job.ErrWorkflowRunNotFound(id)
+		}
+
+		buf := bytes.NewBuffer(v)
+		return gob.NewDecoder(buf).Decode(run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// GetWorkflowRuns returns the persisted runs of a workflow.
+func (db *BoltJobDB) GetWorkflowRuns(workflow string) ([]*job.WorkflowRun, error) {
+	runs := []*job.WorkflowRun{}
+
+	err := db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(workflowRunBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			run := new(job.WorkflowRun)
+			if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(run); err != nil {
+				return err
+			}
+			if run.Workflow == workflow {
+				runs = append(runs, run)
+			}
+			return nil
+		})
+	})
+
+	return runs, err
+}
+
+// SaveWorkflowRun persists a workflow run, replacing it if it already was.
+func (db *BoltJobDB) SaveWorkflowRun(run *job.WorkflowRun) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(workflowRunBucket)
+		if err != nil {
+			return err
+		}
+
+		buffer := new(bytes.Buffer)
+		if err := gob.NewEncoder(buffer).Encode(run); err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(run.Id), buffer.Bytes())
+	})
+}
+
+// DeleteWorkflowRun deletes a persisted workflow run.
+func (db *BoltJobDB) DeleteWorkflowRun(id string) error {
+	return db.dbConn.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(workflowRunBucket)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(id)) == nil {
+			return job.ErrWorkflowRunNotFound(id)
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
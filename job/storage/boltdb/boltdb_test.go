@@ -101,3 +101,114 @@ func TestSaveAndGetAllJobs(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, len(jobs), 2)
 }
+
+func TestSaveGetDeleteCalendar(t *testing.T) {
+	db := GetBoltDB(testDbPath)
+	defer db.Close()
+
+	c := &job.Calendar{
+		Name:     "holidays",
+		Holidays: []job.Holiday{{Name: "Christmas", Date: "2020-12-25"}},
+	}
+	assert.NoError(t, db.SaveCalendar(c))
+
+	c2, err := db.GetCalendar(c.Name)
+	if assert.NoError(t, err) {
+		assert.Equal(t, c.Holidays[0].Date, c2.Holidays[0].Date)
+	}
+
+	all, err := db.GetAllCalendars()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, all)
+
+	assert.NoError(t, db.DeleteCalendar(c.Name))
+	_, err = db.GetCalendar(c.Name)
+	assert.Equal(t, job.ErrCalendarNotFound(c.Name), err)
+}
+
+func TestSaveGetDeleteResourcePool(t *testing.T) {
+	db := GetBoltDB(testDbPath)
+	defer db.Close()
+
+	p := &job.ResourcePool{Name: "reporting-db", Slots: 2}
+	assert.NoError(t, db.SaveResourcePool(p))
+
+	p2, err := db.GetResourcePool(p.Name)
+	if assert.NoError(t, err) {
+		assert.Equal(t, p, p2)
+	}
+
+	all, err := db.GetAllResourcePools()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, all)
+
+	assert.NoError(t, db.DeleteResourcePool(p.Name))
+	_, err = db.GetResourcePool(p.Name)
+	assert.Equal(t, job.ErrResourcePoolNotFound(p.Name), err)
+}
+
+func TestSaveGetDeleteWorkflow(t *testing.T) {
+	db := GetBoltDB(testDbPath)
+	defer db.Close()
+
+	w := &job.Workflow{
+		Name:  "nightly",
+		Nodes: []job.WorkflowNode{{Name: "a", Job: "1"}, {Name: "b", Job: "2"}},
+		Edges: []job.WorkflowEdge{{From: "a", To: "b", On: job.EdgeOnFailure}},
+	}
+	assert.NoError(t, db.SaveWorkflow(w))
+
+	w2, err := db.GetWorkflow(w.Name)
+	if assert.NoError(t, err) {
+		assert.Equal(t, w, w2)
+	}
+
+	all, err := db.GetAllWorkflows()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, all)
+
+	assert.NoError(t, db.DeleteWorkflow(w.Name))
+	_, err = db.GetWorkflow(w.Name)
+	assert.Equal(t, job.ErrWorkflowNotFound(w.Name), err)
+}
+
+func TestSaveGetWorkflowRuns(t *testing.T) {
+	db := GetBoltDB(testDbPath)
+	defer db.Close()
+
+	run := &job.WorkflowRun{
+		Id:       "run",
+		Workflow: "nightly",
+		Status:   job.Status.Running,
+		Nodes:    map[string]*job.WorkflowNodeRun{"a": {Status: job.Status.Running}},
+	}
+	assert.NoError(t, db.SaveWorkflowRun(run))
+	run.Status = job.Status.Success
+	run.Nodes["a"] = &job.WorkflowNodeRun{Status: job.Status.Success, RunId: "a-run"}
+	assert.NoError(t, db.SaveWorkflowRun(run))
+	assert.NoError(t, db.SaveWorkflowRun(&job.WorkflowRun{Id: "other", Workflow: "other"}))
+
+	run2, err := db.GetWorkflowRun(run.Id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, run, run2)
+	}
+
+	runs, err := db.GetWorkflowRuns("nightly")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+
+	_, err = db.GetWorkflowRun("missing")
+	assert.Equal(t, job.ErrWorkflowRunNotFound("missing"), err)
+
+	assert.NoError(t, db.DeleteWorkflowRun("other"))
+	_, err = db.GetWorkflowRun("other")
+	assert.Equal(t, job.ErrWorkflowRunNotFound("other"), err)
+	assert.Equal(t, job.ErrWorkflowRunNotFound("other"), db.DeleteWorkflowRun("other"))
+
+	// Deleting a workflow deletes its runs.
+	assert.NoError(t, db.SaveWorkflow(&job.Workflow{Name: "nightly"}))
+	assert.NoError(t, db.DeleteWorkflow("nightly"))
+	runs, err = db.GetWorkflowRuns("nightly")
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+}
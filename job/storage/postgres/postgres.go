@@ -13,8 +13,12 @@ import (
 )
 
 const (
-	JobTable    = "jobs"
-	JobRunTable = "job_runs"
+	JobTable          = "jobs"
+	JobRunTable       = "job_runs"
+	CalendarTable     = "calendars"
+	ResourcePoolTable = "resource_pools"
+	WorkflowTable     = "workflows"
+	WorkflowRunTable  = "workflow_runs"
 )
 
 type DB struct {
@@ -31,6 +35,10 @@ func New(dsn string) *DB {
 	// passive attempt to create table
 	_, _ = connection.Exec(fmt.Sprintf(`create table if not exists %s (id uuid primary key, job jsonb);`, JobTable))
 	_, _ = connection.Exec(fmt.Sprintf(`create table if not exists %s (id uuid primary key, job_id uuid not null references %s (id) on delete cascade, run jsonb);`, JobRunTable, JobTable))
+	_, _ = connection.Exec(fmt.Sprintf(`create table if not exists %s (name text primary key, calendar jsonb);`, CalendarTable))
+	_, _ = connection.Exec(fmt.Sprintf(`create table if not exists %s (name text primary key, pool jsonb);`, ResourcePoolTable))
+	_, _ = connection.Exec(fmt.Sprintf(`create table if not exists %s (name text primary key, workflow jsonb);`, WorkflowTable))
+	_, _ = connection.Exec(fmt.Sprintf(`create table if not exists %s (id uuid primary key, workflow text not null, run jsonb);`, WorkflowRunTable))
 
 	return &DB{
 		conn: connection,
@@ -194,6 +202,257 @@ func (d DB) ClearExpiredRuns() error {
 	return nil
 }
 
+// GetCalendar returns a persisted calendar.
+func (d DB) GetCalendar(name string) (*job.Calendar, error) {
+	template := `select to_jsonb(c.calendar) from (select * from %[1]s where name = $1) as c;`
+	query := fmt.Sprintf(template, CalendarTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query, name).Scan(&r)
+	if err == sql.ErrNoRows {
+		return nil, job.ErrCalendarNotFound(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &job.Calendar{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), result)
+	}
+	return result, err
+}
+
+// GetAllCalendars returns all persisted calendars.
+func (d DB) GetAllCalendars() ([]*job.Calendar, error) {
+	query := fmt.Sprintf(`select coalesce(json_agg(c.calendar), '[]'::json) from (select * from %[1]s) as c;`, CalendarTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query).Scan(&r)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	err = nil
+	calendars := []*job.Calendar{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), &calendars)
+	}
+	return calendars, err
+}
+
+// SaveCalendar persists a calendar.
+func (d DB) SaveCalendar(c *job.Calendar) error {
+	template := `insert into %[1]s (name, calendar) values($1, $2) on conflict (name) do update set calendar = EXCLUDED.calendar;`
+	query := fmt.Sprintf(template, CalendarTable)
+	r, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.Exec(query, c.Name, string(r))
+	return err
+}
+
+// DeleteCalendar deletes a persisted calendar.
+func (d DB) DeleteCalendar(name string) error {
+	query := fmt.Sprintf(`delete from %v where name = $1;`, CalendarTable)
+	res, err := d.conn.Exec(query, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return job.ErrCalendarNotFound(name)
+	}
+	return nil
+}
+
+// GetResourcePool returns a persisted resource pool.
+func (d DB) GetResourcePool(name string) (*job.ResourcePool, error) {
+	template := `select to_jsonb(p.pool) from (select * from %[1]s where name = $1) as p;`
+	query := fmt.Sprintf(template, ResourcePoolTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query, name).Scan(&r)
+	if err == sql.ErrNoRows {
+		return nil, job.ErrResourcePoolNotFound(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &job.ResourcePool{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), result)
+	}
+	return result, err
+}
+
+// GetAllResourcePools returns all persisted resource pools.
+func (d DB) GetAllResourcePools() ([]*job.ResourcePool, error) {
+	query := fmt.Sprintf(`select coalesce(json_agg(p.pool), '[]'::json) from (select * from %[1]s) as p;`, ResourcePoolTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query).Scan(&r)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	err = nil
+	pools := []*job.ResourcePool{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), &pools)
+	}
+	return pools, err
+}
+
+// SaveResourcePool persists a resource pool.
+func (d DB) SaveResourcePool(p *job.ResourcePool) error {
+	template := `insert into %[1]s (name, pool) values($1, $2) on conflict (name) do update set pool = EXCLUDED.pool;`
+	query := fmt.Sprintf(template, ResourcePoolTable)
+	r, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.Exec(query, p.Name, string(r))
+	return err
+}
+
+// DeleteResourcePool deletes a persisted resource pool.
+func (d DB) DeleteResourcePool(name string) error {
+	query := fmt.Sprintf(`delete from %v where name = $1;`, ResourcePoolTable)
+	res, err := d.conn.Exec(query, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return job.ErrResourcePoolNotFound(name)
+	}
+	return nil
+}
+
+// GetWorkflow returns a persisted workflow.
+func (d DB) GetWorkflow(name string) (*job.Workflow, error) {
+	template := `select to_jsonb(w.workflow) from (select * from %[1]s where name = $1) as w;`
+	query := fmt.Sprintf(template, WorkflowTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query, name).Scan(&r)
+	if err == sql.ErrNoRows {
+		return nil, job.ErrWorkflowNotFound(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &job.Workflow{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), result)
+	}
+	return result, err
+}
+
+// GetAllWorkflows returns all persisted workflows.
+func (d DB) GetAllWorkflows() ([]*job.Workflow, error) {
+	query := fmt.Sprintf(`select coalesce(json_agg(w.workflow), '[]'::json) from (select * from %[1]s) as w;`, WorkflowTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query).Scan(&r)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	err = nil
+	workflows := []*job.Workflow{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), &workflows)
+	}
+	return workflows, err
+}
+
+// SaveWorkflow persists a workflow.
+func (d DB) SaveWorkflow(w *job.Workflow) error {
+	template := `insert into %[1]s (name, workflow) values($1, $2) on conflict (name) do update set workflow = EXCLUDED.workflow;`
+	query := fmt.Sprintf(template, WorkflowTable)
+	r, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.Exec(query, w.Name, string(r))
+	return err
+}
+
+// DeleteWorkflow deletes a persisted workflow, along with its runs.
+func (d DB) DeleteWorkflow(name string) error {
+	transaction, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := transaction.Exec(fmt.Sprintf(`delete from %v where name = $1;`, WorkflowTable), name)
+	if err != nil {
+		transaction.Rollback() //nolint:errcheck // adding insult to injury
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		transaction.Rollback() //nolint:errcheck // nothing was deleted
+		return job.ErrWorkflowNotFound(name)
+	}
+	_, err = transaction.Exec(fmt.Sprintf(`delete from %v where workflow = $1;`, WorkflowRunTable), name)
+	if err != nil {
+		transaction.Rollback() //nolint:errcheck // adding insult to injury
+		return err
+	}
+	return transaction.Commit()
+}
+
+// GetWorkflowRun returns a persisted workflow run.
+func (d DB) GetWorkflowRun(id string) (*job.WorkflowRun, error) {
+	template := `select to_jsonb(r.run) from (select * from %[1]s where id = $1) as r;`
+	query := fmt.Sprintf(template, WorkflowRunTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query, id).Scan(&r)
+	if err == sql.ErrNoRows {
+		return nil, job.ErrWorkflowRunNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &job.WorkflowRun{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), result)
+	}
+	return result, err
+}
+
+// GetWorkflowRuns returns the persisted runs of a workflow.
+func (d DB) GetWorkflowRuns(workflow string) ([]*job.WorkflowRun, error) {
+	template := `select coalesce(json_agg(r.run), '[]'::json) from (select * from %[1]s where workflow = $1) as r;`
+	query := fmt.Sprintf(template, WorkflowRunTable)
+	var r sql.NullString
+	err := d.conn.QueryRow(query, workflow).Scan(&r)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	err = nil
+	runs := []*job.WorkflowRun{}
+	if r.Valid {
+		err = json.Unmarshal([]byte(r.String), &runs)
+	}
+	return runs, err
+}
+
+// SaveWorkflowRun persists a workflow run, replacing it if it already was.
+func (d DB) SaveWorkflowRun(run *job.WorkflowRun) error {
+	template := `insert into %[1]s (id, workflow, run) values($1, $2, $3) on conflict (id) do update set run = EXCLUDED.run;`
+	query := fmt.Sprintf(template, WorkflowRunTable)
+	r, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.Exec(query, run.Id, run.Workflow, string(r))
+	return err
+}
+
+// DeleteWorkflowRun deletes a persisted workflow run.
+func (d DB) DeleteWorkflowRun(id string) error {
+	query := fmt.Sprintf(`delete from %v where id = $1;`, WorkflowRunTable)
+	res, err := d.conn.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return job.ErrWorkflowRunNotFound(id)
+	}
+	return nil
+}
+
 // Close closes the connection to Postgres.
 func (d DB) Close() error {
 	return d.conn.Close()
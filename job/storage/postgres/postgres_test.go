@@ -144,3 +144,277 @@ func TestSaveAndGetAllJobRuns(t *testing.T) {
 		}
 	}
 }
+
+func TestSaveAndGetCalendar(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	c := &job.Calendar{
+		Name:     "holidays",
+		Holidays: []job.Holiday{{Name: "Christmas", Date: "2020-12-25"}},
+	}
+	r, err := json.Marshal(c)
+	if assert.NoError(t, err) {
+		m.ExpectExec("insert into calendars .*").
+			WithArgs(c.Name, string(r)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		if assert.NoError(t, db.SaveCalendar(c)) {
+			m.ExpectQuery("select .* from calendars .*").
+				WithArgs(c.Name).
+				WillReturnRows(sqlmock.NewRows([]string{"calendar"}).AddRow(r))
+			c2, err := db.GetCalendar(c.Name)
+			if assert.NoError(t, err) {
+				assert.Equal(t, c.Name, c2.Name)
+				assert.Equal(t, c.Holidays, c2.Holidays)
+			}
+		}
+	}
+
+	m.ExpectQuery("select .* from calendars .*").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	_, err = db.GetCalendar("missing")
+	assert.Equal(t, job.ErrCalendarNotFound("missing"), err)
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestGetAllCalendars(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	m.ExpectQuery("select .* from calendars\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"calendars"}).
+			AddRow(`[{"name": "holidays", "holidays": [{"name": "Christmas", "date": "2020-12-25"}]}, {"name": "freeze"}]`))
+	calendars, err := db.GetAllCalendars()
+	if assert.NoError(t, err) && assert.Len(t, calendars, 2) {
+		assert.Equal(t, "holidays", calendars[0].Name)
+		assert.Equal(t, []job.Holiday{{Name: "Christmas", Date: "2020-12-25"}}, calendars[0].Holidays)
+		assert.Equal(t, "freeze", calendars[1].Name)
+	}
+
+	m.ExpectQuery("select .* from calendars\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"calendars"}).AddRow(`[]`))
+	calendars, err = db.GetAllCalendars()
+	if assert.NoError(t, err) {
+		assert.Empty(t, calendars)
+	}
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestDeleteCalendar(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	m.ExpectExec("delete from calendars .*").
+		WithArgs("holidays").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, db.DeleteCalendar("holidays"))
+
+	m.ExpectExec("delete from calendars .*").
+		WithArgs("missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, job.ErrCalendarNotFound("missing"), db.DeleteCalendar("missing"))
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestSaveAndGetResourcePool(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	p := &job.ResourcePool{Name: "db", Slots: 2}
+	r, err := json.Marshal(p)
+	if assert.NoError(t, err) {
+		m.ExpectExec("insert into resource_pools .*").
+			WithArgs(p.Name, string(r)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		if assert.NoError(t, db.SaveResourcePool(p)) {
+			m.ExpectQuery("select .* from resource_pools .*").
+				WithArgs(p.Name).
+				WillReturnRows(sqlmock.NewRows([]string{"pool"}).AddRow(r))
+			p2, err := db.GetResourcePool(p.Name)
+			if assert.NoError(t, err) {
+				assert.Equal(t, p, p2)
+			}
+		}
+	}
+
+	m.ExpectQuery("select .* from resource_pools .*").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	_, err = db.GetResourcePool("missing")
+	assert.Equal(t, job.ErrResourcePoolNotFound("missing"), err)
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestGetAllResourcePools(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	m.ExpectQuery("select .* from resource_pools\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"pools"}).
+			AddRow(`[{"name": "db", "slots": 2}, {"name": "api", "slots": 5}]`))
+	pools, err := db.GetAllResourcePools()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []*job.ResourcePool{{Name: "db", Slots: 2}, {Name: "api", Slots: 5}}, pools)
+	}
+
+	m.ExpectQuery("select .* from resource_pools\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"pools"}).AddRow(`[]`))
+	pools, err = db.GetAllResourcePools()
+	if assert.NoError(t, err) {
+		assert.Empty(t, pools)
+	}
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestDeleteResourcePool(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	m.ExpectExec("delete from resource_pools .*").
+		WithArgs("db").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, db.DeleteResourcePool("db"))
+
+	m.ExpectExec("delete from resource_pools .*").
+		WithArgs("missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, job.ErrResourcePoolNotFound("missing"), db.DeleteResourcePool("missing"))
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestSaveAndGetWorkflow(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	w := &job.Workflow{
+		Name:  "nightly",
+		Nodes: []job.WorkflowNode{{Name: "extract", Job: "1"}, {Name: "load", Job: "2"}},
+		Edges: []job.WorkflowEdge{{From: "extract", To: "load", On: job.EdgeAlways}},
+	}
+	r, err := json.Marshal(w)
+	if assert.NoError(t, err) {
+		m.ExpectExec("insert into workflows .*").
+			WithArgs(w.Name, string(r)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		if assert.NoError(t, db.SaveWorkflow(w)) {
+			m.ExpectQuery("select .* from workflows .*").
+				WithArgs(w.Name).
+				WillReturnRows(sqlmock.NewRows([]string{"workflow"}).AddRow(r))
+			w2, err := db.GetWorkflow(w.Name)
+			if assert.NoError(t, err) {
+				assert.Equal(t, w, w2)
+			}
+		}
+	}
+
+	m.ExpectQuery("select .* from workflows .*").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	_, err = db.GetWorkflow("missing")
+	assert.Equal(t, job.ErrWorkflowNotFound("missing"), err)
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestGetAllWorkflows(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	m.ExpectQuery("select .* from workflows\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"workflows"}).
+			AddRow(`[{"name": "a", "nodes": [{"name": "n", "job": "1"}]}, {"name": "b"}]`))
+	workflows, err := db.GetAllWorkflows()
+	if assert.NoError(t, err) && assert.Len(t, workflows, 2) {
+		assert.Equal(t, "a", workflows[0].Name)
+		assert.Equal(t, []job.WorkflowNode{{Name: "n", Job: "1"}}, workflows[0].Nodes)
+		assert.Equal(t, "b", workflows[1].Name)
+	}
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestDeleteWorkflow(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	// Along with its runs.
+	m.ExpectBegin()
+	m.ExpectExec("delete from workflows .*").
+		WithArgs("nightly").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	m.ExpectExec("delete from workflow_runs .*").
+		WithArgs("nightly").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	m.ExpectCommit()
+	assert.NoError(t, db.DeleteWorkflow("nightly"))
+
+	m.ExpectBegin()
+	m.ExpectExec("delete from workflows .*").
+		WithArgs("missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	m.ExpectRollback()
+	assert.Equal(t, job.ErrWorkflowNotFound("missing"), db.DeleteWorkflow("missing"))
+
+	m.ExpectExec("delete from workflow_runs .*").
+		WithArgs("b7a3e4c2-5a1d-4f7e-8c9b-0d1e2f3a4b5c").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, db.DeleteWorkflowRun("b7a3e4c2-5a1d-4f7e-8c9b-0d1e2f3a4b5c"))
+
+	m.ExpectExec("delete from workflow_runs .*").
+		WithArgs("missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, job.ErrWorkflowRunNotFound("missing"), db.DeleteWorkflowRun("missing"))
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestSaveAndGetWorkflowRuns(t *testing.T) {
+	db, m := NewTestDb()
+	defer db.Close()
+
+	run := &job.WorkflowRun{
+		Id:        "b7a3e4c2-5a1d-4f7e-8c9b-0d1e2f3a4b5c",
+		Workflow:  "nightly",
+		Status:    job.Status.Running,
+		StartedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Nodes:     map[string]*job.WorkflowNodeRun{"extract": {Status: job.Status.Success, RunId: "1"}},
+	}
+	r, err := json.Marshal(run)
+	if assert.NoError(t, err) {
+		m.ExpectExec("insert into workflow_runs .*").
+			WithArgs(run.Id, run.Workflow, string(r)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		if assert.NoError(t, db.SaveWorkflowRun(run)) {
+			m.ExpectQuery("select .* from workflow_runs .*").
+				WithArgs(run.Id).
+				WillReturnRows(sqlmock.NewRows([]string{"run"}).AddRow(r))
+			run2, err := db.GetWorkflowRun(run.Id)
+			if assert.NoError(t, err) {
+				assert.Equal(t, run, run2)
+			}
+
+			m.ExpectQuery("select .* from workflow_runs .*").
+				WithArgs(run.Workflow).
+				WillReturnRows(sqlmock.NewRows([]string{"runs"}).AddRow(fmt.Sprintf(`[%s]`, r)))
+			runs, err := db.GetWorkflowRuns(run.Workflow)
+			if assert.NoError(t, err) {
+				assert.Equal(t, []*job.WorkflowRun{run}, runs)
+			}
+		}
+	}
+
+	m.ExpectQuery("select .* from workflow_runs .*").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	_, err = db.GetWorkflowRun("missing")
+	assert.Equal(t, job.ErrWorkflowRunNotFound("missing"), err)
+
+	assert.NoError(t, m.ExpectationsWereMet())
+}
@@ -20,7 +20,11 @@ func (d *MockDBGetAll) GetAll() ([]*Job, error) {
 }
 
 type MockDB struct {
-	Runs map[string]*JobStat
+	Runs          map[string]*JobStat
+	Calendars     map[string]*Calendar
+	ResourcePools map[string]*ResourcePool
+	Workflows     map[string]*Workflow
+	WorkflowRuns  map[string]*WorkflowRun
 }
 
 func (m *MockDB) GetAll() ([]*Job, error) {
@@ -71,6 +75,129 @@ func (m *MockDB) ClearExpiredRuns() error {
 	return nil
 }
 
+func (m *MockDB) GetCalendar(name string) (*Calendar, error) {
+	c, ok := m.Calendars[name]
+	if !ok {
+		return nil, ErrCalendarNotFound(name)
+	}
+	return c, nil
+}
+
+func (m *MockDB) GetAllCalendars() ([]*Calendar, error) {
+	calendars := make([]*Calendar, 0)
+	for _, c := range m.Calendars {
+		calendars = append(calendars, c)
+	}
+	return calendars, nil
+}
+
+func (m *MockDB) SaveCalendar(c *Calendar) error {
+	if m.Calendars == nil {
+		m.Calendars = make(map[string]*Calendar)
+	}
+	m.Calendars[c.Name] = c
+	return nil
+}
+
+func (m *MockDB) DeleteCalendar(name string) error {
+	delete(m.Calendars, name)
+	return nil
+}
+
+func (m *MockDB) GetResourcePool(name string) (*ResourcePool, error) {
+	p, ok := m.ResourcePools[name]
+	if !ok {
+		return nil, ErrResourcePoolNotFound(name)
+	}
+	return p, nil
+}
+
+func (m *MockDB) GetAllResourcePools() ([]*ResourcePool, error) {
+	pools := make([]*ResourcePool, 0)
+	for _, p := range m.ResourcePools {
+		pools = append(pools, p)
+	}
+	return pools, nil
+}
+
+func (m *MockDB) SaveResourcePool(p *ResourcePool) error {
+	if m.ResourcePools == nil {
+		m.ResourcePools = make(map[string]*ResourcePool)
+	}
+	m.ResourcePools[p.Name] = p
+	return nil
+}
+
+func (m *MockDB) DeleteResourcePool(name string) error {
+	delete(m.ResourcePools, name)
+	return nil
+}
+
+func (m *MockDB) GetWorkflow(name string) (*Workflow, error) {
+	w, ok := m.Workflows[name]
+	if !ok {
+		return nil, ErrWorkflowNotFound(name)
+	}
+	return w, nil
+}
+
+func (m *MockDB) GetAllWorkflows() ([]*Workflow, error) {
+	workflows := make([]*Workflow, 0)
+	for _, w := range m.Workflows {
+		workflows = append(workflows, w)
+	}
+	return workflows, nil
+}
+
+func (m *MockDB) SaveWorkflow(w *Workflow) error {
+	if m.Workflows == nil {
+		m.Workflows = make(map[string]*Workflow)
+	}
+	m.Workflows[w.Name] = w
+	return nil
+}
+
+func (m *MockDB) DeleteWorkflow(name string) error {
+	delete(m.Workflows, name)
+	for id, run := range m.WorkflowRuns {
+		if run.Workflow == name {
+			delete(m.WorkflowRuns, id)
+		}
+	}
+	return nil
+}
+
+func (m *MockDB) GetWorkflowRun(id string) (*WorkflowRun, error) {
+	run, ok := m.WorkflowRuns[id]
+	if !ok {
+		return nil, ErrWorkflowRunNotFound(id)
+	}
+	return run, nil
+}
+
+func (m *MockDB) GetWorkflowRuns(workflow string) ([]*WorkflowRun, error) {
+	runs := make([]*WorkflowRun, 0)
+	for _, run := range m.WorkflowRuns {
+		if run.Workflow == workflow {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+func (m *MockDB) SaveWorkflowRun(run *WorkflowRun) error {
+	if m.WorkflowRuns == nil {
+		m.WorkflowRuns = make(map[string]*WorkflowRun)
+	}
+	m.WorkflowRuns[run.Id] = run
+	return nil
+}
+
+func (m *MockDB) DeleteWorkflowRun(id string) error {
+	delete(m.WorkflowRuns, id)
+	return nil
+}
+
 func NewMockCache() *LockFreeJobCache {
 	db := &MockDB{Runs: make(map[string]*JobStat)}
 	return NewLockFreeJobCache(db)
@@ -170,15 +297,23 @@ func awaitJobRan(t *testing.T, j *Job, timeout time.Duration) {
 var _ JobDB = (*MemoryDB)(nil)
 
 type MemoryDB struct {
-	m    map[string]*Job
-	runs map[string][]*JobStat
-	lock sync.RWMutex
+	m            map[string]*Job
+	runs         map[string][]*JobStat
+	calendars    map[string]*Calendar
+	pools        map[string]*ResourcePool
+	workflows    map[string]*Workflow
+	workflowRuns map[string]*WorkflowRun
+	lock         sync.RWMutex
 }
 
 func NewMemoryDB() *MemoryDB {
 	return &MemoryDB{
-		m:    map[string]*Job{},
-		runs: map[string][]*JobStat{},
+		m:            map[string]*Job{},
+		runs:         map[string][]*JobStat{},
+		calendars:    map[string]*Calendar{},
+		pools:        map[string]*ResourcePool{},
+		workflows:    map[string]*Workflow{},
+		workflowRuns: map[string]*WorkflowRun{},
 	}
 }
 
@@ -220,21 +355,27 @@ func (m *MemoryDB) Save(j *Job) error {
 }
 
 func (m *MemoryDB) SaveRun(run *JobStat) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	m.runs[run.JobId] = append(m.runs[run.JobId], run)
 	return nil
 }
 
 func (m *MemoryDB) UpdateRun(jobStat *JobStat) error {
+	m.lock.Lock()
 	runs := m.runs[jobStat.JobId]
 	for i, run := range runs {
 		if run.Id == jobStat.Id {
 			runs[i] = jobStat
 		}
 	}
+	m.lock.Unlock()
 	return m.SaveRun(jobStat)
 }
 
 func (m *MemoryDB) GetAllRuns(jobID string) (ret []*JobStat, _ error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 	for ID, runs := range m.runs {
 		for _, run := range runs {
 			if ID == jobID {
@@ -246,6 +387,8 @@ func (m *MemoryDB) GetAllRuns(jobID string) (ret []*JobStat, _ error) {
 }
 
 func (m *MemoryDB) GetRun(runID string) (ret *JobStat, _ error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 	for _, runs := range m.runs {
 		for _, run := range runs {
 			if run.Id == runID {
@@ -267,3 +410,154 @@ func (m *MemoryDB) ClearExpiredRuns() error {
 func (m *MemoryDB) Close() error {
 	return nil
 }
+
+func (m *MemoryDB) GetCalendar(name string) (*Calendar, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	c, exist := m.calendars[name]
+	if !exist {
+		return nil, ErrCalendarNotFound(name)
+	}
+	return c, nil
+}
+
+func (m *MemoryDB) GetAllCalendars() (ret []*Calendar, _ error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, c := range m.calendars {
+		ret = append(ret, c)
+	}
+	return
+}
+
+func (m *MemoryDB) SaveCalendar(c *Calendar) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calendars[c.Name] = c
+	return nil
+}
+
+func (m *MemoryDB) DeleteCalendar(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.calendars[name]; !exists {
+		return ErrCalendarNotFound(name)
+	}
+	delete(m.calendars, name)
+	return nil
+}
+
+func (m *MemoryDB) GetResourcePool(name string) (*ResourcePool, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	p, exist := m.pools[name]
+	if !exist {
+		return nil, ErrResourcePoolNotFound(name)
+	}
+	return p, nil
+}
+
+func (m *MemoryDB) GetAllResourcePools() (ret []*ResourcePool, _ error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, p := range m.pools {
+		ret = append(ret, p)
+	}
+	return
+}
+
+func (m *MemoryDB) SaveResourcePool(p *ResourcePool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.pools[p.Name] = p
+	return nil
+}
+
+func (m *MemoryDB) DeleteResourcePool(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.pools[name]; !exists {
+		return ErrResourcePoolNotFound(name)
+	}
+	delete(m.pools, name)
+	return nil
+}
+
+func (m *MemoryDB) GetWorkflow(name string) (*Workflow, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	w, exist := m.workflows[name]
+	if !exist {
+		return nil, ErrWorkflowNotFound(name)
+	}
+	return w, nil
+}
+
+func (m *MemoryDB) GetAllWorkflows() (ret []*Workflow, _ error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, w := range m.workflows {
+		ret = append(ret, w)
+	}
+	return
+}
+
+func (m *MemoryDB) SaveWorkflow(w *Workflow) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.workflows[w.Name] = w
+	return nil
+}
+
+func (m *MemoryDB) DeleteWorkflow(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.workflows[name]; !exists {
+		return ErrWorkflowNotFound(name)
+	}
+	delete(m.workflows, name)
+	for id, run := range m.workflowRuns {
+		if run.Workflow == name {
+			delete(m.workflowRuns, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDB) GetWorkflowRun(id string) (*WorkflowRun, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	run, exist := m.workflowRuns[id]
+	if !exist {
+		return nil, ErrWorkflowRunNotFound(id)
+	}
+	return run, nil
+}
+
+func (m *MemoryDB) GetWorkflowRuns(workflow string) (ret []*WorkflowRun, _ error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, run := range m.workflowRuns {
+		if run.Workflow == workflow {
+			ret = append(ret, run)
+		}
+	}
+	return
+}
+
+func (m *MemoryDB) SaveWorkflowRun(run *WorkflowRun) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.workflowRuns[run.Id] = run
+	return nil
+}
+
+func (m *MemoryDB) DeleteWorkflowRun(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.workflowRuns[id]; !exists {
+		return ErrWorkflowRunNotFound(id)
+	}
+	delete(m.workflowRuns, id)
+	return nil
+}
@@ -0,0 +1,264 @@
+package job
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxIdleConnsPerHost is how many idle connections to each host remote jobs' requests keep for reuse.
+const MaxIdleConnsPerHost = 16
+
+var (
+	ErrInvalidTLS                   = errors.New("Invalid Job tls. cert_file and key_file must be given together")
+	ErrInsecureSkipVerifyNotAllowed = errors.New("Invalid Job tls. insecure_skip_verify isn't allowed on this server")
+	ErrTLSFileNotAllowed            = errors.New("Invalid Job tls. Files must be in the server's TLS files directory")
+	ErrInvalidProxy                 = errors.New("Invalid Job proxy. Proxies must be http, https or socks5 URLs")
+)
+
+// AllowInsecureSkipVerify says whether remote jobs may skip verifying the certificates of the servers they call.
+var AllowInsecureSkipVerify bool
+
+// TLSFilesDir is the directory that the TLS files remote jobs set must be in. If it isn't set, jobs can't
+// set files of their own, and only use DefaultTLS's.
+var TLSFilesDir string
+
+// DefaultTLS and DefaultProxy are the server's settings for remote jobs' requests, for each that jobs don't set.
+var (
+	DefaultTLS   TLSProperties
+	DefaultProxy string
+)
+
+// TLSProperties are the TLS settings for a remote job's requests. Files are PEM files on the server.
+type TLSProperties struct {
+	// CA certificates to verify servers' certificates with, instead of the system's.
+	CAFile string `json:"ca_file"`
+
+	// Client certificate, and its key, to present to servers that ask for one.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	// Name to send for SNI, and verify the server's certificate against, instead of the URL's host.
+	ServerName string `json:"server_name"`
+
+	// Don't verify servers' certificates. Only allowed with AllowInsecureSkipVerify.
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// withDefaults returns the properties, with those that aren't set taken from defaults.
+func (p TLSProperties) withDefaults(defaults TLSProperties) TLSProperties {
+	if p.CAFile == "" {
+		p.CAFile = defaults.CAFile
+	}
+	if p.CertFile == "" && p.KeyFile == "" {
+		p.CertFile, p.KeyFile = defaults.CertFile, defaults.KeyFile
+	}
+	if p.ServerName == "" {
+		p.ServerName = defaults.ServerName
+	}
+	p.InsecureSkipVerify = p.InsecureSkipVerify || defaults.InsecureSkipVerify
+	return p
+}
+
+func (p TLSProperties) isEmpty() bool {
+	return p == TLSProperties{}
+}
+
+// config returns the tls.Config for the properties, reading the files they refer to.
+func (p TLSProperties) config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         p.ServerName,
+		InsecureSkipVerify: p.InsecureSkipVerify, //nolint:gosec // Only if the server allows it
+	}
+
+	if p.CAFile != "" {
+		pem, err := ioutil.ReadFile(p.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", p.CAFile)
+		}
+	}
+
+	if p.CertFile != "" || p.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// files returns the files the properties refer to.
+func (p TLSProperties) files() []string {
+	var files []string
+	for _, f := range []string{p.CAFile, p.CertFile, p.KeyFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// valid says whether the properties make sense. The files they refer to are only read when they're used,
+// so that a job isn't lost while they're missing, e.g. being replaced.
+func (p *TLSProperties) valid() bool {
+	return (p.CertFile == "") == (p.KeyFile == "")
+}
+
+func (p *TLSProperties) allowed() bool {
+	return !p.InsecureSkipVerify || AllowInsecureSkipVerify
+}
+
+// filesAllowed says whether the files the properties refer to are all in TLSFilesDir.
+func (p *TLSProperties) filesAllowed() bool {
+	for _, f := range p.files() {
+		if TLSFilesDir == "" || !inDir(TLSFilesDir, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// inDir says whether path is in dir, once any symlinks in either are followed.
+func inDir(dir, path string) bool {
+	rel, err := filepath.Rel(resolvePath(dir), resolvePath(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns the absolute path, with symlinks followed if it exists.
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+func validProxy(proxy string) bool {
+	if proxy == "" {
+		return true
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return true
+	}
+	return false
+}
+
+// What a client's transport is made from.
+type transportKey struct {
+	tls   TLSProperties
+	proxy string
+}
+
+type transportClient struct {
+	client *http.Client
+	// Modification times of the TLS files when they were read, to reread them when they change.
+	modTimes []time.Time
+}
+
+// Clients for remote jobs' requests, shared by all of the jobs with the same settings,
+// so that they reuse connections.
+var transportClients = struct {
+	sync.Mutex
+	m map[transportKey]*transportClient
+}{m: map[transportKey]*transportClient{}}
+
+// HTTPClient returns the client for the job's remote requests, according to its TLS and Proxy,
+// or else the server's DefaultTLS and DefaultProxy.
+func (j *Job) HTTPClient() (*http.Client, error) {
+	// What's allowed may have changed since the job was saved.
+	if !j.RemoteProperties.TLS.allowed() {
+		return nil, ErrInsecureSkipVerifyNotAllowed
+	}
+	if !j.RemoteProperties.TLS.filesAllowed() {
+		return nil, ErrTLSFileNotAllowed
+	}
+
+	key := transportKey{tls: j.RemoteProperties.TLS.withDefaults(DefaultTLS), proxy: j.RemoteProperties.Proxy}
+	if key.proxy == "" {
+		key.proxy = DefaultProxy
+	}
+	modTimes := fileModTimes(key.tls.files())
+
+	transportClients.Lock()
+	defer transportClients.Unlock()
+
+	if c, ok := transportClients.m[key]; ok && sameTimes(c.modTimes, modTimes) {
+		return c.client, nil
+	}
+
+	transport, err := newTransport(key)
+	if err != nil {
+		return nil, err
+	}
+	if old, ok := transportClients.m[key]; ok {
+		old.client.CloseIdleConnections()
+	}
+	c := &transportClient{client: &http.Client{Transport: transport}, modTimes: modTimes}
+	transportClients.m[key] = c
+	return c.client, nil
+}
+
+func newTransport(key transportKey) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = MaxIdleConnsPerHost
+
+	if !key.tls.isEmpty() {
+		config, err := key.tls.config()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = config
+	}
+
+	if key.proxy != "" {
+		proxy, err := url.Parse(key.proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport, nil
+}
+
+func fileModTimes(files []string) []time.Time {
+	times := make([]time.Time, len(files))
+	for i, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			times[i] = info.ModTime()
+		}
+	}
+	return times
+}
+
+func sameTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
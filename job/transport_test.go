@@ -0,0 +1,239 @@
+package job
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportErrors(t *testing.T) {
+	j := GetMockRemoteJob(RemoteProperties{Url: "https://example.com", TLS: TLSProperties{CertFile: "client.pem"}})
+	assert.Equal(t, ErrInvalidTLS, j.validation())
+
+	j = GetMockRemoteJob(RemoteProperties{Url: "https://example.com", TLS: TLSProperties{InsecureSkipVerify: true}})
+	assert.Equal(t, ErrInsecureSkipVerifyNotAllowed, j.validation())
+	defer func(allow bool) { AllowInsecureSkipVerify = allow }(AllowInsecureSkipVerify)
+	AllowInsecureSkipVerify = true
+	assert.NoError(t, j.validation())
+
+	// Until it's allowed again, the job can't run either.
+	AllowInsecureSkipVerify = false
+	_, err := j.HTTPClient()
+	assert.Equal(t, ErrInsecureSkipVerifyNotAllowed, err)
+
+	for _, proxy := range []string{"proxy:3128", "ftp://proxy", "http://"} {
+		j = GetMockRemoteJob(RemoteProperties{Url: "https://example.com", Proxy: proxy})
+		assert.Equal(t, ErrInvalidProxy, j.validation(), proxy)
+	}
+}
+
+func TestTLSFilesDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nextkala-tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	outside, err := ioutil.TempDir("", "nextkala-outside")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outside)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "ca.pem"), nil, 0600))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "ca.pem"), filepath.Join(dir, "link.pem")))
+
+	defer func(dir string) { TLSFilesDir = dir }(TLSFilesDir)
+	TLSFilesDir = ""
+	j := GetMockRemoteJob(RemoteProperties{Url: "https://example.com", TLS: TLSProperties{CAFile: filepath.Join(dir, "ca.pem")}})
+	assert.Equal(t, ErrTLSFileNotAllowed, j.validation(), "Jobs can't set files without a directory")
+
+	TLSFilesDir = dir
+	assert.NoError(t, j.validation())
+
+	for _, f := range []string{
+		filepath.Join(outside, "ca.pem"),
+		filepath.Join(dir, "..", filepath.Base(outside), "ca.pem"),
+		filepath.Join(dir, "link.pem"),
+		"ca.pem",
+	} {
+		j = GetMockRemoteJob(RemoteProperties{Url: "https://example.com", TLS: TLSProperties{CAFile: f}})
+		assert.Equal(t, ErrTLSFileNotAllowed, j.validation(), f)
+	}
+
+	// The directory may have changed since the job was saved.
+	j = GetMockRemoteJob(RemoteProperties{Url: "https://example.com", TLS: TLSProperties{CAFile: filepath.Join(dir, "ca.pem")}})
+	assert.NoError(t, j.validation())
+	TLSFilesDir = outside
+	_, err = j.HTTPClient()
+	assert.Equal(t, ErrTLSFileNotAllowed, err)
+}
+
+func TestHTTPClientShared(t *testing.T) {
+	a, err := GetMockRemoteJob(RemoteProperties{Url: "http://a.example.com"}).HTTPClient()
+	assert.NoError(t, err)
+	b, err := GetMockRemoteJob(RemoteProperties{Url: "http://b.example.com"}).HTTPClient()
+	assert.NoError(t, err)
+	assert.True(t, a == b, "Jobs with the same settings should share a client")
+
+	c, err := GetMockRemoteJob(RemoteProperties{Url: "http://a.example.com", Proxy: "http://proxy:3128"}).HTTPClient()
+	assert.NoError(t, err)
+	assert.False(t, a == c, "Jobs with different settings shouldn't share a client")
+}
+
+// writeCert writes a certificate, signed by parent with parentKey or else self-signed, and its key
+// to name.pem and name-key.pem in dir.
+func writeCert(t *testing.T, dir, name string, template *x509.Certificate, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0600))
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+func TestRemoteJobMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nextkala-tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	ca, caKey := writeCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	// Only valid for a name that isn't the test server's address.
+	writeCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "internal.example"},
+		DNSNames:     []string{"internal.example"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writeCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "nextkala"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"))
+	assert.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello, " + r.TLS.PeerCertificates[0].Subject.CommonName)) //nolint:errcheck // Only a test server
+	}))
+	testServer.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	defer func(dir string) { TLSFilesDir = dir }(TLSFilesDir)
+	TLSFilesDir = dir
+
+	withTLS := TLSProperties{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "internal.example",
+	}
+	withoutCert := withTLS
+	withoutCert.CertFile, withoutCert.KeyFile = "", ""
+	withoutServerName := withTLS
+	withoutServerName.ServerName = ""
+
+	tests := []struct {
+		Name    string
+		TLS     TLSProperties
+		Success bool
+	}{
+		{Name: "Client certificate", TLS: withTLS, Success: true},
+		{Name: "No client certificate", TLS: withoutCert},
+		{Name: "Server name not overridden", TLS: withoutServerName},
+		{Name: "Unknown CA", TLS: TLSProperties{ServerName: "internal.example"}},
+	}
+	for _, test := range tests {
+		cache := NewMockCache()
+		j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL, TLS: test.TLS})
+		j.Id = "mtls"
+		assert.NoError(t, j.validation(), test.Name)
+		j.Run(cache)
+
+		stats, err := cache.GetAllRuns(j.Id)
+		assert.NoError(t, err, test.Name)
+		if !assert.Len(t, stats, 1, test.Name) {
+			continue
+		}
+		if test.Success {
+			// Successful remote runs are completed by their callback.
+			assert.Equal(t, 1, int(j.Metadata.SuccessCount), test.Name)
+			assert.Equal(t, "Hello, nextkala", stats[0].Stdout, test.Name)
+		} else {
+			assert.Equal(t, Status.Failed, stats[0].Status, test.Name)
+		}
+	}
+
+	// Server defaults apply to jobs without settings of their own, wherever their files are.
+	TLSFilesDir = ""
+	defer func(defaults TLSProperties) { DefaultTLS = defaults }(DefaultTLS)
+	DefaultTLS = withTLS
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: testServer.URL})
+	j.Run(cache)
+	assert.Equal(t, 1, int(j.Metadata.SuccessCount))
+}
+
+func TestRemoteJobProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests through a proxy have the whole URL.
+		w.Write([]byte("Proxied " + r.URL.String())) //nolint:errcheck // Only a test server
+	}))
+	defer proxy.Close()
+
+	cache := NewMockCache()
+	j := GetMockRemoteJob(RemoteProperties{Url: "http://internal.example/status", Proxy: proxy.URL})
+	j.Id = "proxied"
+	assert.NoError(t, j.validation())
+	j.Run(cache)
+
+	stats, err := cache.GetAllRuns(j.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, int(j.Metadata.SuccessCount))
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, "Proxied http://internal.example/status", stats[0].Stdout)
+	}
+}
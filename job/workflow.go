@@ -0,0 +1,443 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mixer/clock"
+	uuid "github.com/nu7hatch/gouuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// Conditions on which a WorkflowEdge lets its To node run, by how its From node's run went.
+const (
+	EdgeOnSuccess = "success"
+	EdgeOnFailure = "failure"
+	EdgeAlways    = "always"
+)
+
+var (
+	ErrInvalidWorkflow = errors.New("Invalid Workflow. Workflows must have a name, " +
+		"and nodes with names of their own and jobs")
+	ErrInvalidWorkflowEdge = errors.New("Invalid Workflow edge. Edges must join two of the workflow's nodes, " +
+		"on success, failure or always")
+	ErrWorkflowCycle          = errors.New("Invalid Workflow. Its edges can't form a cycle")
+	ErrWorkflowRunInProgress  = errors.New("Workflow run is still in progress")
+	ErrWorkflowRunNotWorkflow = errors.New("Workflow run isn't a run of that workflow")
+)
+
+// ErrWorkflowNotFound is raised when a Workflow is unable to be found within a database.
+type ErrWorkflowNotFound string
+
+func (name ErrWorkflowNotFound) Error() string {
+	return fmt.Sprintf("Workflow with name of %s not found.", string(name))
+}
+
+// ErrWorkflowRunNotFound is raised when a WorkflowRun is unable to be found within a database.
+type ErrWorkflowRunNotFound string
+
+func (id ErrWorkflowRunNotFound) Error() string {
+	return fmt.Sprintf("Workflow run with id of %s not found.", string(id))
+}
+
+// Workflow is a DAG of jobs, run together. Each run of the workflow runs the jobs of its nodes
+// without edges into them first, then each other node once all of the nodes with edges into it
+// have finished, if all of those edges' conditions hold. A node that doesn't run is Skipped, and
+// so are the nodes after it.
+type Workflow struct {
+	Name  string         `json:"name"`
+	Nodes []WorkflowNode `json:"nodes"`
+	Edges []WorkflowEdge `json:"edges"`
+}
+
+// WorkflowNode is a job's place in a Workflow. The same job can be in several nodes.
+type WorkflowNode struct {
+	Name string `json:"name"`
+
+	// Id of the job.
+	Job string `json:"job"`
+}
+
+// WorkflowEdge says that the To node of a Workflow runs after the From node, on its success
+// (the default), its failure, or always.
+// e.g. {"from": "export", "to": "cleanup", "on": "always"}
+type WorkflowEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	On   string `json:"on"`
+}
+
+func (e WorkflowEdge) condition() string {
+	if e.On == "" {
+		return EdgeOnSuccess
+	}
+	return e.On
+}
+
+// holds says whether the edge lets its To node run, given its From node's status. A node that timed out
+// failed, as far as its edges go, and one that was cancelled only lets the nodes of its always edges run.
+func (e WorkflowEdge) holds(from JobStatus) bool {
+	failed := from == Status.Failed || from == Status.TimedOut
+	switch e.condition() {
+	case EdgeOnSuccess:
+		return from == Status.Success
+	case EdgeOnFailure:
+		return failed
+	default:
+		return from == Status.Success || failed || from == Status.Cancelled
+	}
+}
+
+// Validate checks the workflow's nodes and edges, and that its edges don't form a cycle.
+// It doesn't check that the nodes' jobs exist.
+func (w *Workflow) Validate() error {
+	if w.Name == "" || len(w.Nodes) == 0 {
+		return ErrInvalidWorkflow
+	}
+	nodes := make(map[string]bool, len(w.Nodes))
+	for _, n := range w.Nodes {
+		if n.Name == "" || n.Job == "" || nodes[n.Name] {
+			return ErrInvalidWorkflow
+		}
+		nodes[n.Name] = true
+	}
+
+	for _, e := range w.Edges {
+		if !nodes[e.From] || !nodes[e.To] || e.From == e.To {
+			return ErrInvalidWorkflowEdge
+		}
+		switch e.condition() {
+		case EdgeOnSuccess, EdgeOnFailure, EdgeAlways:
+		default:
+			return ErrInvalidWorkflowEdge
+		}
+	}
+
+	// Take away nodes without edges into them, until none are left, or only those on a cycle.
+	into := make(map[string]int, len(w.Nodes))
+	for _, e := range w.Edges {
+		into[e.To]++
+	}
+	ready := []string{}
+	for _, n := range w.Nodes {
+		if into[n.Name] == 0 {
+			ready = append(ready, n.Name)
+		}
+	}
+	for removed := 0; ; removed++ {
+		if len(ready) == 0 {
+			if removed != len(w.Nodes) {
+				return ErrWorkflowCycle
+			}
+			return nil
+		}
+		name := ready[0]
+		ready = ready[1:]
+		for _, e := range w.Edges {
+			if e.From != name {
+				continue
+			}
+			if into[e.To]--; into[e.To] == 0 {
+				ready = append(ready, e.To)
+			}
+		}
+	}
+}
+
+// checkJobs returns an error if any of the workflow's nodes' jobs don't exist.
+func (w *Workflow) checkJobs(cache JobCache) error {
+	for _, n := range w.Nodes {
+		if _, err := cache.Get(n.Job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WorkflowRun is a run of a Workflow, and of its nodes' jobs. The stats of the jobs' runs have its Id
+// as their WorkflowRunId.
+type WorkflowRun struct {
+	Id       string `json:"id"`
+	Workflow string `json:"workflow"`
+
+	// Id of the workflow run this one re-runs, if it does.
+	RerunOf string `json:"rerun_of"`
+
+	// Running until all of the nodes are done, then Failed if any of them failed or timed out,
+	// or else Cancelled if any of them were cancelled, or else Success.
+	Status     JobStatus `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	// By node name.
+	Nodes map[string]*WorkflowNodeRun `json:"nodes"`
+}
+
+// WorkflowNodeRun is how a node of a WorkflowRun is getting on.
+type WorkflowNodeRun struct {
+	// Queued until the nodes before it are done, then Running, then Success, Failed, TimedOut,
+	// or Cancelled if its job's run was cancelled or replaced, or Skipped if its edges' conditions
+	// didn't hold, or its job is disabled, or its ConcurrencyPolicy didn't let it run alongside
+	// a run of the job in progress.
+	Status JobStatus `json:"status"`
+
+	// Id of the run of the node's job, once it has one.
+	RunId string `json:"run_id"`
+}
+
+func (r *WorkflowRun) copy() *WorkflowRun {
+	c := *r
+	c.Nodes = make(map[string]*WorkflowNodeRun, len(r.Nodes))
+	for name, n := range r.Nodes {
+		nodeRun := *n
+		c.Nodes[name] = &nodeRun
+	}
+	return &c
+}
+
+func sortWorkflows(workflows []*Workflow) {
+	sort.Slice(workflows, func(i, k int) bool { return workflows[i].Name < workflows[k].Name })
+}
+
+func sortWorkflowRuns(runs []*WorkflowRun) {
+	sort.Slice(runs, func(i, k int) bool { return runs[i].StartedAt.Before(runs[k].StartedAt) })
+}
+
+// StartWorkflow starts a run of the named workflow, and returns it as it starts.
+func StartWorkflow(cache JobCache, name string) (*WorkflowRun, error) {
+	w, err := cache.GetWorkflow(name)
+	if err != nil {
+		return nil, err
+	}
+	return w.start(cache, "")
+}
+
+// RerunWorkflow starts a new run of the named workflow, as a re-run of its finished run with the id.
+func RerunWorkflow(cache JobCache, name, runID string) (*WorkflowRun, error) {
+	previous, err := cache.GetWorkflowRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	if previous.Workflow != name {
+		return nil, ErrWorkflowRunNotWorkflow
+	}
+	if previous.Status == Status.Running {
+		return nil, ErrWorkflowRunInProgress
+	}
+	w, err := cache.GetWorkflow(name)
+	if err != nil {
+		return nil, err
+	}
+	return w.start(cache, runID)
+}
+
+// workflowClock returns the clock the cache sets on its jobs, if it has one (useful for tests),
+// or else the one jobs use by default.
+func workflowClock(cache JobCache) clock.Clock {
+	clk := &Clock{}
+	if clker, ok := cache.(Clocker); ok && clker.TimeSet() {
+		clk.SetClock(clker.Time())
+	}
+	return clk.Time()
+}
+
+func (w *Workflow) start(cache JobCache, rerunOf string) (*WorkflowRun, error) {
+	if err := w.checkJobs(cache); err != nil {
+		return nil, err
+	}
+
+	u4, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	clk := workflowClock(cache)
+	run := &WorkflowRun{
+		Id:        u4.String(),
+		Workflow:  w.Name,
+		RerunOf:   rerunOf,
+		Status:    Status.Running,
+		StartedAt: clk.Now(),
+		Nodes:     make(map[string]*WorkflowNodeRun, len(w.Nodes)),
+	}
+	for _, n := range w.Nodes {
+		run.Nodes[n.Name] = &WorkflowNodeRun{Status: Status.Queued}
+	}
+	if err := cache.SaveWorkflowRun(run.copy()); err != nil {
+		return nil, err
+	}
+
+	started := run.copy()
+	go w.run(cache, clk, run)
+	return started, nil
+}
+
+// nodeResult is how a node's job's run went.
+type nodeResult struct {
+	node   string
+	status JobStatus
+	// What the nodes after it are told of the run, if it ran.
+	parent *ParentRun
+}
+
+// run runs the nodes of the workflow run, saving it as they start and finish, until all of them are done.
+func (w *Workflow) run(cache JobCache, clk clock.Clock, run *WorkflowRun) {
+	log.Infof("Workflow %s run %s started.", w.Name, run.Id)
+
+	results := make(chan nodeResult)
+	parents := make(map[string]*ParentRun, len(w.Nodes))
+	running := 0
+	for {
+		// Skipping a node can let the nodes after it be decided on in turn.
+		for changed := true; changed; {
+			changed = false
+			for _, n := range w.Nodes {
+				nodeRun := run.Nodes[n.Name]
+				if nodeRun.Status != Status.Queued {
+					continue
+				}
+				ready, holds, parent := w.readiness(n.Name, run, parents)
+				if !ready {
+					continue
+				}
+				changed = true
+				if !holds {
+					nodeRun.Status = Status.Skipped
+					continue
+				}
+				nodeRun.Status = Status.Running
+				running++
+				go w.runNode(cache, run.Id, n, parent, results)
+			}
+		}
+		w.saveRun(cache, run)
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+		run.Nodes[result.node].Status = result.status
+		if result.parent != nil {
+			run.Nodes[result.node].RunId = result.parent.RunId
+			parents[result.node] = result.parent
+		}
+	}
+
+	run.Status = Status.Success
+	for _, nodeRun := range run.Nodes {
+		switch nodeRun.Status {
+		case Status.Failed, Status.TimedOut:
+			run.Status = Status.Failed
+		case Status.Cancelled:
+			if run.Status == Status.Success {
+				run.Status = Status.Cancelled
+			}
+		}
+	}
+	run.FinishedAt = clk.Now()
+	w.saveRun(cache, run)
+	log.Infof("Workflow %s run %s finished: %s.", w.Name, run.Id, run.Status)
+}
+
+// readiness says whether all of the nodes with edges into the node are done, and if so whether all of
+// those edges' conditions hold, and what the node's run is told of its parent: the node of its first edge.
+func (w *Workflow) readiness(node string, run *WorkflowRun, parents map[string]*ParentRun) (ready, holds bool,
+	parent *ParentRun) {
+	holds = true
+	for _, e := range w.Edges {
+		if e.To != node {
+			continue
+		}
+		switch status := run.Nodes[e.From].Status; status {
+		case Status.Queued, Status.Running:
+			return false, false, nil
+		default:
+			holds = holds && e.holds(status)
+		}
+		if parent == nil {
+			parent = parents[e.From]
+		}
+	}
+	return true, holds, parent
+}
+
+// runNode runs the node's job, and sends how it went to results. The run is the workflow run's,
+// so the job's metadata and schedule are left as they are, and neither its dependent jobs
+// nor its OnFailureJob are run.
+func (w *Workflow) runNode(cache JobCache, runID string, n WorkflowNode, parent *ParentRun, results chan<- nodeResult) {
+	result := nodeResult{node: n.Name, status: Status.Failed}
+	defer func() { results <- result }()
+
+	j, err := cache.Get(n.Job)
+	if err != nil {
+		log.Errorf("Error getting the job %s of workflow %s node %s: %v", n.Job, w.Name, n.Name, err)
+		return
+	}
+
+	jobRunner := &JobRunner{job: j, parent: parent, workflowRunID: runID}
+	defer jobRunner.release()
+	stat, _, err := jobRunner.Run(cache)
+	if stat != nil {
+		if err := cache.SaveRun(stat); err != nil {
+			log.Warnf("Unable to save stats for run %+v", stat)
+		}
+	}
+
+	result.parent = jobRunner.result
+	if result.parent == nil && stat != nil {
+		result.parent = newParentRun(stat, stat.Status)
+	}
+	switch {
+	case err == nil:
+		result.status = Status.Success
+	case err == ErrJobSkipped || err == ErrJobDisabled:
+		result.status = Status.Skipped
+	case err == ErrJobCancelled || err == ErrJobReplaced:
+		result.status = Status.Cancelled
+	case errors.Is(err, ErrJobTimedOut):
+		result.status = Status.TimedOut
+	}
+}
+
+func (w *Workflow) saveRun(cache JobCache, run *WorkflowRun) {
+	if err := cache.SaveWorkflowRun(run.copy()); err != nil {
+		log.Errorf("Error saving workflow %s run %s: %v", w.Name, run.Id, err)
+	}
+}
+
+// failInterruptedWorkflowRuns marks the workflow runs that were still running when the server stopped
+// as failed, along with their nodes that were running, and skips their nodes that were queued.
+func failInterruptedWorkflowRuns(cache JobCache) {
+	workflows, err := cache.GetAllWorkflows()
+	if err != nil {
+		log.Errorf("Error getting workflows to fail their interrupted runs: %v", err)
+		return
+	}
+	now := workflowClock(cache).Now()
+	for _, w := range workflows {
+		runs, err := cache.GetWorkflowRuns(w.Name)
+		if err != nil {
+			log.Errorf("Error getting the runs of workflow %s: %v", w.Name, err)
+			continue
+		}
+		for _, run := range runs {
+			if run.Status != Status.Running {
+				continue
+			}
+			for _, nodeRun := range run.Nodes {
+				switch nodeRun.Status {
+				case Status.Running:
+					nodeRun.Status = Status.Failed
+				case Status.Queued:
+					nodeRun.Status = Status.Skipped
+				}
+			}
+			run.Status = Status.Failed
+			run.FinishedAt = now
+			log.Infof("Workflow %s run %s was interrupted; marking it as failed.", w.Name, run.Id)
+			w.saveRun(cache, run)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mixer/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowValidate(t *testing.T) {
+	nodes := []WorkflowNode{{Name: "a", Job: "1"}, {Name: "b", Job: "2"}, {Name: "c", Job: "1"}}
+
+	tests := []struct {
+		Name     string
+		Workflow Workflow
+		Err      error
+	}{
+		{Name: "No name", Workflow: Workflow{Nodes: nodes}, Err: ErrInvalidWorkflow},
+		{Name: "No nodes", Workflow: Workflow{Name: "w"}, Err: ErrInvalidWorkflow},
+		{
+			Name:     "Same node name twice",
+			Workflow: Workflow{Name: "w", Nodes: []WorkflowNode{{Name: "a", Job: "1"}, {Name: "a", Job: "2"}}},
+			Err:      ErrInvalidWorkflow,
+		},
+		{
+			Name:     "Node without a job",
+			Workflow: Workflow{Name: "w", Nodes: []WorkflowNode{{Name: "a"}}},
+			Err:      ErrInvalidWorkflow,
+		},
+		{
+			Name:     "Edge to a missing node",
+			Workflow: Workflow{Name: "w", Nodes: nodes, Edges: []WorkflowEdge{{From: "a", To: "d"}}},
+			Err:      ErrInvalidWorkflowEdge,
+		},
+		{
+			Name:     "Edge to its own node",
+			Workflow: Workflow{Name: "w", Nodes: nodes, Edges: []WorkflowEdge{{From: "a", To: "a"}}},
+			Err:      ErrInvalidWorkflowEdge,
+		},
+		{
+			Name:     "Unknown condition",
+			Workflow: Workflow{Name: "w", Nodes: nodes, Edges: []WorkflowEdge{{From: "a", To: "b", On: "sometimes"}}},
+			Err:      ErrInvalidWorkflowEdge,
+		},
+		{
+			Name: "Cycle",
+			Workflow: Workflow{Name: "w", Nodes: nodes, Edges: []WorkflowEdge{
+				{From: "a", To: "b"}, {From: "b", To: "c"}, {From: "c", To: "b", On: EdgeAlways},
+			}},
+			Err: ErrWorkflowCycle,
+		},
+		{
+			Name: "Fan in",
+			Workflow: Workflow{Name: "w", Nodes: nodes, Edges: []WorkflowEdge{
+				{From: "a", To: "c"}, {From: "b", To: "c", On: EdgeOnFailure},
+			}},
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Err, test.Workflow.Validate(), test.Name)
+	}
+}
+
+func TestWorkflowEdgeHolds(t *testing.T) {
+	// Whether on success, failure and always edges hold, by the status of their From node.
+	tests := map[JobStatus][3]bool{
+		Status.Success:   {true, false, true},
+		Status.Failed:    {false, true, true},
+		Status.TimedOut:  {false, true, true},
+		Status.Cancelled: {false, false, true},
+		Status.Skipped:   {false, false, false},
+	}
+	for status, want := range tests {
+		for i, on := range []string{EdgeOnSuccess, EdgeOnFailure, EdgeAlways} {
+			assert.Equal(t, want[i], WorkflowEdge{On: on}.holds(status), "%s on %s", status, on)
+		}
+	}
+}
+
+func TestSaveWorkflowWithMissingJob(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	w := &Workflow{Name: "w", Nodes: []WorkflowNode{{Name: "a", Job: "missing"}}}
+	assert.Equal(t, ErrJobDoesntExist, cache.SaveWorkflow(w))
+
+	_, err := StartWorkflow(cache, "w")
+	assert.Equal(t, ErrWorkflowNotFound("w"), err)
+}
+
+// getMockWorkflowJob returns a one-off job with the command, saved to the cache, once it has run.
+func getMockWorkflowJob(t *testing.T, cache JobCache, command string) *Job {
+	j := GetMockJob()
+	j.Command = command
+	j.Retries = 0
+	assert.NoError(t, j.Init(cache))
+	deadline := time.Now().Add(10 * time.Second)
+	for !isDone(j) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %s didn't run", j.Id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return j
+}
+
+func isDone(j *Job) bool {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	return j.IsDone
+}
+
+// waitForWorkflowRun returns the workflow run with the id once it has finished.
+func waitForWorkflowRun(t *testing.T, cache JobCache, id string) *WorkflowRun {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		run, err := cache.GetWorkflowRun(id)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if run.Status != Status.Running {
+			return run
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Workflow run %s didn't finish", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWorkflowRun(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	succeeding := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	failing := getMockWorkflowJob(t, cache, "bash -c 'exit 3'")
+	fanIn := getMockWorkflowJob(t, cache, "bash -c 'echo $NEXTKALA_WORKFLOW_RUN_ID $NEXTKALA_PARENT_OUTPUT'")
+
+	w := &Workflow{
+		Name: "nightly",
+		Nodes: []WorkflowNode{
+			{Name: "extract", Job: succeeding.Id},
+			{Name: "other_extract", Job: succeeding.Id},
+			{Name: "load", Job: fanIn.Id},
+			{Name: "report", Job: failing.Id},
+			{Name: "alert", Job: succeeding.Id},
+			{Name: "publish", Job: succeeding.Id},
+			{Name: "announce", Job: succeeding.Id},
+			{Name: "cleanup", Job: succeeding.Id},
+		},
+		Edges: []WorkflowEdge{
+			{From: "extract", To: "load"},
+			{From: "other_extract", To: "load"},
+			{From: "load", To: "report"},
+			{From: "report", To: "alert", On: EdgeOnFailure},
+			{From: "report", To: "publish"},
+			{From: "publish", To: "announce", On: EdgeAlways},
+			{From: "report", To: "cleanup", On: EdgeAlways},
+		},
+	}
+	assert.NoError(t, cache.SaveWorkflow(w))
+
+	started, err := StartWorkflow(cache, w.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, Status.Running, started.Status)
+	assert.Equal(t, Status.Queued, started.Nodes["load"].Status)
+
+	run := waitForWorkflowRun(t, cache, started.Id)
+	assert.Equal(t, Status.Failed, run.Status)
+	want := map[string]JobStatus{
+		"extract":       Status.Success,
+		"other_extract": Status.Success,
+		"load":          Status.Success,
+		"report":        Status.Failed,
+		"alert":         Status.Success,
+		"publish":       Status.Skipped,
+		"announce":      Status.Skipped,
+		"cleanup":       Status.Success,
+	}
+	for name, status := range want {
+		assert.Equal(t, status, run.Nodes[name].Status, name)
+	}
+	assert.Empty(t, run.Nodes["publish"].RunId)
+
+	load, err := cache.GetRun(run.Nodes["load"].RunId)
+	assert.NoError(t, err)
+	assert.Equal(t, run.Id, load.WorkflowRunId)
+	assert.Equal(t, run.Id+" ok", load.Output)
+
+	// A re-run is a new run of the workflow.
+	rerun, err := RerunWorkflow(cache, w.Name, run.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, run.Id, rerun.RerunOf)
+	assert.NotEqual(t, run.Id, rerun.Id)
+	waitForWorkflowRun(t, cache, rerun.Id)
+
+	runs, err := cache.GetWorkflowRuns(w.Name)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	_, err = RerunWorkflow(cache, "other", run.Id)
+	assert.Equal(t, ErrWorkflowRunNotWorkflow, err)
+
+	// The runs were the workflow's: the jobs' own metadata and schedules are as they were.
+	for _, j := range []*Job{succeeding, failing, fanIn} {
+		j.lock.RLock()
+		assert.Equal(t, uint(1), j.Metadata.NumberOfFinishedRuns)
+		assert.True(t, j.IsDone)
+		assert.Nil(t, j.jobTimer)
+		j.lock.RUnlock()
+	}
+}
+
+func TestWorkflowRunSkipsDisabledJobs(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	clk := clock.NewMockClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	cache.Clock.SetClock(clk)
+	first := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	disabled := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	assert.NoError(t, disabled.Disable(cache))
+
+	w := &Workflow{
+		Name: "w",
+		Nodes: []WorkflowNode{
+			{Name: "first", Job: first.Id}, {Name: "disabled", Job: disabled.Id}, {Name: "last", Job: first.Id},
+		},
+		Edges: []WorkflowEdge{{From: "first", To: "disabled"}, {From: "disabled", To: "last", On: EdgeAlways}},
+	}
+	assert.NoError(t, cache.SaveWorkflow(w))
+
+	started, err := StartWorkflow(cache, w.Name)
+	assert.NoError(t, err)
+	run := waitForWorkflowRun(t, cache, started.Id)
+	assert.Equal(t, Status.Success, run.Status)
+	assert.Equal(t, Status.Success, run.Nodes["first"].Status)
+	assert.Equal(t, Status.Skipped, run.Nodes["disabled"].Status)
+	assert.Equal(t, Status.Skipped, run.Nodes["last"].Status)
+	assert.Equal(t, clk.Now(), run.StartedAt)
+	assert.Equal(t, clk.Now(), run.FinishedAt)
+}
+
+func TestWorkflowRunCancelledAndTimedOutNodes(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	succeeding := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	slow := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	slow.lock.Lock()
+	slow.Command = "bash -c 'sleep 30'"
+	slow.lock.Unlock()
+	timingOut := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	timingOut.lock.Lock()
+	timingOut.Command = "bash -c 'sleep 30'"
+	timingOut.Timeout = "PT1S"
+	timingOut.lock.Unlock()
+
+	w := &Workflow{
+		Name: "w",
+		Nodes: []WorkflowNode{
+			{Name: "cancelled", Job: slow.Id},
+			{Name: "cancelled_alert", Job: succeeding.Id},
+			{Name: "cancelled_cleanup", Job: succeeding.Id},
+			{Name: "timed_out", Job: timingOut.Id},
+			{Name: "timed_out_alert", Job: succeeding.Id},
+		},
+		Edges: []WorkflowEdge{
+			{From: "cancelled", To: "cancelled_alert", On: EdgeOnFailure},
+			{From: "cancelled", To: "cancelled_cleanup", On: EdgeAlways},
+			{From: "timed_out", To: "timed_out_alert", On: EdgeOnFailure},
+		},
+	}
+	assert.NoError(t, cache.SaveWorkflow(w))
+
+	started, err := StartWorkflow(cache, w.Name)
+	assert.NoError(t, err)
+	var running []*JobStat
+	for i := 0; i < 100 && len(running) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		running = RunningStats(slow.Id)
+	}
+	if !assert.Len(t, running, 1) {
+		return
+	}
+	assert.NoError(t, slow.CancelRun(running[0].Id))
+
+	run := waitForWorkflowRun(t, cache, started.Id)
+	assert.Equal(t, Status.Failed, run.Status)
+	want := map[string]JobStatus{
+		"cancelled":         Status.Cancelled,
+		"cancelled_alert":   Status.Skipped,
+		"cancelled_cleanup": Status.Success,
+		"timed_out":         Status.TimedOut,
+		"timed_out_alert":   Status.Success,
+	}
+	for name, status := range want {
+		assert.Equal(t, status, run.Nodes[name].Status, name)
+	}
+}
+
+func TestWorkflowRunCancelled(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	slow := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	slow.lock.Lock()
+	slow.Command = "bash -c 'sleep 30'"
+	slow.lock.Unlock()
+	w := &Workflow{Name: "w", Nodes: []WorkflowNode{{Name: "a", Job: slow.Id}}}
+	assert.NoError(t, cache.SaveWorkflow(w))
+
+	started, err := StartWorkflow(cache, w.Name)
+	assert.NoError(t, err)
+	var running []*JobStat
+	for i := 0; i < 100 && len(running) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		running = RunningStats(slow.Id)
+	}
+	if !assert.Len(t, running, 1) {
+		return
+	}
+	assert.NoError(t, slow.CancelRun(running[0].Id))
+
+	run := waitForWorkflowRun(t, cache, started.Id)
+	assert.Equal(t, Status.Cancelled, run.Status)
+}
+
+func TestDeleteWorkflowDeletesItsRuns(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	j := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	w := &Workflow{Name: "w", Nodes: []WorkflowNode{{Name: "a", Job: j.Id}}}
+	assert.NoError(t, cache.SaveWorkflow(w))
+	running := &WorkflowRun{Id: "running", Workflow: w.Name, Status: Status.Running}
+	assert.NoError(t, cache.SaveWorkflowRun(running))
+	assert.NoError(t, cache.SaveWorkflowRun(&WorkflowRun{Id: "other", Workflow: "other", Status: Status.Success}))
+
+	assert.Equal(t, ErrWorkflowRunInProgress, cache.DeleteWorkflow(w.Name))
+
+	running.Status = Status.Success
+	assert.NoError(t, cache.SaveWorkflowRun(running))
+	assert.NoError(t, cache.DeleteWorkflow(w.Name))
+	_, err := cache.GetWorkflowRun(running.Id)
+	assert.Equal(t, ErrWorkflowRunNotFound(running.Id), err)
+	_, err = cache.GetWorkflowRun("other")
+	assert.NoError(t, err)
+}
+
+func TestClearExpiredWorkflowRuns(t *testing.T) {
+	db := NewMemoryDB()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, db.SaveWorkflow(&Workflow{Name: "w"}))
+	for _, run := range []*WorkflowRun{
+		{Id: "expired", Workflow: "w", Status: Status.Success, FinishedAt: now.Add(-2 * time.Hour)},
+		{Id: "recent", Workflow: "w", Status: Status.Failed, FinishedAt: now.Add(-time.Minute)},
+		{Id: "running", Workflow: "w", Status: Status.Running, StartedAt: now.Add(-2 * time.Hour)},
+	} {
+		assert.NoError(t, db.SaveWorkflowRun(run))
+	}
+
+	assert.NoError(t, clearExpiredWorkflowRuns(db, now.Add(-time.Hour)))
+
+	runs, err := db.GetWorkflowRuns("w")
+	assert.NoError(t, err)
+	ids := []string{}
+	for _, run := range runs {
+		ids = append(ids, run.Id)
+	}
+	assert.ElementsMatch(t, []string{"recent", "running"}, ids)
+}
+
+func TestFailInterruptedWorkflowRuns(t *testing.T) {
+	cache := NewLockFreeJobCache(NewMemoryDB())
+	j := getMockWorkflowJob(t, cache, "bash -c 'echo ok'")
+	w := &Workflow{
+		Name:  "w",
+		Nodes: []WorkflowNode{{Name: "a", Job: j.Id}, {Name: "b", Job: j.Id}, {Name: "c", Job: j.Id}},
+	}
+	assert.NoError(t, cache.SaveWorkflow(w))
+	interrupted := &WorkflowRun{
+		Id:       "interrupted",
+		Workflow: w.Name,
+		Status:   Status.Running,
+		Nodes: map[string]*WorkflowNodeRun{
+			"a": {Status: Status.Success, RunId: "1"},
+			"b": {Status: Status.Running, RunId: "2"},
+			"c": {Status: Status.Queued},
+		},
+	}
+	finished := &WorkflowRun{
+		Id:       "finished",
+		Workflow: w.Name,
+		Status:   Status.Success,
+		Nodes:    map[string]*WorkflowNodeRun{},
+	}
+	assert.NoError(t, cache.SaveWorkflowRun(interrupted))
+	assert.NoError(t, cache.SaveWorkflowRun(finished))
+
+	failInterruptedWorkflowRuns(cache)
+
+	run, err := cache.GetWorkflowRun("interrupted")
+	assert.NoError(t, err)
+	assert.Equal(t, Status.Failed, run.Status)
+	assert.False(t, run.FinishedAt.IsZero())
+	assert.Equal(t, Status.Success, run.Nodes["a"].Status)
+	assert.Equal(t, Status.Failed, run.Nodes["b"].Status)
+	assert.Equal(t, Status.Skipped, run.Nodes["c"].Status)
+
+	run, err = cache.GetWorkflowRun("finished")
+	assert.NoError(t, err)
+	assert.Equal(t, Status.Success, run.Status)
+	assert.True(t, run.FinishedAt.IsZero())
+}
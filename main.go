@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/nextiva/nextkala/cmd"
+	"github.com/nextiva/nextkala/job"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -11,6 +12,8 @@ func init() {
 }
 
 func main() {
+	job.ExecWithRlimits()
+
 	if err := cmd.RootCmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
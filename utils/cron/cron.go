@@ -0,0 +1,474 @@
+// Package cron parses cron expressions and computes the times at which they fire.
+//
+// Both the classic 5-field form (minute hour day-of-month month day-of-week)
+// and the 6-field form with a leading seconds field are accepted, as well as
+// the @yearly, @annually, @monthly, @weekly, @daily, @midnight and @hourly macros.
+//
+// The day-of-month field additionally understands `L` (last day of the month),
+// `LW` (last weekday of the month) and `nW` (weekday nearest to day n).
+// The day-of-week field understands `nL` (last day n of the month) and
+// `n#k` (the k-th day n of the month).
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nextiva/nextkala/utils/wallclock"
+)
+
+var (
+	// ErrBadFormat is returned when the expression does not have 5 or 6 fields.
+	ErrBadFormat = errors.New("bad cron expression, expected 5 or 6 space separated fields")
+
+	macros = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+
+	monthNames = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+
+	dayNames = map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}
+)
+
+const (
+	fieldsWithoutSeconds = 5
+	fieldsWithSeconds    = 6
+
+	daysPerWeek = 7
+	maxNth      = 5
+
+	// Give up looking for the next activation after this many years,
+	// which covers expressions such as "0 0 29 2 *" but stops runaway
+	// searches for dates that never happen, like the 30th of February.
+	searchYears = 8
+)
+
+type bounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = bounds{"second", 0, 59, nil}
+	minuteBounds = bounds{"minute", 0, 59, nil}
+	hourBounds   = bounds{"hour", 0, 23, nil}
+	domBounds    = bounds{"day of month", 1, 31, nil}
+	monthBounds  = bounds{"month", 1, 12, monthNames}
+	dowBounds    = bounds{"day of week", 0, 7, dayNames}
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expr string
+
+	second, minute, hour, dom, month, dow uint64
+
+	// Set when the day-of-month or day-of-week field is `*` or `?`.
+	domAny, dowAny bool
+
+	// Day-of-month specials.
+	lastDay        bool
+	lastWeekday    bool
+	nearestWeekday []int
+
+	// Day-of-week specials.
+	lastDow []time.Weekday
+	nthDow  []nthWeekday
+}
+
+type nthWeekday struct {
+	day time.Weekday
+	nth int
+}
+
+// Parse parses a cron expression.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "@") {
+		macro, ok := macros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cron macro %s", spec)
+		}
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case fieldsWithoutSeconds:
+		fields = append([]string{"0"}, fields...)
+	case fieldsWithSeconds:
+	default:
+		return nil, ErrBadFormat
+	}
+
+	s := &Schedule{expr: expr}
+	var err error
+	if s.second, err = parseField(fields[0], secondBounds); err != nil {
+		return nil, err
+	}
+	if s.minute, err = parseField(fields[1], minuteBounds); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[2], hourBounds); err != nil {
+		return nil, err
+	}
+	if err = s.parseDayOfMonth(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[4], monthBounds); err != nil {
+		return nil, err
+	}
+	if err = s.parseDayOfWeek(fields[5]); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// String returns the expression the Schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+func (s *Schedule) parseDayOfMonth(field string) error {
+	if field == "?" || field == "*" {
+		s.domAny = true
+		s.dom = span(domBounds.min, domBounds.max, 1)
+		return nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		switch {
+		case part == "L":
+			s.lastDay = true
+		case part == "LW":
+			s.lastWeekday = true
+		case strings.HasSuffix(part, "W"):
+			day, err := parseValue(strings.TrimSuffix(part, "W"), domBounds)
+			if err != nil {
+				return err
+			}
+			s.nearestWeekday = append(s.nearestWeekday, day)
+		default:
+			bits, err := parseField(part, domBounds)
+			if err != nil {
+				return err
+			}
+			s.dom |= bits
+		}
+	}
+	return nil
+}
+
+func (s *Schedule) parseDayOfWeek(field string) error {
+	if field == "?" || field == "*" {
+		s.dowAny = true
+		s.dow = span(dowBounds.min, dowBounds.max, 1)
+		return nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		switch {
+		case strings.Contains(part, "#"):
+			split := strings.SplitN(part, "#", 2) //nolint:gomnd
+			day, err := parseValue(split[0], dowBounds)
+			if err != nil {
+				return err
+			}
+			nth, err := strconv.Atoi(split[1])
+			if err != nil || nth < 1 || nth > maxNth {
+				return fmt.Errorf("invalid occurrence in day of week %s", part)
+			}
+			s.nthDow = append(s.nthDow, nthWeekday{day: time.Weekday(day % daysPerWeek), nth: nth})
+		case len(part) > 1 && strings.HasSuffix(part, "L"):
+			day, err := parseValue(strings.TrimSuffix(part, "L"), dowBounds)
+			if err != nil {
+				return err
+			}
+			s.lastDow = append(s.lastDow, time.Weekday(day%daysPerWeek))
+		default:
+			bits, err := parseField(part, dowBounds)
+			if err != nil {
+				return err
+			}
+			s.dow |= bits
+		}
+	}
+
+	// 7 is an alias for Sunday.
+	if s.dow&(1<<daysPerWeek) != 0 {
+		s.dow |= 1
+	}
+	return nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps into a bit set.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		partBits, err := parseRange(part, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+// parseRange parses one of `*`, `n`, `n-m`, with an optional `/step` suffix.
+func parseRange(part string, b bounds) (uint64, error) {
+	rangeAndStep := strings.Split(part, "/")
+	if len(rangeAndStep) > 2 { //nolint:gomnd
+		return 0, fmt.Errorf("invalid %s %s", b.name, part)
+	}
+
+	var start, end int
+	var err error
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	switch {
+	case rangeAndStep[0] == "*" || rangeAndStep[0] == "?":
+		start, end = b.min, b.max
+	case len(lowAndHigh) == 1:
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		// `n/step` means every step starting at n.
+		if len(rangeAndStep) == 2 { //nolint:gomnd
+			end = b.max
+		}
+	case len(lowAndHigh) == 2: //nolint:gomnd
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(lowAndHigh[1], b); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("invalid %s %s", b.name, part)
+	}
+
+	step := 1
+	if len(rangeAndStep) == 2 { //nolint:gomnd
+		step, err = strconv.Atoi(rangeAndStep[1])
+		if err != nil || step < 1 {
+			return 0, fmt.Errorf("invalid step in %s %s", b.name, part)
+		}
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("invalid %s range %s", b.name, part)
+	}
+
+	return span(start, end, step), nil
+}
+
+func parseValue(value string, b bounds) (int, error) {
+	if n, ok := b.names[strings.ToUpper(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", b.name, value)
+	}
+	if n < b.min || n > b.max {
+		return 0, fmt.Errorf("%s %d out of range [%d-%d]", b.name, n, b.min, b.max)
+	}
+	return n, nil
+}
+
+func span(start, end, step int) uint64 {
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits
+}
+
+func has(bits uint64, i int) bool {
+	return bits&(1<<uint(i)) != 0
+}
+
+// Next returns the first activation time strictly after t, in t's location.
+// The zero time is returned if the expression can never be satisfied.
+//
+// The expression is matched against the wall clock in t's location. An activation
+// skipped by a daylight saving time transition is moved forward by the length of
+// the gap, and one repeated by a transition only happens on its first occurrence
+// (see wallclock.Resolve).
+func (s *Schedule) Next(t time.Time) time.Time {
+	naive := wallclock.Naive(t)
+	for {
+		naive = s.nextNaive(naive)
+		if naive.IsZero() {
+			return naive
+		}
+		// Readings resolved onto an earlier activation, such as both 02:00 and 03:00
+		// on a night clocks skip from 02:00 to 03:00, only fire once.
+		if next := wallclock.Resolve(naive, t.Location()); next.After(t) {
+			return next
+		}
+	}
+}
+
+// nextNaive returns the first wall clock reading strictly after the naive reading t
+// that matches the expression.
+func (s *Schedule) nextNaive(t time.Time) time.Time {
+	loc := time.UTC
+
+	// Activations happen on whole seconds.
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + searchYears
+
+	// Each field is advanced in turn; whenever a field rolls over into the
+	// next larger unit we start again from the top, as the larger unit may
+	// no longer match.
+	added := false
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for !has(s.month, int(t.Month())) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for !has(s.hour, t.Hour()) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for !has(s.minute, t.Minute()) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for !has(s.second, t.Second()) {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// dayMatches applies the usual cron rule: when both day fields are restricted,
+// a day matches if either of them does.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.domMatches(t)
+	dowMatch := s.dowMatches(t)
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s *Schedule) domMatches(t time.Time) bool {
+	day := t.Day()
+	if has(s.dom, day) {
+		return true
+	}
+
+	last := daysIn(t.Year(), t.Month(), t.Location())
+	if s.lastDay && day == last {
+		return true
+	}
+	if s.lastWeekday && day == nearestWeekday(t, last, last) {
+		return true
+	}
+	for _, n := range s.nearestWeekday {
+		if n <= last && day == nearestWeekday(t, n, last) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) dowMatches(t time.Time) bool {
+	weekday := t.Weekday()
+	if has(s.dow, int(weekday)) {
+		return true
+	}
+
+	day := t.Day()
+	for _, d := range s.lastDow {
+		if d == weekday && day+daysPerWeek > daysIn(t.Year(), t.Month(), t.Location()) {
+			return true
+		}
+	}
+	for _, n := range s.nthDow {
+		if n.day == weekday && (day-1)/daysPerWeek+1 == n.nth {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month, loc *time.Location) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+}
+
+// nearestWeekday returns the day of the month closest to day that falls on a
+// weekday, without crossing into the previous or next month.
+func nearestWeekday(t time.Time, day, last int) int {
+	switch time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, t.Location()).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2 //nolint:gomnd
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2 //nolint:gomnd
+		}
+		return day + 1
+	default:
+		return day
+	}
+}
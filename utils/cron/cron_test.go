@@ -0,0 +1,269 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nextiva/nextkala/utils/cron"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		"* * * * *",
+		"0 * * * * *",
+		"30 9 * * 1-5",
+		"*/15 0-6/2 1,15 JAN-jun ?",
+		"0 0 L * *",
+		"0 0 LW * *",
+		"0 0 15W * *",
+		"0 0 * * 5L",
+		"0 0 * * MON#2",
+		"0 0 * * 7",
+		"@hourly",
+		"@DAILY",
+		"@midnight",
+		"@weekly",
+		"@monthly",
+		"@yearly",
+		"@annually",
+	}
+	for _, expr := range valid {
+		_, err := cron.Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"* * * FOO *",
+		"0 0 * * MON#6",
+		"0 0 32W * *",
+		"@fortnightly",
+	}
+	for _, expr := range invalid {
+		_, err := cron.Parse(expr)
+		assert.Error(t, err, expr)
+	}
+
+	_, err := cron.Parse("* * *")
+	assert.Equal(t, cron.ErrBadFormat, err)
+}
+
+var nextTableTests = []struct {
+	Name     string
+	Expr     string
+	From     string
+	Expected []string
+}{
+	{
+		Name: "Every minute",
+		Expr: "* * * * *",
+		From: "2020-01-13T14:09:30Z",
+		Expected: []string{
+			"2020-01-13T14:10:00Z",
+			"2020-01-13T14:11:00Z",
+		},
+	},
+	{
+		Name: "With seconds",
+		Expr: "*/20 * * * * *",
+		From: "2020-01-13T14:09:30Z",
+		Expected: []string{
+			"2020-01-13T14:09:40Z",
+			"2020-01-13T14:10:00Z",
+			"2020-01-13T14:10:20Z",
+		},
+	},
+	{
+		Name: "Weekdays at 09:30",
+		Expr: "30 9 * * MON-FRI",
+		From: "2020-01-16T10:00:00Z",
+		Expected: []string{
+			"2020-01-17T09:30:00Z",
+			"2020-01-20T09:30:00Z",
+			"2020-01-21T09:30:00Z",
+		},
+	},
+	{
+		Name: "Hourly macro",
+		Expr: "@hourly",
+		From: "2020-01-13T23:09:00Z",
+		Expected: []string{
+			"2020-01-14T00:00:00Z",
+			"2020-01-14T01:00:00Z",
+		},
+	},
+	{
+		Name: "Last day of the month",
+		Expr: "0 12 L * *",
+		From: "2020-01-31T13:00:00Z",
+		Expected: []string{
+			"2020-02-29T12:00:00Z",
+			"2020-03-31T12:00:00Z",
+			"2020-04-30T12:00:00Z",
+		},
+	},
+	{
+		Name: "Last weekday of the month",
+		Expr: "0 0 LW * *",
+		From: "2020-05-01T00:00:00Z",
+		Expected: []string{
+			"2020-05-29T00:00:00Z",
+			"2020-06-30T00:00:00Z",
+		},
+	},
+	{
+		Name: "Nearest weekday",
+		Expr: "0 0 1W,15W * *",
+		From: "2020-02-01T00:00:00Z",
+		Expected: []string{
+			"2020-02-03T00:00:00Z",
+			"2020-02-14T00:00:00Z",
+			"2020-03-02T00:00:00Z",
+			"2020-03-16T00:00:00Z",
+		},
+	},
+	{
+		Name: "Last Friday of the month",
+		Expr: "0 0 * * 5L",
+		From: "2020-01-01T00:00:00Z",
+		Expected: []string{
+			"2020-01-31T00:00:00Z",
+			"2020-02-28T00:00:00Z",
+		},
+	},
+	{
+		Name: "Second Monday of the month",
+		Expr: "0 0 * * MON#2",
+		From: "2020-01-01T00:00:00Z",
+		Expected: []string{
+			"2020-01-13T00:00:00Z",
+			"2020-02-10T00:00:00Z",
+		},
+	},
+	{
+		Name: "Day of month or day of week",
+		Expr: "0 0 13 * FRI",
+		From: "2020-03-01T00:00:00Z",
+		Expected: []string{
+			"2020-03-06T00:00:00Z",
+			"2020-03-13T00:00:00Z",
+			"2020-03-20T00:00:00Z",
+		},
+	},
+	{
+		Name: "Leap day",
+		Expr: "0 0 29 2 *",
+		From: "2020-03-01T00:00:00Z",
+		Expected: []string{
+			"2024-02-29T00:00:00Z",
+		},
+	},
+}
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+
+	for _, testStruct := range nextTableTests {
+		s, err := cron.Parse(testStruct.Expr)
+		assert.NoError(t, err, testStruct.Name)
+
+		from, err := time.Parse(time.RFC3339, testStruct.From)
+		assert.NoError(t, err, testStruct.Name)
+
+		for _, exp := range testStruct.Expected {
+			expected, err := time.Parse(time.RFC3339, exp)
+			assert.NoError(t, err, testStruct.Name)
+
+			from = s.Next(from)
+			assert.Equal(t, expected, from, testStruct.Name)
+		}
+	}
+}
+
+func TestNextNeverFires(t *testing.T) {
+	t.Parallel()
+
+	s, err := cron.Parse("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestNextAcrossDaylightSavingTime(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	assert.NoError(t, err)
+
+	// Clocks go from 02:00 to 03:00 on 2020-03-08 and from 02:00 back to 01:00 on 2020-11-01.
+	dstTableTests := []struct {
+		Name     string
+		Expr     string
+		From     time.Time
+		Expected []string
+	}{
+		{
+			Name: "Skipped activation moves forward by the gap",
+			Expr: "30 2 * * *",
+			From: time.Date(2020, time.March, 7, 3, 0, 0, 0, loc),
+			Expected: []string{
+				"2020-03-08T03:30:00-07:00",
+				"2020-03-09T02:30:00-07:00",
+			},
+		},
+		{
+			Name: "Hourly only fires once when clocks go forward",
+			Expr: "0 * * * *",
+			From: time.Date(2020, time.March, 8, 0, 30, 0, 0, loc),
+			Expected: []string{
+				"2020-03-08T01:00:00-08:00",
+				"2020-03-08T03:00:00-07:00",
+				"2020-03-08T04:00:00-07:00",
+			},
+		},
+		{
+			Name: "Repeated activation fires on first occurrence",
+			Expr: "30 1 * * *",
+			From: time.Date(2020, time.October, 31, 12, 0, 0, 0, loc),
+			Expected: []string{
+				"2020-11-01T01:30:00-07:00",
+				"2020-11-02T01:30:00-08:00",
+			},
+		},
+		{
+			Name: "Every 30 minutes when clocks go back",
+			Expr: "*/30 * * * *",
+			From: time.Date(2020, time.November, 1, 0, 45, 0, 0, loc),
+			Expected: []string{
+				"2020-11-01T01:00:00-07:00",
+				"2020-11-01T01:30:00-07:00",
+				"2020-11-01T02:00:00-08:00",
+			},
+		},
+	}
+
+	for _, testStruct := range dstTableTests {
+		s, err := cron.Parse(testStruct.Expr)
+		assert.NoError(t, err, testStruct.Name)
+
+		from := testStruct.From
+		for _, exp := range testStruct.Expected {
+			from = s.Next(from)
+			assert.Equal(t, exp, from.Format(time.RFC3339), testStruct.Name)
+			assert.Equal(t, loc, from.Location(), testStruct.Name)
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"text/template"
 	"time"
+
+	"github.com/nextiva/nextkala/utils/wallclock"
 )
 
 var (
@@ -128,6 +130,8 @@ func (d *Duration) RelativeTo(t time.Time) time.Duration {
 	return after.Sub(t)
 }
 
+// Add returns t plus the duration. Years, months, weeks and days are added to the
+// date in t's location, while hours, minutes and seconds are elapsed time.
 func (d *Duration) Add(t time.Time) time.Time {
 	result := t
 	result = result.AddDate(d.Years, d.Months, d.Days+d.Weeks*7)
@@ -137,6 +141,27 @@ func (d *Duration) Add(t time.Time) time.Time {
 	return result
 }
 
+// AddIn returns t plus the duration measured on the wall clock in loc, so that
+// P1D and PT24H both give the same time of day on the following day.
+// See wallclock.Resolve for how readings skipped or repeated by daylight saving
+// time transitions are handled.
+func (d *Duration) AddIn(t time.Time, loc *time.Location) time.Time {
+	return wallclock.Resolve(d.Add(wallclock.Naive(t.In(loc))), loc)
+}
+
+// Negated returns the duration with each of its parts negated, for going back in time with Add and AddIn.
+func (d *Duration) Negated() *Duration {
+	return &Duration{
+		Years:   -d.Years,
+		Months:  -d.Months,
+		Weeks:   -d.Weeks,
+		Days:    -d.Days,
+		Hours:   -d.Hours,
+		Minutes: -d.Minutes,
+		Seconds: -d.Seconds,
+	}
+}
+
 func (d *Duration) IsZero() bool {
 	switch {
 	case d.Years != 0:
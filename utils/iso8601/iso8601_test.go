@@ -139,3 +139,41 @@ func TestRelativeTo(t *testing.T) {
 	t.Logf("Anchor plus duration '%s' is: %s", d.String(), d.Add(anchor).Format(time.RFC822))
 	assert.Equal(t, d.RelativeTo(anchor), time.Hour*24*59)
 }
+
+func TestAddIn(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	assert.NoError(t, err)
+
+	day, err := iso8601.FromString("P1D")
+	assert.NoError(t, err)
+	hours, err := iso8601.FromString("PT24H")
+	assert.NoError(t, err)
+
+	// Clocks go forward on the 8th; both keep the time of day.
+	start := time.Date(2020, time.March, 7, 14, 9, 0, 0, loc)
+	assert.Equal(t, "2020-03-08T14:09:00-07:00", day.AddIn(start, loc).Format(time.RFC3339))
+	assert.Equal(t, "2020-03-08T14:09:00-07:00", hours.AddIn(start, loc).Format(time.RFC3339))
+
+	// Unlike Add, where hours are elapsed time.
+	assert.Equal(t, "2020-03-08T15:09:00-07:00", hours.Add(start).Format(time.RFC3339))
+
+	// The wall clock is that of loc, not of t's location.
+	assert.Equal(t, "2020-03-08T14:09:00-07:00", day.AddIn(start.UTC(), loc).Format(time.RFC3339))
+
+	// 02:30 is skipped on the 8th.
+	start = time.Date(2020, time.March, 7, 2, 30, 0, 0, loc)
+	assert.Equal(t, "2020-03-08T03:30:00-07:00", day.AddIn(start, loc).Format(time.RFC3339))
+}
+
+func TestNegated(t *testing.T) {
+	t.Parallel()
+
+	dur, err := iso8601.FromString("P1DT2H")
+	assert.NoError(t, err)
+
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2020, time.February, 28, 22, 0, 0, 0, time.UTC), dur.Negated().Add(start))
+	assert.Equal(t, start, dur.Negated().Add(dur.Add(start)))
+}
@@ -0,0 +1,124 @@
+// Package jsonpath picks values out of decoded JSON documents with a subset of JSONPath.
+//
+// A path starts at the root, `$`, and goes through object members, as `.name` or `['name']`,
+// and array elements, as `[index]`, where negative indexes count back from the end,
+// e.g. `$.items[0].status` or `$['first name']`. Wildcards, slices, filters and
+// recursive descent aren't supported.
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBadFormat is returned when a path isn't in the supported subset of JSONPath.
+var ErrBadFormat = errors.New("bad JSONPath, expected e.g. $.items[0].name")
+
+type step struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// Path is a parsed JSONPath.
+type Path struct {
+	raw   string
+	steps []step
+}
+
+// Parse parses a JSONPath.
+func Parse(path string) (*Path, error) {
+	p := &Path{raw: path}
+	if !strings.HasPrefix(path, "$") {
+		return nil, ErrBadFormat
+	}
+
+	rest := path[1:]
+	for rest != "" {
+		var s step
+		var err error
+		switch rest[0] {
+		case '.':
+			s, rest, err = parseMember(rest[1:])
+		case '[':
+			s, rest, err = parseBracket(rest[1:])
+		default:
+			err = ErrBadFormat
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.steps = append(p.steps, s)
+	}
+	return p, nil
+}
+
+// parseMember parses the name following a `.`, returning what's left of the path.
+func parseMember(rest string) (step, string, error) {
+	end := strings.IndexAny(rest, ".[")
+	if end == -1 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return step{}, "", ErrBadFormat
+	}
+	return step{key: rest[:end]}, rest[end:], nil
+}
+
+// parseBracket parses a quoted name or an index, and the closing bracket, following a `[`.
+func parseBracket(rest string) (step, string, error) {
+	if rest != "" && (rest[0] == '\'' || rest[0] == '"') {
+		quote := rest[0]
+		end := strings.IndexByte(rest[1:], quote)
+		if end == -1 || !strings.HasPrefix(rest[end+2:], "]") {
+			return step{}, "", ErrBadFormat
+		}
+		return step{key: rest[1 : end+1]}, rest[end+3:], nil
+	}
+
+	end := strings.IndexByte(rest, ']')
+	if end == -1 {
+		return step{}, "", ErrBadFormat
+	}
+	index, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return step{}, "", fmt.Errorf("%w: %s isn't an index", ErrBadFormat, rest[:end])
+	}
+	return step{index: index, isIndex: true}, rest[end+1:], nil
+}
+
+// Get returns the value at the path in doc, a document decoded by encoding/json into an interface{},
+// and whether there is one.
+func (p *Path) Get(doc interface{}) (interface{}, bool) {
+	v := doc
+	for _, s := range p.steps {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			if s.isIndex {
+				return nil, false
+			}
+			var ok bool
+			if v, ok = node[s.key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i := s.index
+			if i < 0 {
+				i += len(node)
+			}
+			if !s.isIndex || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (p *Path) String() string {
+	return p.raw
+}
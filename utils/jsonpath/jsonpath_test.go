@@ -0,0 +1,71 @@
+package jsonpath_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nextiva/nextkala/utils/jsonpath"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{"$", "$.ok", "$.items[0].status", "$['first name']", `$["a.b"][-1]`}
+	for _, path := range valid {
+		p, err := jsonpath.Parse(path)
+		if assert.NoError(t, err, path) {
+			assert.Equal(t, path, p.String())
+		}
+	}
+
+	invalid := []string{"", "ok", "$.", "$..ok", "$[", "$[x]", "$['unclosed]", "$['a'", "$ok", "$.items[*]"}
+	for _, path := range invalid {
+		_, err := jsonpath.Parse(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"ok": false,
+		"items": [{"status": "done"}, {"status": "failed"}],
+		"first name": "Ada",
+		"a.b": [1, 2, 3]
+	}`), &doc))
+
+	tests := []struct {
+		path  string
+		value interface{}
+		found bool
+	}{
+		{"$.ok", false, true},
+		{"$.items[0].status", "done", true},
+		{"$.items[-1].status", "failed", true},
+		{"$['first name']", "Ada", true},
+		{`$["a.b"][2]`, float64(3), true},
+		{"$.missing", nil, false},
+		{"$.items[2]", nil, false},
+		{"$.items.status", nil, false},
+		{"$.ok.value", nil, false},
+	}
+	for _, test := range tests {
+		p, err := jsonpath.Parse(test.path)
+		if !assert.NoError(t, err, test.path) {
+			continue
+		}
+		value, found := p.Get(doc)
+		assert.Equal(t, test.found, found, test.path)
+		assert.Equal(t, test.value, value, test.path)
+	}
+
+	root, err := jsonpath.Parse("$")
+	assert.NoError(t, err)
+	value, found := root.Get(doc)
+	assert.True(t, found)
+	assert.Equal(t, doc, value)
+}
@@ -0,0 +1,41 @@
+// Package wallclock converts between instants and the readings of a wall clock
+// in a given location, with defined behaviour around daylight saving time transitions.
+//
+// Wall clock readings are represented as "naive" times: a time.Time in UTC whose
+// fields are the reading. Arithmetic on naive times (AddDate, Add) is therefore
+// pure wall clock arithmetic, unaffected by any transitions.
+package wallclock
+
+import "time"
+
+// Transitions are assumed to be at least this far apart, which holds for every
+// zone in the IANA database.
+const transitionWindow = 24 * time.Hour
+
+// Naive returns the wall clock reading of t in its own location.
+func Naive(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// Resolve returns the instant at which the wall clocks in loc show the naive reading.
+//
+// A reading skipped by a transition (clocks going forward) is moved forward by the
+// length of the gap, e.g. 02:30 on a night clocks go from 02:00 to 03:00 resolves to 03:30.
+// A reading that occurs twice (clocks going back) resolves to its first occurrence.
+func Resolve(naive time.Time, loc *time.Location) time.Time {
+	t := time.Date(naive.Year(), naive.Month(), naive.Day(),
+		naive.Hour(), naive.Minute(), naive.Second(), naive.Nanosecond(), loc)
+
+	_, before := t.Add(-transitionWindow).Zone()
+	_, after := t.Zone()
+
+	// Reading the clock with the offset in effect before any transition gives the first
+	// occurrence of a repeated reading; for a skipped reading it is the only sensible answer.
+	for _, offset := range []int{before, after} {
+		candidate := naive.Add(-time.Duration(offset) * time.Second).In(loc)
+		if _, got := candidate.Zone(); got == offset {
+			return candidate
+		}
+	}
+	return naive.Add(-time.Duration(before) * time.Second).In(loc)
+}
@@ -0,0 +1,53 @@
+package wallclock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nextiva/nextkala/utils/wallclock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	assert.NoError(t, err)
+
+	resolveTests := []struct {
+		Name     string
+		Naive    string
+		Expected string
+	}{
+		{"Standard time", "2020-01-13T14:09:00Z", "2020-01-13T14:09:00-08:00"},
+		{"Daylight time", "2020-07-13T14:09:00Z", "2020-07-13T14:09:00-07:00"},
+		{"Skipped reading moves forward by the gap", "2020-03-08T02:30:00Z", "2020-03-08T03:30:00-07:00"},
+		{"Just after the gap", "2020-03-08T03:00:00Z", "2020-03-08T03:00:00-07:00"},
+		{"Later on the day clocks go forward", "2020-03-08T10:00:00Z", "2020-03-08T10:00:00-07:00"},
+		{"Repeated reading resolves to first occurrence", "2020-11-01T01:30:00Z", "2020-11-01T01:30:00-07:00"},
+		{"Just after the repeated hour", "2020-11-01T02:00:00Z", "2020-11-01T02:00:00-08:00"},
+		{"Later on the day clocks go back", "2020-11-01T10:00:00Z", "2020-11-01T10:00:00-08:00"},
+	}
+
+	for _, testStruct := range resolveTests {
+		naive, err := time.Parse(time.RFC3339, testStruct.Naive)
+		assert.NoError(t, err)
+		expected, err := time.Parse(time.RFC3339, testStruct.Expected)
+		assert.NoError(t, err)
+
+		actual := wallclock.Resolve(naive, loc)
+		assert.True(t, expected.Equal(actual), "%s: expected %s, got %s", testStruct.Name, expected, actual)
+		assert.Equal(t, loc, actual.Location(), testStruct.Name)
+	}
+}
+
+func TestNaive(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	assert.NoError(t, err)
+
+	naive := wallclock.Naive(time.Date(2020, time.March, 8, 3, 30, 0, 0, loc))
+	assert.Equal(t, time.Date(2020, time.March, 8, 3, 30, 0, 0, time.UTC), naive)
+}